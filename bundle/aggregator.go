@@ -0,0 +1,143 @@
+package bundle
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"dvr-upload/queue"
+)
+
+const (
+	ReasonComplete = "complete"
+	ReasonTimeout  = "timeout"
+)
+
+type groupKey struct {
+	imei        string
+	uploadType  string
+	captureTime int64
+}
+
+type group struct {
+	event    queue.BundleEvent
+	channels map[string]queue.BundleChannel
+	timer    *time.Timer
+}
+
+// Aggregator agrupa os uploads que compartilham IMEI, horário de captura e tipo.
+// O bundle é emitido quando todos os canais esperados chegam ou quando a janela
+// (contada a partir do primeiro canal) expira.
+type Aggregator struct {
+	mu       sync.Mutex
+	window   time.Duration
+	expected []string
+	groups   map[groupKey]*group
+	emit     func(queue.BundleEvent)
+}
+
+// NewAggregator cria o agregador. Sem canais esperados, os bundles só são emitidos no timeout.
+func NewAggregator(window time.Duration, expected []string, emit func(queue.BundleEvent)) *Aggregator {
+	return &Aggregator{
+		window:   window,
+		expected: expected,
+		groups:   make(map[groupKey]*group),
+		emit:     emit,
+	}
+}
+
+// Add registra o upload de um canal. Reenvios do mesmo canal substituem o anterior.
+func (a *Aggregator) Add(imei, uploadType string, captureTime time.Time, item queue.BundleChannel) {
+	key := groupKey{imei: imei, uploadType: uploadType, captureTime: captureTime.Unix()}
+
+	a.mu.Lock()
+	g, ok := a.groups[key]
+	if !ok {
+		g = &group{
+			event: queue.BundleEvent{
+				BundleID:    fmt.Sprintf("%s_%s_%s", imei, captureTime.UTC().Format("20060102150405"), uploadType),
+				IMEI:        imei,
+				Type:        uploadType,
+				CaptureTime: captureTime.UTC(),
+			},
+			channels: make(map[string]queue.BundleChannel),
+		}
+		g.timer = time.AfterFunc(a.window, func() { a.flush(key, ReasonTimeout) })
+		a.groups[key] = g
+	}
+	g.channels[item.Channel] = item
+	complete := len(a.expected) > 0 && len(a.missing(g)) == 0
+	a.mu.Unlock()
+
+	if complete {
+		a.flush(key, ReasonComplete)
+	}
+}
+
+// Close emite todos os bundles pendentes (ex: no desligamento do serviço).
+func (a *Aggregator) Close() {
+	a.mu.Lock()
+	keys := make([]groupKey, 0, len(a.groups))
+	for key := range a.groups {
+		keys = append(keys, key)
+	}
+	a.mu.Unlock()
+
+	for _, key := range keys {
+		a.flush(key, ReasonTimeout)
+	}
+}
+
+// Pending retorna quantos bundles aguardam canais.
+func (a *Aggregator) Pending() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.groups)
+}
+
+func (a *Aggregator) flush(key groupKey, reason string) {
+	a.mu.Lock()
+	g, ok := a.groups[key]
+	if !ok {
+		// Já emitido (timeout e canal completo concorrendo)
+		a.mu.Unlock()
+		return
+	}
+	delete(a.groups, key)
+	g.timer.Stop()
+
+	event := g.event
+	event.Reason = reason
+	event.Missing = a.missing(g)
+	event.Complete = len(a.expected) > 0 && len(event.Missing) == 0
+	for _, item := range g.channels {
+		event.Channels = append(event.Channels, item)
+	}
+	a.mu.Unlock()
+
+	sort.Slice(event.Channels, func(i, j int) bool {
+		return channelLess(event.Channels[i].Channel, event.Channels[j].Channel)
+	})
+	a.emit(event)
+}
+
+func (a *Aggregator) missing(g *group) []string {
+	var missing []string
+	for _, ch := range a.expected {
+		if _, ok := g.channels[ch]; !ok {
+			missing = append(missing, ch)
+		}
+	}
+	return missing
+}
+
+// channelLess ordena canais numericamente ("2" antes de "10").
+func channelLess(a, b string) bool {
+	a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
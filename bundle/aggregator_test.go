@@ -0,0 +1,170 @@
+package bundle
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"dvr-upload/queue"
+)
+
+func TestAggregator(t *testing.T) {
+	captured := time.Date(2024, 5, 1, 8, 30, 15, 0, time.FixedZone("BRT", -3*3600))
+	item := func(ch string) queue.BundleChannel {
+		return queue.BundleChannel{Channel: ch, Filename: "EVENT_123456789012_00000000_2024_05_01_08_30_15_I_" + ch + ".mp4"}
+	}
+
+	type add struct {
+		imei, uploadType string
+		capture          time.Time
+		channel          string
+	}
+	tests := []struct {
+		name     string
+		expected []string
+		adds     []add
+		close    bool // chama Close em vez de esperar a janela
+		want     []queue.BundleEvent
+	}{
+		{
+			name:     "all expected channels",
+			expected: []string{"1", "2"},
+			adds:     []add{{"123456789012", "I", captured, "2"}, {"123456789012", "I", captured, "1"}},
+			want: []queue.BundleEvent{{
+				BundleID:    "123456789012_20240501113015_I",
+				IMEI:        "123456789012",
+				Type:        "I",
+				CaptureTime: captured.UTC(),
+				Complete:    true,
+				Reason:      ReasonComplete,
+				Channels:    []queue.BundleChannel{item("1"), item("2")},
+			}},
+		},
+		{
+			name:     "window expires with a channel missing",
+			expected: []string{"1", "2", "3"},
+			adds:     []add{{"123456789012", "I", captured, "3"}, {"123456789012", "I", captured, "1"}},
+			want: []queue.BundleEvent{{
+				BundleID:    "123456789012_20240501113015_I",
+				IMEI:        "123456789012",
+				Type:        "I",
+				CaptureTime: captured.UTC(),
+				Reason:      ReasonTimeout,
+				Channels:    []queue.BundleChannel{item("1"), item("3")},
+				Missing:     []string{"2"},
+			}},
+		},
+		{
+			name: "without expected channels only the window closes a bundle",
+			adds: []add{{"123456789012", "I", captured, "10"}, {"123456789012", "I", captured, "2"}},
+			want: []queue.BundleEvent{{
+				BundleID:    "123456789012_20240501113015_I",
+				IMEI:        "123456789012",
+				Type:        "I",
+				CaptureTime: captured.UTC(),
+				Reason:      ReasonTimeout,
+				Channels:    []queue.BundleChannel{item("2"), item("10")},
+			}},
+		},
+		{
+			name:     "close flushes pending bundles",
+			expected: []string{"1", "2"},
+			adds:     []add{{"123456789012", "I", captured, "1"}},
+			close:    true,
+			want: []queue.BundleEvent{{
+				BundleID:    "123456789012_20240501113015_I",
+				IMEI:        "123456789012",
+				Type:        "I",
+				CaptureTime: captured.UTC(),
+				Reason:      ReasonTimeout,
+				Channels:    []queue.BundleChannel{item("1")},
+				Missing:     []string{"2"},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := make(chan queue.BundleEvent, 10)
+			window := 50 * time.Millisecond
+			if tt.close {
+				window = time.Hour
+			}
+			a := NewAggregator(window, tt.expected, func(e queue.BundleEvent) { events <- e })
+			for _, ad := range tt.adds {
+				a.Add(ad.imei, ad.uploadType, ad.capture, item(ad.channel))
+			}
+			if tt.close {
+				a.Close()
+			}
+
+			for i, want := range tt.want {
+				select {
+				case got := <-events:
+					if !reflect.DeepEqual(got, want) {
+						t.Fatalf("bundle %d =\n%+v\nwant\n%+v", i, got, want)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatalf("bundle %d not emitted", i)
+				}
+			}
+			select {
+			case extra := <-events:
+				t.Fatalf("unexpected bundle %+v", extra)
+			case <-time.After(100 * time.Millisecond):
+			}
+			if n := a.Pending(); n != 0 {
+				t.Fatalf("Pending() = %d after the bundles were emitted", n)
+			}
+		})
+	}
+}
+
+func TestAggregatorSeparatesGroups(t *testing.T) {
+	captured := time.Date(2024, 5, 1, 8, 30, 15, 0, time.UTC)
+	events := make(chan queue.BundleEvent, 10)
+	a := NewAggregator(time.Hour, []string{"1", "2"}, func(e queue.BundleEvent) { events <- e })
+
+	a.Add("111111111111", "I", captured, queue.BundleChannel{Channel: "1"})
+	a.Add("222222222222", "I", captured, queue.BundleChannel{Channel: "1"})
+	a.Add("111111111111", "F", captured, queue.BundleChannel{Channel: "1"})
+	a.Add("111111111111", "I", captured.Add(time.Second), queue.BundleChannel{Channel: "1"})
+	// Reenvio do mesmo canal substitui o anterior sem completar o bundle
+	a.Add("111111111111", "I", captured, queue.BundleChannel{Channel: "1", Size: 2})
+	if n := a.Pending(); n != 4 {
+		t.Fatalf("Pending() = %d, want 4", n)
+	}
+
+	a.Add("111111111111", "I", captured, queue.BundleChannel{Channel: "2"})
+	got := <-events
+	if got.IMEI != "111111111111" || got.Type != "I" || !got.CaptureTime.Equal(captured) || !got.Complete {
+		t.Fatalf("bundle = %+v", got)
+	}
+	if len(got.Channels) != 2 || got.Channels[0].Size != 2 {
+		t.Fatalf("channels = %+v, want the resent channel 1 and channel 2", got.Channels)
+	}
+	if n := a.Pending(); n != 3 {
+		t.Fatalf("Pending() = %d, want 3", n)
+	}
+	a.Close()
+	if n := a.Pending(); n != 0 {
+		t.Fatalf("Pending() = %d after Close", n)
+	}
+}
+
+func TestChannelLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"1", "2", true},
+		{"2", "10", true},
+		{"10", "2", false},
+		{"02", "10", true},
+		{"3", "3", false},
+	}
+	for _, tt := range tests {
+		if got := channelLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("channelLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
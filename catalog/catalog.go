@@ -0,0 +1,315 @@
+package catalog
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	StatusUploaded = "uploaded" // enviado ao S3
+	StatusStored   = "stored"   // apenas armazenamento local
+	StatusFailed   = "failed"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+var (
+	bucketMedia  = []byte("media")
+	bucketByIMEI = []byte("by_imei_time")
+	bucketByTime = []byte("by_time")
+	bucketByKey  = []byte("by_key")
+)
+
+// ErrInvalidCursor é retornado quando o cursor de paginação não pôde ser decodificado.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Record representa um upload processado.
+type Record struct {
+	ID          string    `json:"id"`
+	IMEI        string    `json:"imei,omitempty"`
+	Type        string    `json:"type,omitempty"`
+	Channel     string    `json:"channel,omitempty"`
+	CaptureTime time.Time `json:"capture_time"`
+	ReceivedAt  time.Time `json:"received_at"`
+	Filename    string    `json:"filename"`
+	ObjectKey   string    `json:"object_key,omitempty"`
+	LocalPath   string    `json:"local_path,omitempty"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256,omitempty"`
+	Duration    float64   `json:"duration_seconds,omitempty"`
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+
+	Raw json.RawMessage `json:"raw,omitempty"` // bloco raw decodificado (snapshots)
+}
+
+// Query filtra os registros do catálogo. Campos vazios não filtram.
+// From/To se referem ao horário de captura (inclusivos).
+type Query struct {
+	IMEI    string
+	Type    string
+	Channel string
+	From    time.Time
+	To      time.Time
+	Limit   int
+	Cursor  string
+}
+
+// Page é uma página de resultados, do mais recente para o mais antigo.
+type Page struct {
+	Items      []Record `json:"items"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+// Catalog é um catálogo embarcado (bbolt) dos uploads processados.
+type Catalog struct {
+	db *bolt.DB
+}
+
+func Open(path string) (*Catalog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create catalog directory: %w", err)
+	}
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open catalog: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{bucketMedia, bucketByIMEI, bucketByTime, bucketByKey} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize catalog: %w", err)
+	}
+	return &Catalog{db: db}, nil
+}
+
+func (c *Catalog) Close() error {
+	return c.db.Close()
+}
+
+// Put insere ou atualiza um registro (identificado por ID), mantendo os índices consistentes.
+func (c *Catalog) Put(rec Record) error {
+	if rec.ID == "" {
+		return fmt.Errorf("record without id")
+	}
+	if rec.CaptureTime.IsZero() {
+		rec.CaptureTime = rec.ReceivedAt
+	}
+
+	return c.db.Update(func(tx *bolt.Tx) error {
+		media := tx.Bucket(bucketMedia)
+		if old := media.Get([]byte(rec.ID)); old != nil {
+			var prev Record
+			if err := json.Unmarshal(old, &prev); err == nil {
+				deleteIndexes(tx, prev)
+			}
+		}
+
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		if err := media.Put([]byte(rec.ID), data); err != nil {
+			return err
+		}
+		if err := tx.Bucket(bucketByIMEI).Put(imeiIndexKey(rec), []byte(rec.ID)); err != nil {
+			return err
+		}
+		if err := tx.Bucket(bucketByTime).Put(timeIndexKey(rec), []byte(rec.ID)); err != nil {
+			return err
+		}
+		if key := lookupKey(rec); key != "" {
+			if err := tx.Bucket(bucketByKey).Put([]byte(key), []byte(rec.ID)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Delete remove o registro e suas entradas nos índices. Retorna false se o ID não existe.
+func (c *Catalog) Delete(id string) (bool, error) {
+	var found bool
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		media := tx.Bucket(bucketMedia)
+		data := media.Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		found = true
+		var rec Record
+		if err := json.Unmarshal(data, &rec); err == nil {
+			deleteIndexes(tx, rec)
+		}
+		return media.Delete([]byte(id))
+	})
+	return found, err
+}
+
+// FindByKey busca o registro mais recente associado a uma object key (ou ao nome do arquivo,
+// para mídias guardadas só localmente).
+func (c *Catalog) FindByKey(key string) (Record, bool, error) {
+	var rec Record
+	var found bool
+	err := c.db.View(func(tx *bolt.Tx) error {
+		id := tx.Bucket(bucketByKey).Get([]byte(key))
+		if id == nil {
+			return nil
+		}
+		data := tx.Bucket(bucketMedia).Get(id)
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, &rec)
+	})
+	return rec, found, err
+}
+
+// Query percorre o índice adequado (por IMEI quando informado, senão por horário)
+// do mais recente para o mais antigo, aplicando os demais filtros.
+func (c *Catalog) Query(q Query) (Page, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	var prefix []byte
+	bucket := bucketByTime
+	if q.IMEI != "" {
+		bucket = bucketByIMEI
+		prefix = append([]byte(q.IMEI), 0)
+	}
+
+	lower := append(append([]byte{}, prefix...), encodeTime(q.From)...)
+	to := q.To
+	if to.IsZero() {
+		to = time.Unix(0, 1<<63-1)
+	}
+	upper := append(append(append([]byte{}, prefix...), encodeTime(to)...), 0xFF)
+
+	cursorKey := []byte(nil)
+	if q.Cursor != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+		if err != nil || !bytes.HasPrefix(decoded, prefix) {
+			return Page{}, ErrInvalidCursor
+		}
+		cursorKey = decoded
+	}
+
+	page := Page{Items: []Record{}}
+	err := c.db.View(func(tx *bolt.Tx) error {
+		media := tx.Bucket(bucketMedia)
+		cur := tx.Bucket(bucket).Cursor()
+
+		var k, v []byte
+		start := upper
+		if cursorKey != nil && bytes.Compare(cursorKey, upper) < 0 {
+			start = cursorKey
+		}
+		k, v = cur.Seek(start)
+		if k == nil {
+			k, v = cur.Last()
+		} else if bytes.Compare(k, start) >= 0 {
+			k, v = cur.Prev()
+		}
+
+		for ; k != nil; k, v = cur.Prev() {
+			if bytes.Compare(k, lower) < 0 || !bytes.HasPrefix(k, prefix) {
+				break
+			}
+			data := media.Get(v)
+			if data == nil {
+				continue
+			}
+			var rec Record
+			if err := json.Unmarshal(data, &rec); err != nil {
+				continue
+			}
+			if q.Type != "" && rec.Type != q.Type {
+				continue
+			}
+			if q.Channel != "" && rec.Channel != q.Channel {
+				continue
+			}
+			if len(page.Items) == limit {
+				page.NextCursor = base64.RawURLEncoding.EncodeToString(lastKey(page.Items[len(page.Items)-1], q.IMEI != ""))
+				break
+			}
+			page.Items = append(page.Items, rec)
+		}
+		return nil
+	})
+	return page, err
+}
+
+func deleteIndexes(tx *bolt.Tx, rec Record) {
+	tx.Bucket(bucketByIMEI).Delete(imeiIndexKey(rec))
+	tx.Bucket(bucketByTime).Delete(timeIndexKey(rec))
+	if key := lookupKey(rec); key != "" {
+		byKey := tx.Bucket(bucketByKey)
+		if id := byKey.Get([]byte(key)); string(id) == rec.ID {
+			byKey.Delete([]byte(key))
+		}
+	}
+}
+
+// lookupKey é a chave do índice by_key: a object key ou, sem S3, o nome do arquivo.
+func lookupKey(rec Record) string {
+	if rec.ObjectKey != "" {
+		return rec.ObjectKey
+	}
+	return rec.Filename
+}
+
+func lastKey(rec Record, byIMEI bool) []byte {
+	if byIMEI {
+		return imeiIndexKey(rec)
+	}
+	return timeIndexKey(rec)
+}
+
+func imeiIndexKey(rec Record) []byte {
+	key := append([]byte(rec.IMEI), 0)
+	key = append(key, encodeTime(rec.CaptureTime)...)
+	return append(key, rec.ID...)
+}
+
+func timeIndexKey(rec Record) []byte {
+	return append(encodeTime(rec.CaptureTime), rec.ID...)
+}
+
+// encodeTime codifica o horário em big-endian para que a ordem dos bytes siga a ordem cronológica.
+func encodeTime(t time.Time) []byte {
+	b := make([]byte, 8)
+	var n int64
+	if !t.IsZero() {
+		n = t.UnixNano()
+	}
+	if n < 0 {
+		n = 0
+	}
+	binary.BigEndian.PutUint64(b, uint64(n))
+	return b
+}
@@ -0,0 +1,176 @@
+package catalog
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func openTestCatalog(t *testing.T) *Catalog {
+	t.Helper()
+	c, err := Open(filepath.Join(t.TempDir(), "catalog.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	records := []Record{
+		{ID: "r1", IMEI: "111", Type: "I", Channel: "1", CaptureTime: base.Add(1 * time.Minute)},
+		{ID: "r2", IMEI: "222", Type: "F", Channel: "1", CaptureTime: base.Add(2 * time.Minute)},
+		{ID: "r3", IMEI: "111", Type: "F", Channel: "2", CaptureTime: base.Add(3 * time.Minute)},
+		{ID: "r4", IMEI: "111", Type: "I", Channel: "1", CaptureTime: base.Add(4 * time.Minute)},
+		{ID: "r5", IMEI: "222", Type: "I", Channel: "2", CaptureTime: base.Add(5 * time.Minute)},
+		{ID: "r6", IMEI: "111", Type: "F", Channel: "1", CaptureTime: base.Add(6 * time.Minute)},
+		// Mesmo horário: a ordem segue o ID
+		{ID: "r7", IMEI: "111", Type: "I", Channel: "1", CaptureTime: base.Add(6 * time.Minute)},
+	}
+	for _, rec := range records {
+		rec.Filename = rec.ID + ".mp4"
+		rec.Status = StatusStored
+		if err := c.Put(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c
+}
+
+// queryAll percorre todas as páginas seguindo o cursor e retorna os IDs de cada página.
+func queryAll(t *testing.T, c *Catalog, q Query) [][]string {
+	t.Helper()
+	var pages [][]string
+	for i := 0; i < 20; i++ {
+		page, err := c.Query(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []string{}
+		for _, rec := range page.Items {
+			ids = append(ids, rec.ID)
+		}
+		pages = append(pages, ids)
+		if page.NextCursor == "" {
+			return pages
+		}
+		q.Cursor = page.NextCursor
+	}
+	t.Fatal("pagination did not end")
+	return nil
+}
+
+func TestQueryPagination(t *testing.T) {
+	c := openTestCatalog(t)
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		q    Query
+		want [][]string
+	}{
+		{
+			name: "all records in one page",
+			q:    Query{},
+			want: [][]string{{"r7", "r6", "r5", "r4", "r3", "r2", "r1"}},
+		},
+		{
+			name: "by time in pages of 3",
+			q:    Query{Limit: 3},
+			want: [][]string{{"r7", "r6", "r5"}, {"r4", "r3", "r2"}, {"r1"}},
+		},
+		{
+			name: "exact multiple of the page size",
+			q:    Query{IMEI: "222", Limit: 2},
+			want: [][]string{{"r5", "r2"}},
+		},
+		{
+			name: "by imei in pages of 2",
+			q:    Query{IMEI: "111", Limit: 2},
+			want: [][]string{{"r7", "r6"}, {"r4", "r3"}, {"r1"}},
+		},
+		{
+			name: "type filter across pages",
+			q:    Query{Type: "I", Limit: 2},
+			want: [][]string{{"r7", "r5"}, {"r4", "r1"}},
+		},
+		{
+			name: "imei and channel filter",
+			q:    Query{IMEI: "111", Channel: "1", Limit: 2},
+			want: [][]string{{"r7", "r6"}, {"r4", "r1"}},
+		},
+		{
+			name: "time range is inclusive",
+			q:    Query{From: base.Add(2 * time.Minute), To: base.Add(4 * time.Minute), Limit: 2},
+			want: [][]string{{"r4", "r3"}, {"r2"}},
+		},
+		{
+			name: "imei with time range",
+			q:    Query{IMEI: "111", From: base.Add(3 * time.Minute), To: base.Add(6 * time.Minute)},
+			want: [][]string{{"r7", "r6", "r4", "r3"}},
+		},
+		{
+			name: "unknown imei",
+			q:    Query{IMEI: "999"},
+			want: [][]string{{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := queryAll(t, c, tt.q); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("pages = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryInvalidCursor(t *testing.T) {
+	c := openTestCatalog(t)
+	page, err := c.Query(Query{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		q    Query
+	}{
+		{"not base64", Query{Cursor: "***"}},
+		{"time cursor used with an imei", Query{IMEI: "111", Cursor: page.NextCursor}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := c.Query(tt.q); !errors.Is(err, ErrInvalidCursor) {
+				t.Fatalf("error = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestPutReplacesIndexes(t *testing.T) {
+	c := openTestCatalog(t)
+	rec, found, err := c.FindByKey("r3.mp4")
+	if err != nil || !found {
+		t.Fatalf("FindByKey = %v, %v", found, err)
+	}
+
+	// Atualizar no lugar move o registro para o novo horário e IMEI sem deixar entradas antigas
+	rec.IMEI = "222"
+	rec.CaptureTime = rec.CaptureTime.Add(time.Hour)
+	if err := c.Put(rec); err != nil {
+		t.Fatal(err)
+	}
+	for imei, want := range map[string][]string{"111": {"r7", "r6", "r4", "r1"}, "222": {"r3", "r5", "r2"}} {
+		got := queryAll(t, c, Query{IMEI: imei})
+		if !reflect.DeepEqual(got, [][]string{want}) {
+			t.Fatalf("imei %s: %v, want %v", imei, got, want)
+		}
+	}
+
+	if ok, err := c.Delete("r3"); !ok || err != nil {
+		t.Fatalf("Delete = %v, %v", ok, err)
+	}
+	if _, found, _ := c.FindByKey("r3.mp4"); found {
+		t.Fatalf("record %s still found by key after delete", rec.ID)
+	}
+}
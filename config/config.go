@@ -1,12 +1,47 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
+
+	"dvr-upload/secrets"
 )
 
+// PriorityClass define uma classe de prioridade de processamento. Um job pertence à
+// classe se atender todas as regras preenchidas (tipo, canal e regex do nome).
+type PriorityClass struct {
+	Name     string   `json:"name"`
+	Types    []string `json:"types,omitempty"`
+	Channels []string `json:"channels,omitempty"`
+	Pattern  string   `json:"pattern,omitempty"`
+	Workers  int      `json:"workers"`
+}
+
+// WebhookEndpoint é um destino HTTP de eventos (um por tenant). Filtros vazios aceitam tudo;
+// IMEIs aceita prefixos terminados em "*".
+type WebhookEndpoint struct {
+	Name   string   `json:"name"`
+	URL    string   `json:"url"`
+	Secret string   `json:"secret,omitempty"`
+	IMEIs  []string `json:"imeis,omitempty"`
+	Types  []string `json:"types,omitempty"`
+	Events []string `json:"events,omitempty"`
+}
+
+// TranscodeProfile é um perfil de encode adicional (ou que substitui um embutido de mesmo nome)
+// usado pelo comando de reprocessamento.
+type TranscodeProfile struct {
+	Name      string `json:"name"`
+	CRF       int    `json:"crf"`
+	Preset    string `json:"preset"`
+	MaxHeight int    `json:"max_height,omitempty"`
+}
+
 type Config struct {
 	SecretKey            string
 	EnableSecret         bool
@@ -18,6 +53,7 @@ type Config struct {
 	EnableS3Upload       bool
 	EnableRabbitMQ       bool
 	LogFilePath          string
+	ListenAddr           string
 
 	// S3 Configuration (OCI Compatibility)
 	S3Bucket       string
@@ -28,70 +64,512 @@ type Config struct {
 	S3UsePathStyle bool
 
 	// RabbitMQ Configuration
-	RabbitMQURL      string
-	RabbitMQQueue    string
-	RabbitMQExchange string
-	RabbitMQTtl      int
+	RabbitMQURL         string
+	RabbitMQQueue       string
+	RabbitMQExchange    string
+	RabbitMQTtl         int
+	RabbitMQBundleQueue string
+
+	// Argumentos das filas de eventos: tipo, dead-letter e limite de tamanho
+	RabbitMQQueueType            string
+	RabbitMQDeadLetterExchange   string
+	RabbitMQDeadLetterRoutingKey string
+	RabbitMQDeadLetterQueue      string
+	RabbitMQMaxLength            int
+	RabbitMQOverflow             string
+	RabbitMQStrictDeclare        bool
+
+	// Eventos de ciclo de vida (recebido, rejeitado, falha de processamento, publicado)
+	EnableLifecycleEvents       bool
+	RabbitMQLifecycleRoutingKey string
 
 	// Workers Configuration
 	MaxConcurrentWorkers int
 	EnableCompression    bool
+
+	// Rate limiting por dispositivo (0 desativa)
+	RateLimitUploadsPerMinute int
+	RateLimitBytesPerHour     int64
+	EnableFairScheduling      bool
+
+	// Classes de prioridade (JSON), na ordem de prioridade
+	PriorityClasses []PriorityClass
+
+	// Catálogo de mídias (bbolt)
+	EnableCatalog bool
+	CatalogPath   string
+
+	// Endpoints autenticados e URLs assinadas
+	AdminTokens      []string
+	DeviceSecrets    map[string]string // secret de assinatura por IMEI (fallback: SecretKey)
+	URLSigningKey    string
+	PresignExpiry    int // segundos
+	PresignMaxExpiry int // segundos
+	PublicBaseURL    string
+
+	// API de admin (endereço separado do :23010 das câmeras) e nível de log inicial
+	AdminListenAddr string
+	LogLevel        string
+
+	// Streaming de atividade (SSE) na API de admin
+	StreamMaxConnections          int
+	StreamMaxConnectionsPerClient int
+
+	// Verificações de dependências em segundo plano (/health e /readyz)
+	ProbeInterval int // segundos
+	ProbeTimeout  int // segundos
+
+	// Tempo máximo para concluir requisições e descarregar filas/traces ao receber SIGTERM
+	ShutdownTimeout int // segundos
+
+	// Tracing (OpenTelemetry)
+	TracingExporter     string // none, stdout ou otlp
+	TracingOTLPEndpoint string
+	TracingServiceName  string
+	TracingSampleRatio  float64
+
+	// Empacotamento HLS
+	EnableHLS          bool
+	HLSTypes           []string
+	HLSMinDuration     int // segundos
+	HLSSegmentDuration int // segundos
+	HLSSegmentFormat   string
+	HLSRenditions      []string
+
+	// Agrupamento de canais de um mesmo alarme em bundles
+	EnableBundles          bool
+	BundleTypes            []string
+	BundleWindow           int // segundos
+	BundleExpectedChannels []string
+	EnableBundleMosaic     bool
+
+	// Watermark (aplicado no passo de compressão)
+	EnableWatermark       bool
+	WatermarkTemplate     string
+	WatermarkPosition     string
+	WatermarkFontFile     string
+	WatermarkFontSize     int
+	WatermarkFontColor    string
+	WatermarkBoxColor     string
+	WatermarkLogoPath     string
+	WatermarkLogoPosition string
+	WatermarkTimeFormat   string
+	WatermarkTimezone     string
+	WatermarkRequired     bool // falha no encode com watermark descarta a mídia em vez de enviar o original
+
+	// Cadeia de custódia (manifestos assinados com Ed25519)
+	EnableCustody     bool
+	CustodySigningKey string
+
+	// Pipeline de snapshots (JPEG)
+	EnableImagePipeline bool
+	ImageVariants       []ImageVariant
+	ImageEXIFMode       string // keep, strip ou normalize
+	ImageJPEGQuality    int
+	ImageExtraFormats   []string // webp, avif
+	ImageExtraQuality   int
+	ImageRejectInvalid  bool
+
+	// Decodificação do bloco raw dos snapshots
+	EnableRawDecoding bool
+	RawDecoderFamily  string // família de firmware padrão quando o upload não informa "firmware"
+	RawRejectInvalid  bool
+
+	// Telemetria (lat/lon/speed/heading/alarm/meta) embutida como faixa de legenda no MP4
+	EnableTelemetrySubtitle bool
+
+	// Webhooks (sink HTTP de eventos)
+	EnableWebhooks      bool
+	WebhookEndpoints    []WebhookEndpoint
+	WebhookMaxAttempts  int
+	WebhookBackoff      int // segundos, dobra a cada tentativa
+	WebhookTimeout      int // segundos
+	WebhookWorkers      int
+	WebhookQueueSize    int
+	WebhookDeliveryLogs int
+
+	// NATS JetStream
+	EnableNATS          bool
+	NATSURL             string
+	NATSCredsFile       string
+	NATSSubjectTemplate string // {kind}, {imei}, {type}
+	NATSStream          string
+	NATSStreamSubjects  []string
+
+	// Kafka
+	EnableKafka        bool
+	KafkaBrokers       []string
+	KafkaTopicTemplate string // {kind}, {imei}, {type}
+
+	// CloudEvents 1.0 (vazio mantém o JSON puro)
+	CloudEventsMode       string // binary ou structured
+	CloudEventsSource     string
+	CloudEventsTypePrefix string
+
+	// Fila de comandos (reprocessamento, republicação, exclusão, miniaturas)
+	EnableCommands             bool
+	RabbitMQCommandQueue       string
+	RabbitMQCommandResultQueue string
+	CommandWorkers             int
+	TranscodeProfiles          []TranscodeProfile
+}
+
+// ImageVariant é uma variante redimensionada de snapshot (maior lado com até MaxSize pixels).
+type ImageVariant struct {
+	Name    string
+	MaxSize int
 }
 
-func LoadConfig() *Config {
+// load monta a Config a partir das chaves resolvidas por s.
+func (s *source) load() *Config {
 	// Construct RabbitMQ URL from individual components
-	rmqHost := getEnv("RABBITMQ_HOST", "localhost")
-	rmqPort := getEnv("RABBITMQ_PORT", "5672")
-	rmqUser := getEnv("RABBITMQ_USER", "guest")
-	rmqPass := getEnv("RABBITMQ_PASSWORD", "guest")
+	rmqHost := s.getEnv("RABBITMQ_HOST", "localhost")
+	rmqPort := s.getEnv("RABBITMQ_PORT", "5672")
+	rmqUser := s.getEnv("RABBITMQ_USER", "guest")
+	rmqPass := s.getSecret("RABBITMQ_PASSWORD", "guest")
 
-	rmqURL := fmt.Sprintf("amqp://%s:%s@%s:%s/", rmqUser, rmqPass, rmqHost, rmqPort)
+	// Secrets vindos de arquivo costumam ter caracteres reservados em URLs (@, /, :)
+	rmqURL := (&url.URL{Scheme: "amqp", User: url.UserPassword(rmqUser, rmqPass), Host: net.JoinHostPort(rmqHost, rmqPort), Path: "/"}).String()
+	secretKey := s.getSecret("SECRET_KEY", "jimidvr@123!443")
+
+	// Sem bucket configurado o upload para o object storage fica desligado por padrão (deploy só local)
+	s3Bucket := s.getEnv("OCI_BUCKET_MEDIA", "")
+	s3Default := "false"
+	if s3Bucket != "" {
+		s3Default = "true"
+	}
 
 	return &Config{
-		SecretKey:            getEnv("SECRET_KEY", "jimidvr@123!443"),
-		EnableSecret:         getEnv("ENABLE_SECRET", "true") == "true",
-		VideoPath:            getEnv("LOCAL_VIDEO_PATH", "/data/upload"),
-		BackupPath:           getEnv("BACKUP_VIDEO_PATH", "/data/dvr-upload-backup"),
-		DisasterRecoveryMode: getEnv("DISASTER_RECOVERY_MODE", "false") == "true",
-		EnableLocalStorage:   getEnv("ENABLE_LOCAL_STORAGE", "true") == "true",
-		EnableTsToMp4:        getEnv("ENABLE_TS_TO_MP4", "true") == "true",
-		EnableS3Upload:       getEnv("ENABLE_S3_UPLOAD", "true") == "true",
-		EnableRabbitMQ:       getEnv("ENABLE_RABBITMQ", "true") == "true",
-		LogFilePath:          "/app/dvr-upload/logs/server.log",
-
-		S3Bucket:       getEnv("OCI_BUCKET_MEDIA", ""),
-		S3Region:       getEnv("OCI_REGION", "sa-saopaulo-1"),
-		S3Endpoint:     getEnv("OCI_ENDPOINT", ""),
-		S3AccessKey:    getEnv("OCI_ACCESS_KEY_ID", ""),
-		S3SecretKey:    getEnv("OCI_SECRET_ACCESS_KEY", ""),
-		S3UsePathStyle: strings.EqualFold(getEnv("OCI_USE_PATH_STYLE_ENDPOINT", "true"), "true"),
-
-		RabbitMQURL:      rmqURL,
-		RabbitMQQueue:    getEnv("RABBITMQ_QUEUE", "dvr_upload_events"),
-		RabbitMQExchange: getEnv("RABBITMQ_EXCHANGE", "iothub-webhook"),
-		RabbitMQTtl:      getEnvAsInt("RABBITMQ_TTL", 300000),
-
-		MaxConcurrentWorkers: getEnvAsInt("MAX_CONCURRENT_WORKERS", 6),
-		EnableCompression:    getEnv("ENABLE_COMPRESSION", "true") == "true",
-	}
-}
-
-func getEnv(key, def string) string {
-	val := os.Getenv(key)
-	if val == "" {
+		SecretKey:            secretKey,
+		EnableSecret:         s.getEnv("ENABLE_SECRET", "true") == "true",
+		VideoPath:            s.getEnv("LOCAL_VIDEO_PATH", "/data/upload"),
+		BackupPath:           s.getEnv("BACKUP_VIDEO_PATH", "/data/dvr-upload-backup"),
+		DisasterRecoveryMode: s.getEnv("DISASTER_RECOVERY_MODE", "false") == "true",
+		EnableLocalStorage:   s.getEnv("ENABLE_LOCAL_STORAGE", "true") == "true",
+		EnableTsToMp4:        s.getEnv("ENABLE_TS_TO_MP4", "true") == "true",
+		EnableS3Upload:       s.getEnv("ENABLE_S3_UPLOAD", s3Default) == "true",
+		EnableRabbitMQ:       s.getEnv("ENABLE_RABBITMQ", "true") == "true",
+		LogFilePath:          s.getEnv("LOG_FILE_PATH", "/app/dvr-upload/logs/server.log"),
+		ListenAddr:           s.getEnv("LISTEN_ADDR", ":23010"),
+
+		S3Bucket:       s3Bucket,
+		S3Region:       s.getEnv("OCI_REGION", "sa-saopaulo-1"),
+		S3Endpoint:     s.getEnv("OCI_ENDPOINT", ""),
+		S3AccessKey:    s.getSecret("OCI_ACCESS_KEY_ID", ""),
+		S3SecretKey:    s.getSecret("OCI_SECRET_ACCESS_KEY", ""),
+		S3UsePathStyle: strings.EqualFold(s.getEnv("OCI_USE_PATH_STYLE_ENDPOINT", "true"), "true"),
+
+		RabbitMQURL:         rmqURL,
+		RabbitMQQueue:       s.getEnv("RABBITMQ_QUEUE", "dvr_upload_events"),
+		RabbitMQExchange:    s.getEnv("RABBITMQ_EXCHANGE", "iothub-webhook"),
+		RabbitMQTtl:         s.getEnvAsInt("RABBITMQ_TTL", 300000),
+		RabbitMQBundleQueue: s.getEnv("RABBITMQ_BUNDLE_QUEUE", "dvr_event_bundles"),
+
+		RabbitMQQueueType:            s.getEnv("RABBITMQ_QUEUE_TYPE", "classic"),
+		RabbitMQDeadLetterExchange:   s.getEnv("RABBITMQ_DLX_EXCHANGE", ""),
+		RabbitMQDeadLetterRoutingKey: s.getEnv("RABBITMQ_DLX_ROUTING_KEY", ""),
+		RabbitMQDeadLetterQueue:      s.getEnv("RABBITMQ_DEAD_LETTER_QUEUE", ""),
+		RabbitMQMaxLength:            s.getEnvAsInt("RABBITMQ_MAX_LENGTH", 0),
+		RabbitMQOverflow:             s.getEnv("RABBITMQ_OVERFLOW", ""),
+		RabbitMQStrictDeclare:        s.getEnv("RABBITMQ_STRICT_DECLARE", "false") == "true",
+
+		EnableLifecycleEvents:       s.getEnv("ENABLE_LIFECYCLE_EVENTS", "false") == "true",
+		RabbitMQLifecycleRoutingKey: s.getEnv("RABBITMQ_LIFECYCLE_ROUTING_KEY", "dvr_upload_lifecycle"),
+
+		MaxConcurrentWorkers: s.getEnvAsInt("MAX_CONCURRENT_WORKERS", 6),
+		EnableCompression:    s.getEnv("ENABLE_COMPRESSION", "true") == "true",
+
+		RateLimitUploadsPerMinute: s.getEnvAsInt("RATE_LIMIT_UPLOADS_PER_MINUTE", 0),
+		RateLimitBytesPerHour:     s.getEnvAsInt64("RATE_LIMIT_BYTES_PER_HOUR", 0),
+		EnableFairScheduling:      s.getEnv("ENABLE_FAIR_SCHEDULING", "true") == "true",
+
+		PriorityClasses: s.getEnvAsPriorityClasses("PRIORITY_CLASSES"),
+
+		EnableCatalog: s.getEnv("ENABLE_CATALOG", "true") == "true",
+		CatalogPath:   s.getEnv("CATALOG_PATH", "/app/dvr-upload/data/catalog.db"),
+
+		AdminTokens:      s.getSecretAsList("ADMIN_TOKENS"),
+		DeviceSecrets:    s.getEnvAsDeviceSecrets("DEVICE_SECRETS"),
+		URLSigningKey:    s.getSecret("URL_SIGNING_KEY", ""),
+		PresignExpiry:    s.getEnvAsInt("PRESIGN_EXPIRY_SECONDS", 900),
+		PresignMaxExpiry: s.getEnvAsInt("PRESIGN_MAX_EXPIRY_SECONDS", 604800),
+		PublicBaseURL:    strings.TrimRight(s.getEnv("PUBLIC_BASE_URL", ""), "/"),
+
+		AdminListenAddr: s.getEnv("ADMIN_LISTEN_ADDR", ":23011"),
+		LogLevel:        s.getEnv("LOG_LEVEL", "info"),
+
+		StreamMaxConnections:          s.getEnvAsInt("SSE_MAX_CONNECTIONS", 20),
+		StreamMaxConnectionsPerClient: s.getEnvAsInt("SSE_MAX_CONNECTIONS_PER_CLIENT", 3),
+
+		ProbeInterval: max(s.getEnvAsInt("PROBE_INTERVAL_SECONDS", 15), 1),
+		ProbeTimeout:  max(s.getEnvAsInt("PROBE_TIMEOUT_SECONDS", 5), 1),
+
+		ShutdownTimeout: s.getEnvAsInt("SHUTDOWN_TIMEOUT_SECONDS", 20),
+
+		TracingExporter:     strings.ToLower(s.getEnv("TRACING_EXPORTER", "none")),
+		TracingOTLPEndpoint: s.getEnv("TRACING_OTLP_ENDPOINT", ""),
+		TracingServiceName:  s.getEnv("TRACING_SERVICE_NAME", "dvr-upload"),
+		TracingSampleRatio:  s.getEnvAsFloat("TRACING_SAMPLE_RATIO", 1),
+
+		EnableHLS:          s.getEnv("ENABLE_HLS", "false") == "true",
+		HLSTypes:           s.getEnvAsList("HLS_TYPES", "F"),
+		HLSMinDuration:     s.getEnvAsInt("HLS_MIN_DURATION_SECONDS", 30),
+		HLSSegmentDuration: s.getEnvAsInt("HLS_SEGMENT_DURATION", 6),
+		HLSSegmentFormat:   strings.ToLower(s.getEnv("HLS_SEGMENT_FORMAT", "mpegts")),
+		HLSRenditions:      s.getEnvAsList("HLS_RENDITIONS", ""),
+
+		EnableBundles:          s.getEnv("ENABLE_BUNDLES", "false") == "true",
+		BundleTypes:            s.getEnvAsList("BUNDLE_TYPES", "I"),
+		BundleWindow:           s.getEnvAsInt("BUNDLE_WINDOW_SECONDS", 120),
+		BundleExpectedChannels: s.getEnvAsList("BUNDLE_EXPECTED_CHANNELS", ""),
+		EnableBundleMosaic:     s.getEnv("ENABLE_BUNDLE_MOSAIC", "false") == "true",
+
+		EnableWatermark:       s.getEnv("ENABLE_WATERMARK", "false") == "true",
+		WatermarkTemplate:     s.getEnv("WATERMARK_TEMPLATE", "IMEI {imei} CH{channel} {datetime}"),
+		WatermarkPosition:     s.getEnv("WATERMARK_POSITION", "bottom-left"),
+		WatermarkFontFile:     s.getEnv("WATERMARK_FONT_FILE", "/usr/share/fonts/dejavu/DejaVuSans.ttf"),
+		WatermarkFontSize:     s.getEnvAsInt("WATERMARK_FONT_SIZE", 24),
+		WatermarkFontColor:    s.getEnv("WATERMARK_FONT_COLOR", "white"),
+		WatermarkBoxColor:     s.getEnv("WATERMARK_BOX_COLOR", "black@0.5"),
+		WatermarkLogoPath:     s.getEnv("WATERMARK_LOGO_PATH", ""),
+		WatermarkLogoPosition: s.getEnv("WATERMARK_LOGO_POSITION", "top-right"),
+		WatermarkTimeFormat:   s.getEnv("WATERMARK_TIME_FORMAT", "2006-01-02 15:04:05"),
+		WatermarkTimezone:     s.getEnv("WATERMARK_TIMEZONE", "UTC"),
+		WatermarkRequired:     s.getEnv("WATERMARK_REQUIRED", "true") == "true",
+
+		EnableCustody:     s.getEnv("ENABLE_CUSTODY", "false") == "true",
+		CustodySigningKey: s.getSecret("CUSTODY_SIGNING_KEY", ""),
+
+		EnableImagePipeline: s.getEnv("ENABLE_IMAGE_PIPELINE", "false") == "true",
+		ImageVariants:       s.getEnvAsImageVariants("IMAGE_VARIANTS", "thumb:320,medium:1280"),
+		ImageEXIFMode:       strings.ToLower(s.getEnv("IMAGE_EXIF_MODE", "normalize")),
+		ImageJPEGQuality:    s.getEnvAsInt("IMAGE_JPEG_QUALITY", 85),
+		ImageExtraFormats:   s.getEnvAsList("IMAGE_EXTRA_FORMATS", ""),
+		ImageExtraQuality:   s.getEnvAsInt("IMAGE_EXTRA_QUALITY", 75),
+		ImageRejectInvalid:  s.getEnv("IMAGE_REJECT_INVALID", "true") == "true",
+
+		EnableRawDecoding: s.getEnv("ENABLE_RAW_DECODING", "false") == "true",
+		RawDecoderFamily:  strings.ToLower(s.getEnv("RAW_DECODER_FAMILY", "jimi")),
+		RawRejectInvalid:  s.getEnv("RAW_REJECT_INVALID", "false") == "true",
+
+		EnableTelemetrySubtitle: s.getEnv("ENABLE_TELEMETRY_SUBTITLE", "false") == "true",
+
+		EnableWebhooks:      s.getEnv("ENABLE_WEBHOOKS", "false") == "true",
+		WebhookEndpoints:    s.getEnvAsWebhookEndpoints("WEBHOOK_ENDPOINTS", s.getEnv("WEBHOOK_URL", ""), s.getSecret("WEBHOOK_SECRET", "")),
+		WebhookMaxAttempts:  s.getEnvAsInt("WEBHOOK_MAX_ATTEMPTS", 6),
+		WebhookBackoff:      s.getEnvAsInt("WEBHOOK_BACKOFF_SECONDS", 2),
+		WebhookTimeout:      s.getEnvAsInt("WEBHOOK_TIMEOUT_SECONDS", 10),
+		WebhookWorkers:      s.getEnvAsInt("WEBHOOK_WORKERS", 4),
+		WebhookQueueSize:    s.getEnvAsInt("WEBHOOK_QUEUE_SIZE", 1000),
+		WebhookDeliveryLogs: s.getEnvAsInt("WEBHOOK_DELIVERY_LOG_SIZE", 1000),
+
+		EnableNATS:          s.getEnv("ENABLE_NATS", "false") == "true",
+		NATSURL:             s.getEnv("NATS_URL", "nats://localhost:4222"),
+		NATSCredsFile:       s.getEnv("NATS_CREDS_FILE", ""),
+		NATSSubjectTemplate: s.getEnv("NATS_SUBJECT_TEMPLATE", "dvr.{kind}.{type}.{imei}"),
+		NATSStream:          s.getEnv("NATS_STREAM", "DVR_EVENTS"),
+		NATSStreamSubjects:  s.getEnvAsList("NATS_STREAM_SUBJECTS", "dvr.>"),
+
+		EnableKafka:        s.getEnv("ENABLE_KAFKA", "false") == "true",
+		KafkaBrokers:       s.getEnvAsList("KAFKA_BROKERS", "localhost:9092"),
+		KafkaTopicTemplate: s.getEnv("KAFKA_TOPIC_TEMPLATE", "dvr.{kind}"),
+
+		CloudEventsMode:       strings.ToLower(s.getEnv("CLOUDEVENTS_MODE", "")),
+		CloudEventsSource:     s.getEnv("CLOUDEVENTS_SOURCE", "/dvr-upload/"+hostname()),
+		CloudEventsTypePrefix: s.getEnv("CLOUDEVENTS_TYPE_PREFIX", "com.jimi.dvr"),
+
+		EnableCommands:             s.getEnv("ENABLE_COMMANDS", "false") == "true",
+		RabbitMQCommandQueue:       s.getEnv("RABBITMQ_COMMAND_QUEUE", "dvr_commands"),
+		RabbitMQCommandResultQueue: s.getEnv("RABBITMQ_COMMAND_RESULT_QUEUE", "dvr_command_results"),
+		CommandWorkers:             s.getEnvAsInt("COMMAND_WORKERS", 2),
+		TranscodeProfiles:          s.getEnvAsTranscodeProfiles("TRANSCODE_PROFILES"),
+	}
+}
+
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return "unknown"
+	}
+	return name
+}
+
+func (s *source) getEnv(key, def string) string {
+	val, ok := s.find(key)
+	if !ok {
 		return def
 	}
 	return val
 }
 
-func getEnvAsInt(key string, def int) int {
-	valStr := os.Getenv(key)
+// getSecret lê um secret de KEY ou, no formato dos Docker/Kubernetes secrets, do arquivo indicado
+// em KEY_FILE. O valor de KEY pode ser uma referência (file://, env://, vault://) resolvida pelo
+// registry de secrets.
+func (s *source) getSecret(key, def string) string {
+	val, fromFile, ok := s.findSecret(key)
+	if !ok {
+		return def
+	}
+	if fromFile {
+		return val
+	}
+	return s.resolve(key, val)
+}
+
+// getSecretAsList lê uma lista de secrets separada por vírgulas ou linhas (um token por linha no
+// arquivo de KEY_FILE). Cada item pode ser uma referência.
+func (s *source) getSecretAsList(key string) []string {
+	val, _, _ := s.findSecret(key)
+	var values []string
+	for _, v := range strings.FieldsFunc(val, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, s.resolve(key, v))
+		}
+	}
+	return values
+}
+
+// findSecret retorna o valor bruto de KEY ou o conteúdo do arquivo de KEY_FILE.
+func (s *source) findSecret(key string) (val string, fromFile, ok bool) {
+	val, ok = s.find(key)
+	path := s.lookup(key + "_FILE")
+	if path == "" {
+		return val, false, ok
+	}
+	if ok {
+		s.errs = append(s.errs, fmt.Errorf("%s and %s_FILE are both set, use only one", key, key))
+	}
+	secret, err := secrets.ReadFile(path)
+	if err != nil {
+		s.errs = append(s.errs, fmt.Errorf("%s_FILE: %w", key, err))
+		return "", true, false
+	}
+	return secret, true, true
+}
+
+func (s *source) getEnvAsInt(key string, def int) int {
+	valStr := s.lookup(key)
 	if valStr == "" {
 		return def
 	}
 	val, err := strconv.Atoi(valStr)
 	if err != nil {
+		s.invalid(key, valStr, "an integer")
+		return def
+	}
+	return val
+}
+
+func (s *source) getEnvAsFloat(key string, def float64) float64 {
+	valStr := s.lookup(key)
+	if valStr == "" {
+		return def
+	}
+	val, err := strconv.ParseFloat(valStr, 64)
+	if err != nil {
+		s.invalid(key, valStr, "a number")
+		return def
+	}
+	return val
+}
+
+func (s *source) getEnvAsList(key, def string) []string {
+	var values []string
+	for _, v := range strings.Split(s.getEnv(key, def), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func (s *source) getEnvAsInt64(key string, def int64) int64 {
+	valStr := s.lookup(key)
+	if valStr == "" {
+		return def
+	}
+	val, err := strconv.ParseInt(valStr, 10, 64)
+	if err != nil {
+		s.invalid(key, valStr, "an integer")
 		return def
 	}
 	return val
 }
+
+func (s *source) getEnvAsPriorityClasses(key string) []PriorityClass {
+	valStr := s.lookup(key)
+	if valStr == "" {
+		return nil
+	}
+	var classes []PriorityClass
+	if err := json.Unmarshal([]byte(valStr), &classes); err != nil {
+		s.invalid(key, valStr, "a JSON list of priority classes")
+		return nil
+	}
+	return classes
+}
+
+func (s *source) getEnvAsTranscodeProfiles(key string) []TranscodeProfile {
+	valStr := s.lookup(key)
+	if valStr == "" {
+		return nil
+	}
+	var profiles []TranscodeProfile
+	if err := json.Unmarshal([]byte(valStr), &profiles); err != nil {
+		s.invalid(key, valStr, "a JSON list of transcode profiles")
+		return nil
+	}
+	return profiles
+}
+
+// getEnvAsDeviceSecrets lê o objeto JSON {"<imei>": "<secret>"} com os secrets por dispositivo.
+// O objeto inteiro e cada secret podem ser referências (ver getSecret).
+func (s *source) getEnvAsDeviceSecrets(key string) map[string]string {
+	valStr := s.getSecret(key, "")
+	if valStr == "" {
+		return nil
+	}
+	var secrets map[string]string
+	if err := json.Unmarshal([]byte(valStr), &secrets); err != nil {
+		s.invalid(key, "<redacted>", `a JSON object {"<imei>": "<secret>"}`)
+		return nil
+	}
+	for imei, secret := range secrets {
+		secrets[imei] = s.resolve(key+"["+imei+"]", secret)
+	}
+	return secrets
+}
+
+// getEnvAsWebhookEndpoints lê a lista JSON de endpoints por tenant. WEBHOOK_URL/WEBHOOK_SECRET
+// definem um endpoint "default" que recebe todos os eventos.
+func (s *source) getEnvAsWebhookEndpoints(key, defaultURL, defaultSecret string) []WebhookEndpoint {
+	var endpoints []WebhookEndpoint
+	if valStr := s.getSecret(key, ""); valStr != "" {
+		if err := json.Unmarshal([]byte(valStr), &endpoints); err != nil {
+			s.invalid(key, "<redacted>", "a JSON list of webhook endpoints")
+			endpoints = nil
+		}
+	}
+	for i := range endpoints {
+		endpoints[i].Secret = s.resolve(fmt.Sprintf("%s[%d].secret", key, i), endpoints[i].Secret)
+	}
+	if defaultURL != "" {
+		endpoints = append(endpoints, WebhookEndpoint{Name: "default", URL: defaultURL, Secret: defaultSecret})
+	}
+	return endpoints
+}
+
+// getEnvAsImageVariants lê variantes no formato "nome:tamanho,nome:tamanho".
+func (s *source) getEnvAsImageVariants(key, def string) []ImageVariant {
+	var variants []ImageVariant
+	for _, item := range s.getEnvAsList(key, def) {
+		name, size, ok := strings.Cut(item, ":")
+		maxSize, err := strconv.Atoi(strings.TrimSpace(size))
+		if !ok || err != nil || maxSize <= 0 || strings.TrimSpace(name) == "" {
+			s.invalid(key, item, `"name:size" with a positive size`)
+			continue
+		}
+		variants = append(variants, ImageVariant{Name: strings.TrimSpace(name), MaxSize: maxSize})
+	}
+	return variants
+}
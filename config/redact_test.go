@@ -0,0 +1,85 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRedacted(t *testing.T) {
+	cfg := &Config{
+		SecretKey:        "device-secret",
+		EnableSecret:     true,
+		S3AccessKey:      "AKIA",
+		S3SecretKey:      "s3-secret",
+		RabbitMQURL:      "amqp://dvr:rabbit-pass@mq:5672/",
+		NATSURL:          "nats://mq:4222",
+		AdminTokens:      []string{"admin-token"},
+		DeviceSecrets:    map[string]string{"123456789012": "per-device"},
+		WebhookEndpoints: []WebhookEndpoint{{Name: "erp", URL: "https://erp.example.com/hook", Secret: "hook-secret"}},
+		VideoPath:        "/data/videos",
+	}
+	out := cfg.Redacted()
+
+	data, err := json.Marshal(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"device-secret", "AKIA", "s3-secret", "rabbit-pass", "admin-token", "per-device", "hook-secret"} {
+		if bytes.Contains(data, []byte(secret)) {
+			t.Fatalf("%q not redacted: %s", secret, data)
+		}
+	}
+
+	checks := map[string]any{
+		"SecretKey":         redacted,
+		"EnableSecret":      true, // booleanos com nome de secret não são ocultados
+		"S3AccessKey":       redacted,
+		"URLSigningKey":     "", // vazio continua vazio: mostra que o secret não está configurado
+		"RabbitMQURL":       "amqp://dvr:xxxxx@mq:5672/",
+		"NATSURL":           "nats://mq:4222",
+		"VideoPath":         "/data/videos",
+		"CustodySigningKey": "",
+	}
+	for field, want := range checks {
+		if got := out[field]; got != want {
+			t.Errorf("%s = %v, want %v", field, got, want)
+		}
+	}
+	endpoint := out["WebhookEndpoints"].([]any)[0].(map[string]any)
+	if endpoint["Secret"] != redacted || endpoint["URL"] != "https://erp.example.com/hook" {
+		t.Fatalf("webhook endpoint = %v", endpoint)
+	}
+}
+
+func TestRedactLogAttr(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{ReplaceAttr: RedactLogAttr}))
+	logger.Info("test",
+		"secret_key", "s3-secret",
+		"admin_token", "admin-token",
+		"enable_secret", true,
+		"url", "amqp://dvr:rabbit-pass@mq:5672/",
+		"path", "/data/videos",
+	)
+	line := buf.String()
+	for _, want := range []string{"secret_key=[REDACTED]", "admin_token=[REDACTED]", "enable_secret=true", "url=amqp://dvr:xxxxx@mq:5672/", "path=/data/videos"} {
+		if !strings.Contains(line, want) {
+			t.Fatalf("log line %q does not contain %q", line, want)
+		}
+	}
+}
+
+func TestChangedAndOverlay(t *testing.T) {
+	base := &Config{SecretKey: "old", VideoPath: "/a", AdminTokens: []string{"x"}}
+	next := &Config{SecretKey: "new", VideoPath: "/b", AdminTokens: []string{"x"}}
+	if got := strings.Join(base.Changed(next), ","); got != "SecretKey,VideoPath" {
+		t.Fatalf("Changed() = %s", got)
+	}
+	merged := base.Overlay(next, map[string]bool{"SecretKey": true})
+	if merged.SecretKey != "new" || merged.VideoPath != "/a" || base.SecretKey != "old" {
+		t.Fatalf("Overlay() = %+v (base %+v)", merged, base)
+	}
+}
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"dvr-upload/secrets"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// jsonKeys são as chaves cujo valor é JSON; no arquivo podem ser escritas como estruturas
+// YAML/TOML, que são serializadas de volta para JSON.
+var jsonKeys = map[string]bool{
+	"PRIORITY_CLASSES":   true,
+	"TRANSCODE_PROFILES": true,
+	"DEVICE_SECRETS":     true,
+	"WEBHOOK_ENDPOINTS":  true,
+}
+
+// secretTimeout limita cada consulta a um gerenciador de secrets durante o carregamento.
+const secretTimeout = 5 * time.Second
+
+// source resolve as chaves de configuração: a variável de ambiente tem precedência sobre o
+// arquivo. Erros de conversão são acumulados em vez de cair silenciosamente no padrão.
+type source struct {
+	file     map[string]string
+	seen     map[string]bool
+	errs     []error
+	registry *secrets.Registry
+}
+
+func (s *source) lookup(key string) string {
+	val, _ := s.find(key)
+	return val
+}
+
+// find indica também se a chave foi definida. Variáveis de ambiente vazias contam como não
+// definidas; no arquivo, uma chave vazia é um valor explícito.
+func (s *source) find(key string) (string, bool) {
+	if s.seen == nil {
+		s.seen = make(map[string]bool)
+	}
+	s.seen[key] = true
+	if val := os.Getenv(key); val != "" {
+		return val, true
+	}
+	val, ok := s.file[key]
+	return val, ok
+}
+
+// resolve devolve o secret referenciado por value (file://, env://, vault://) ou o próprio value.
+// O erro cita a chave e a referência, nunca o conteúdo do secret.
+func (s *source) resolve(key, value string) string {
+	if s.registry == nil {
+		return value
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), secretTimeout)
+	defer cancel()
+	secret, err := s.registry.Resolve(ctx, value)
+	if err != nil {
+		s.errs = append(s.errs, fmt.Errorf("%s: %w", key, err))
+		return ""
+	}
+	return secret
+}
+
+func (s *source) invalid(key, value, expected string) {
+	s.errs = append(s.errs, fmt.Errorf("%s: invalid value %q, expected %s", key, value, expected))
+}
+
+// Load lê a configuração das variáveis de ambiente, usando o arquivo path (YAML ou TOML, opcional)
+// como base, e valida o resultado. O erro lista todos os problemas encontrados.
+func Load(path string) (*Config, error) {
+	s := &source{}
+	if path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		s.file = values
+	}
+
+	// O token do Vault pode vir de VAULT_TOKEN_FILE ou de uma referência file:// / env://: ele é lido
+	// com um registry de bootstrap, ainda sem o Vault, e só então o registry completo é montado
+	bootstrap := secrets.NewRegistry(secrets.VaultOptions{})
+	s.registry = bootstrap
+	vaultToken := s.getSecret("VAULT_TOKEN", "")
+	s.registry = secrets.NewRegistry(secrets.VaultOptions{
+		Addr:    s.lookup("VAULT_ADDR"),
+		Token:   vaultToken,
+		Timeout: secretTimeout,
+	})
+
+	cfg := s.load()
+
+	var unknown []string
+	for key := range s.file {
+		if !s.seen[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		s.errs = append(s.errs, fmt.Errorf("%s: unknown setting in %s", key, path))
+	}
+
+	if err := errors.Join(append(s.errs, cfg.Validate())...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// readFile lê o arquivo de configuração e achata as seções em chaves no formato das variáveis de
+// ambiente: "rabbitmq: {host: x}" vira RABBITMQ_HOST=x.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	var tree map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format (use .yaml, .yml or .toml)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	if err := flatten(values, "", tree); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+func flatten(values map[string]string, prefix string, tree map[string]any) error {
+	for name, value := range tree {
+		key := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+		if jsonKeys[key] {
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			values[key] = string(encoded)
+			continue
+		}
+		switch v := value.(type) {
+		case map[string]any:
+			if err := flatten(values, key, v); err != nil {
+				return err
+			}
+		case []any:
+			items := make([]string, len(v))
+			for i, item := range v {
+				s, err := scalar(item)
+				if err != nil {
+					return fmt.Errorf("%s: %w", key, err)
+				}
+				items[i] = s
+			}
+			values[key] = strings.Join(items, ",")
+		default:
+			s, err := scalar(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			values[key] = s
+		}
+	}
+	return nil
+}
+
+func scalar(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("unsupported value %v", value)
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+)
+
+// Validate confere combinações de configuração que impediriam o serviço de funcionar como
+// esperado. As mensagens citam as variáveis de ambiente envolvidas.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.EnableS3Upload {
+		if c.S3Bucket == "" {
+			fail("ENABLE_S3_UPLOAD is true but OCI_BUCKET_MEDIA is empty (set ENABLE_S3_UPLOAD=false to disable object storage)")
+		}
+		if c.S3Endpoint == "" {
+			fail("ENABLE_S3_UPLOAD is true but OCI_ENDPOINT is empty")
+		}
+	}
+	if c.EnableWatermark {
+		if _, err := time.LoadLocation(c.WatermarkTimezone); err != nil {
+			fail("WATERMARK_TIMEZONE %q: %v", c.WatermarkTimezone, err)
+		}
+	}
+	if c.DisasterRecoveryMode && c.BackupPath == "" {
+		fail("DISASTER_RECOVERY_MODE is true but BACKUP_VIDEO_PATH is empty")
+	}
+	if c.EnableLocalStorage && c.VideoPath == "" {
+		fail("ENABLE_LOCAL_STORAGE is true but LOCAL_VIDEO_PATH is empty")
+	}
+	if c.EnableSecret && c.SecretKey == "" && len(c.DeviceSecrets) == 0 {
+		fail("ENABLE_SECRET is true but neither SECRET_KEY nor DEVICE_SECRETS is set")
+	}
+	if c.URLSigningKey != "" && c.URLSigningKey == c.SecretKey {
+		fail("URL_SIGNING_KEY must differ from SECRET_KEY (the device secret would allow forging download URLs)")
+	}
+	if c.LogFilePath == "" {
+		fail("LOG_FILE_PATH is empty")
+	}
+
+	if _, _, err := net.SplitHostPort(c.ListenAddr); err != nil {
+		fail("LISTEN_ADDR %q: %v", c.ListenAddr, err)
+	}
+	if c.AdminListenAddr != "" {
+		if _, _, err := net.SplitHostPort(c.AdminListenAddr); err != nil {
+			fail("ADMIN_LISTEN_ADDR %q: %v", c.AdminListenAddr, err)
+		} else if c.AdminListenAddr == c.ListenAddr {
+			fail("ADMIN_LISTEN_ADDR must differ from LISTEN_ADDR (%s)", c.ListenAddr)
+		}
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		fail("LOG_LEVEL %q: expected debug, info, warn or error", c.LogLevel)
+	}
+
+	positive := []struct {
+		name  string
+		value int
+	}{
+		{"MAX_CONCURRENT_WORKERS", c.MaxConcurrentWorkers},
+		{"COMMAND_WORKERS", c.CommandWorkers},
+		{"WEBHOOK_WORKERS", c.WebhookWorkers},
+		{"WEBHOOK_QUEUE_SIZE", c.WebhookQueueSize},
+		{"WEBHOOK_MAX_ATTEMPTS", c.WebhookMaxAttempts},
+		{"SHUTDOWN_TIMEOUT_SECONDS", c.ShutdownTimeout},
+	}
+	for _, v := range positive {
+		if v.value <= 0 {
+			fail("%s must be greater than zero (got %d)", v.name, v.value)
+		}
+	}
+	nonNegative := []struct {
+		name  string
+		value int
+	}{
+		{"RATE_LIMIT_UPLOADS_PER_MINUTE", c.RateLimitUploadsPerMinute},
+		{"RABBITMQ_MAX_LENGTH", c.RabbitMQMaxLength},
+		{"SSE_MAX_CONNECTIONS", c.StreamMaxConnections},
+		{"PRESIGN_EXPIRY_SECONDS", c.PresignExpiry},
+		{"PRESIGN_MAX_EXPIRY_SECONDS", c.PresignMaxExpiry},
+	}
+	for _, v := range nonNegative {
+		if v.value < 0 {
+			fail("%s must not be negative (got %d)", v.name, v.value)
+		}
+	}
+	if c.RateLimitBytesPerHour < 0 {
+		fail("RATE_LIMIT_BYTES_PER_HOUR must not be negative (got %d)", c.RateLimitBytesPerHour)
+	}
+	// PRESIGN_MAX_EXPIRY_SECONDS=0 significa sem máximo
+	if c.PresignMaxExpiry > 0 && c.PresignExpiry > c.PresignMaxExpiry {
+		fail("PRESIGN_EXPIRY_SECONDS (%d) is greater than PRESIGN_MAX_EXPIRY_SECONDS (%d)", c.PresignExpiry, c.PresignMaxExpiry)
+	}
+
+	switch c.TracingExporter {
+	case "none", "stdout", "otlp":
+	default:
+		fail("TRACING_EXPORTER %q: expected none, stdout or otlp", c.TracingExporter)
+	}
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		fail("TRACING_SAMPLE_RATIO must be between 0 and 1 (got %g)", c.TracingSampleRatio)
+	}
+
+	if c.EnableCustody && c.CustodySigningKey == "" {
+		fail("ENABLE_CUSTODY is true but CUSTODY_SIGNING_KEY is empty")
+	}
+	if c.EnableNATS && c.NATSURL == "" {
+		fail("ENABLE_NATS is true but NATS_URL is empty")
+	}
+	if c.EnableKafka && len(c.KafkaBrokers) == 0 {
+		fail("ENABLE_KAFKA is true but KAFKA_BROKERS is empty")
+	}
+	for i, ep := range c.WebhookEndpoints {
+		if ep.URL == "" {
+			fail("WEBHOOK_ENDPOINTS[%d] (%s): url is empty", i, ep.Name)
+		} else if !strings.HasPrefix(ep.URL, "http://") && !strings.HasPrefix(ep.URL, "https://") {
+			fail("WEBHOOK_ENDPOINTS[%d] (%s): url must start with http:// or https://", i, ep.Name)
+		}
+	}
+	classWorkers, catchAll := 0, false
+	for i, class := range c.PriorityClasses {
+		if class.Name == "" || class.Workers <= 0 {
+			fail("PRIORITY_CLASSES[%d]: name and a positive workers count are required", i)
+		}
+		classWorkers += class.Workers
+		if len(class.Types) == 0 && len(class.Channels) == 0 && class.Pattern == "" {
+			catchAll = true
+		}
+	}
+	// As classes dividem MAX_CONCURRENT_WORKERS; sem classe sem regras, a "default" fica com o que sobrar
+	if c.MaxConcurrentWorkers > 0 && len(c.PriorityClasses) > 0 {
+		if classWorkers > c.MaxConcurrentWorkers {
+			fail("PRIORITY_CLASSES use %d workers, more than MAX_CONCURRENT_WORKERS (%d)", classWorkers, c.MaxConcurrentWorkers)
+		} else if !catchAll && classWorkers == c.MaxConcurrentWorkers {
+			fail("PRIORITY_CLASSES use all MAX_CONCURRENT_WORKERS (%d); leave workers for the default class or add a class without rules", c.MaxConcurrentWorkers)
+		}
+	}
+	return errors.Join(errs...)
+}
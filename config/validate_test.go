@@ -0,0 +1,158 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		file    string // conteúdo YAML de CONFIG_FILE
+		wantErr []string
+		check   func(t *testing.T, cfg *Config)
+	}{
+		{
+			name: "defaults boot without object storage",
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.EnableS3Upload {
+					t.Fatal("ENABLE_S3_UPLOAD defaults to true without OCI_BUCKET_MEDIA")
+				}
+			},
+		},
+		{
+			name: "bucket turns object storage on",
+			env:  map[string]string{"OCI_BUCKET_MEDIA": "media", "OCI_ENDPOINT": "https://objectstorage.example.com"},
+			check: func(t *testing.T, cfg *Config) {
+				if !cfg.EnableS3Upload {
+					t.Fatal("ENABLE_S3_UPLOAD is off with OCI_BUCKET_MEDIA set")
+				}
+			},
+		},
+		{
+			name:    "object storage enabled without bucket",
+			env:     map[string]string{"ENABLE_S3_UPLOAD": "true"},
+			wantErr: []string{"OCI_BUCKET_MEDIA is empty", "OCI_ENDPOINT is empty"},
+		},
+		{
+			name:    "bucket without endpoint",
+			env:     map[string]string{"OCI_BUCKET_MEDIA": "media"},
+			wantErr: []string{"OCI_ENDPOINT is empty"},
+		},
+		{
+			name:    "url signing key reuses the device secret",
+			env:     map[string]string{"SECRET_KEY": "k", "URL_SIGNING_KEY": "k"},
+			wantErr: []string{"URL_SIGNING_KEY must differ from SECRET_KEY"},
+		},
+		{
+			name:    "invalid numbers and addresses",
+			env:     map[string]string{"MAX_CONCURRENT_WORKERS": "0", "LISTEN_ADDR": "23010", "LOG_LEVEL": "verbose"},
+			wantErr: []string{"MAX_CONCURRENT_WORKERS must be greater than zero", "LISTEN_ADDR", "LOG_LEVEL"},
+		},
+		{
+			name:    "admin on the upload address",
+			env:     map[string]string{"LISTEN_ADDR": ":23010", "ADMIN_LISTEN_ADDR": ":23010"},
+			wantErr: []string{"ADMIN_LISTEN_ADDR must differ from LISTEN_ADDR"},
+		},
+		{
+			name:    "priority classes above the worker limit",
+			env:     map[string]string{"MAX_CONCURRENT_WORKERS": "4", "PRIORITY_CLASSES": `[{"name":"alarm","types":["I"],"workers":3},{"name":"rest","workers":2}]`},
+			wantErr: []string{"more than MAX_CONCURRENT_WORKERS (4)"},
+		},
+		{
+			name:    "priority classes leave nothing for the default class",
+			env:     map[string]string{"MAX_CONCURRENT_WORKERS": "4", "PRIORITY_CLASSES": `[{"name":"alarm","types":["I"],"workers":4}]`},
+			wantErr: []string{"leave workers for the default class"},
+		},
+		{
+			name: "priority classes with a catch-all may use every worker",
+			env:  map[string]string{"MAX_CONCURRENT_WORKERS": "4", "PRIORITY_CLASSES": `[{"name":"alarm","types":["I"],"workers":2},{"name":"rest","workers":2}]`},
+		},
+		{
+			name: "enabled integrations without their settings",
+			env:  map[string]string{"ENABLE_CUSTODY": "true", "ENABLE_KAFKA": "true", "DISASTER_RECOVERY_MODE": "true"},
+			// Variáveis de ambiente vazias usam o padrão; no arquivo o valor vazio é explícito
+			file:    "kafka:\n  brokers: \"\"\nbackup_video_path: \"\"\n",
+			wantErr: []string{"CUSTODY_SIGNING_KEY is empty", "KAFKA_BROKERS is empty", "BACKUP_VIDEO_PATH is empty"},
+		},
+		{
+			name: "presign without a maximum",
+			env:  map[string]string{"PRESIGN_EXPIRY_SECONDS": "900000", "PRESIGN_MAX_EXPIRY_SECONDS": "0"},
+		},
+		{
+			name:    "presign expiry above the maximum",
+			env:     map[string]string{"PRESIGN_EXPIRY_SECONDS": "7200", "PRESIGN_MAX_EXPIRY_SECONDS": "3600"},
+			wantErr: []string{"PRESIGN_EXPIRY_SECONDS (7200) is greater than PRESIGN_MAX_EXPIRY_SECONDS (3600)"},
+		},
+		{
+			name:    "negative presign maximum",
+			env:     map[string]string{"PRESIGN_MAX_EXPIRY_SECONDS": "-1"},
+			wantErr: []string{"PRESIGN_MAX_EXPIRY_SECONDS must not be negative"},
+		},
+		{
+			name:    "unknown watermark timezone",
+			env:     map[string]string{"ENABLE_WATERMARK": "true", "WATERMARK_TIMEZONE": "America/Atlantis"},
+			wantErr: []string{`WATERMARK_TIMEZONE "America/Atlantis"`},
+		},
+		{
+			name: "watermark timezone ignored with the watermark off",
+			env:  map[string]string{"ENABLE_WATERMARK": "false", "WATERMARK_TIMEZONE": "America/Atlantis"},
+		},
+		{
+			name:    "webhook without scheme",
+			env:     map[string]string{"WEBHOOK_ENDPOINTS": `[{"name":"erp","url":"erp.example.com/hook"}]`},
+			wantErr: []string{"must start with http:// or https://"},
+		},
+		{
+			name:    "unknown key in the file",
+			file:    "rabbitmq:\n  host: mq\n  hots: typo\n",
+			wantErr: []string{"RABBITMQ_HOTS: unknown setting"},
+		},
+		{
+			name: "file values under the environment",
+			env:  map[string]string{"RABBITMQ_HOST": "from-env"},
+			file: "rabbitmq:\n  host: from-file\n  port: 5673\n",
+			check: func(t *testing.T, cfg *Config) {
+				if !strings.Contains(cfg.RabbitMQURL, "from-env:5673") {
+					t.Fatalf("RabbitMQURL = %s, want the env host and the file port", cfg.RabbitMQURL)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			path := ""
+			if tt.file != "" {
+				path = filepath.Join(t.TempDir(), "config.yaml")
+				if err := os.WriteFile(path, []byte(tt.file), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cfg, err := Load(path)
+			if len(tt.wantErr) > 0 {
+				if err == nil {
+					t.Fatalf("Load succeeded, want errors %q", tt.wantErr)
+				}
+				for _, want := range tt.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Fatalf("error %q does not mention %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.check != nil {
+				tt.check(t, cfg)
+			}
+		})
+	}
+}
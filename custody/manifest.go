@@ -0,0 +1,224 @@
+package custody
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	StageOriginal   = "original"
+	StageRemuxed    = "remuxed"
+	StageCompressed = "compressed"
+	StageSubtitled  = "subtitled"
+	StageImage      = "image"   // snapshot após o tratamento do EXIF
+	StageVariant    = "variant" // variantes redimensionadas do snapshot
+)
+
+// ManifestSuffix é acrescentado à key/arquivo da mídia para formar o nome do manifesto.
+const ManifestSuffix = ".manifest.json"
+
+var (
+	ErrNoSignature      = errors.New("manifest is not signed")
+	ErrUntrustedKey     = errors.New("manifest signed by an untrusted key")
+	ErrInvalidSignature = errors.New("invalid manifest signature")
+	ErrHashMismatch     = errors.New("file does not match any artifact in the manifest")
+)
+
+// Artifact é uma versão da mídia (original recebido ou derivado) com seu hash.
+type Artifact struct {
+	Stage     string    `json:"stage"`
+	Filename  string    `json:"filename"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Manifest registra a cadeia de custódia de um upload. A assinatura Ed25519 cobre o JSON
+// do manifesto com o campo signature vazio.
+type Manifest struct {
+	Version    int        `json:"version"`
+	RequestID  string     `json:"request_id"`
+	IMEI       string     `json:"imei,omitempty"`
+	Filename   string     `json:"filename"`
+	ObjectKey  string     `json:"object_key,omitempty"`
+	ReceivedAt time.Time  `json:"received_at"`
+	Artifacts  []Artifact `json:"artifacts"`
+	PublicKey  string     `json:"public_key,omitempty"`
+	Signature  string     `json:"signature,omitempty"`
+}
+
+// AddArtifact calcula o SHA-256 do arquivo e acrescenta o artefato ao manifesto.
+func (m *Manifest) AddArtifact(stage, filename, path string) (Artifact, error) {
+	sum, size, err := HashFile(path)
+	if err != nil {
+		return Artifact{}, err
+	}
+	a := Artifact{Stage: stage, Filename: filename, Size: size, SHA256: sum, CreatedAt: time.Now().UTC()}
+	m.Artifacts = append(m.Artifacts, a)
+	return a, nil
+}
+
+func (m *Manifest) payload() ([]byte, error) {
+	unsigned := *m
+	unsigned.Signature = ""
+	return json.Marshal(unsigned)
+}
+
+// Sign assina o manifesto e registra a chave pública usada.
+func (m *Manifest) Sign(key ed25519.PrivateKey) error {
+	m.Version = 1
+	m.PublicKey = base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+	payload, err := m.payload()
+	if err != nil {
+		return err
+	}
+	m.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload))
+	return nil
+}
+
+// VerifySignature valida a assinatura. Se trusted for informado, a chave do manifesto
+// precisa ser uma delas; sem chaves confiáveis vale a chave embutida (autodeclarada).
+func (m *Manifest) VerifySignature(trusted ...ed25519.PublicKey) error {
+	if m.Signature == "" || m.PublicKey == "" {
+		return ErrNoSignature
+	}
+	pub, err := base64.StdEncoding.DecodeString(m.PublicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid public key in manifest")
+	}
+	if len(trusted) > 0 {
+		ok := false
+		for _, t := range trusted {
+			if t.Equal(ed25519.PublicKey(pub)) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return ErrUntrustedKey
+		}
+	}
+	sig, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	payload, err := m.payload()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(ed25519.PublicKey(pub), payload, sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Match procura o artefato com o hash informado.
+func (m *Manifest) Match(sha256Hex string) (Artifact, error) {
+	for _, a := range m.Artifacts {
+		if strings.EqualFold(a.SHA256, sha256Hex) {
+			return a, nil
+		}
+	}
+	return Artifact{}, ErrHashMismatch
+}
+
+// Parse lê um manifesto JSON.
+func Parse(r io.Reader) (*Manifest, error) {
+	var m Manifest
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// HashFile retorna o SHA-256 (hex) e o tamanho do arquivo.
+func HashFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// LoadPrivateKey aceita a seed (32 bytes) ou a chave privada (64 bytes) em base64 ou hex,
+// ou um caminho para um PEM PKCS#8.
+func LoadPrivateKey(value string) (ed25519.PrivateKey, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, fmt.Errorf("empty signing key")
+	}
+
+	if data, err := os.ReadFile(value); err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("signing key file is not PEM")
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse signing key: %w", err)
+		}
+		key, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("signing key is not Ed25519")
+		}
+		return key, nil
+	}
+
+	raw, ok := decodeKey(value, ed25519.SeedSize, ed25519.PrivateKeySize)
+	if !ok {
+		return nil, fmt.Errorf("signing key must be base64, hex or a PEM file path")
+	}
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	}
+	return nil, fmt.Errorf("invalid Ed25519 key length %d", len(raw))
+}
+
+// ParsePublicKey decodifica uma chave pública Ed25519 em base64 ou hex.
+func ParsePublicKey(value string) (ed25519.PublicKey, error) {
+	value = strings.TrimSpace(value)
+	raw, ok := decodeKey(value, ed25519.PublicKeySize)
+	if !ok {
+		return nil, fmt.Errorf("public key must be base64 or hex")
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid Ed25519 public key length %d", len(raw))
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// decodeKey decodifica uma chave em hex ou base64. O hex vem primeiro e só vale com um dos
+// tamanhos esperados: uma chave em hex também é base64 válido e decodificaria com o tamanho errado.
+func decodeKey(value string, sizes ...int) ([]byte, bool) {
+	if raw, err := hex.DecodeString(value); err == nil && slices.Contains(sizes, len(raw)) {
+		return raw, true
+	}
+	if raw, err := base64.StdEncoding.DecodeString(value); err == nil {
+		return raw, true
+	}
+	if raw, err := hex.DecodeString(value); err == nil {
+		return raw, true
+	}
+	return nil, false
+}
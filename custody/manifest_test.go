@@ -0,0 +1,216 @@
+package custody
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signedManifest monta um manifesto com o original e um derivado, assinado com key, e o
+// devolve depois de uma volta por JSON (como é lido do armazenamento).
+func signedManifest(t *testing.T, key ed25519.PrivateKey) (*Manifest, map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	sums := map[string]string{}
+	m := &Manifest{
+		RequestID:  "req-1",
+		IMEI:       "123456789012",
+		Filename:   "video.mp4",
+		ReceivedAt: time.Date(2024, 5, 1, 11, 30, 15, 0, time.UTC),
+	}
+	for stage, content := range map[string]string{StageOriginal: "original bytes", "compressed": "compressed bytes"} {
+		path := filepath.Join(dir, stage)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		a, err := m.AddArtifact(stage, "video.mp4", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Size != int64(len(content)) {
+			t.Fatalf("artifact size = %d, want %d", a.Size, len(content))
+		}
+		sums[stage] = a.SHA256
+	}
+	if err := m.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed, sums
+}
+
+func TestManifestSignVerify(t *testing.T) {
+	key := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+	other := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{2}, ed25519.SeedSize))
+	trusted := key.Public().(ed25519.PublicKey)
+
+	tests := []struct {
+		name    string
+		tamper  func(m *Manifest)
+		trusted []ed25519.PublicKey
+		wantErr error
+	}{
+		{name: "trusted key", trusted: []ed25519.PublicKey{trusted}},
+		{name: "one of several trusted keys", trusted: []ed25519.PublicKey{other.Public().(ed25519.PublicKey), trusted}},
+		{name: "embedded key without trusted keys"},
+		{name: "untrusted key", trusted: []ed25519.PublicKey{other.Public().(ed25519.PublicKey)}, wantErr: ErrUntrustedKey},
+		{
+			name:    "artifact hash changed",
+			tamper:  func(m *Manifest) { m.Artifacts[0].SHA256 = strings.Repeat("0", 64) },
+			trusted: []ed25519.PublicKey{trusted},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "artifact added",
+			tamper:  func(m *Manifest) { m.Artifacts = append(m.Artifacts, Artifact{Stage: "forged"}) },
+			trusted: []ed25519.PublicKey{trusted},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "imei changed",
+			tamper:  func(m *Manifest) { m.IMEI = "999999999999" },
+			trusted: []ed25519.PublicKey{trusted},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "re-signed by another key",
+			tamper: func(m *Manifest) {
+				m.IMEI = "999999999999"
+				if err := m.Sign(other); err != nil {
+					t.Fatal(err)
+				}
+			},
+			trusted: []ed25519.PublicKey{trusted},
+			wantErr: ErrUntrustedKey,
+		},
+		{
+			name:    "signature not base64",
+			tamper:  func(m *Manifest) { m.Signature = "%%%" },
+			trusted: []ed25519.PublicKey{trusted},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "unsigned",
+			tamper:  func(m *Manifest) { m.Signature = "" },
+			trusted: []ed25519.PublicKey{trusted},
+			wantErr: ErrNoSignature,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := signedManifest(t, key)
+			if tt.tamper != nil {
+				tt.tamper(m)
+			}
+			err := m.VerifySignature(tt.trusted...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifySignature() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestManifestMatch(t *testing.T) {
+	m, sums := signedManifest(t, ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)))
+	for stage, sum := range sums {
+		a, err := m.Match(sum)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Stage != stage {
+			t.Fatalf("Match(%s) = stage %q, want %q", sum, a.Stage, stage)
+		}
+	}
+	if _, err := m.Match(strings.Repeat("0", 64)); !errors.Is(err, ErrHashMismatch) {
+		t.Fatalf("Match(unknown) = %v, want %v", err, ErrHashMismatch)
+	}
+}
+
+func TestLoadPrivateKey(t *testing.T) {
+	seed := bytes.Repeat([]byte{7}, ed25519.SeedSize)
+	want := ed25519.NewKeyFromSeed(seed)
+
+	dir := t.TempDir()
+	der, err := x509.MarshalPKCS8PrivateKey(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemPath := filepath.Join(dir, "custody.pem")
+	if err := os.WriteFile(pemPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	notPEM := filepath.Join(dir, "custody.txt")
+	if err := os.WriteFile(notPEM, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		wantErr string
+	}{
+		{name: "base64 seed", value: base64.StdEncoding.EncodeToString(seed)},
+		{name: "hex seed", value: hex.EncodeToString(seed)},
+		{name: "base64 private key", value: base64.StdEncoding.EncodeToString(want)},
+		{name: "surrounding whitespace", value: " " + hex.EncodeToString(seed) + "\n"},
+		{name: "PEM file", value: pemPath},
+		{name: "empty", value: "", wantErr: "empty signing key"},
+		{name: "file not PEM", value: notPEM, wantErr: "not PEM"},
+		{name: "not base64 or hex", value: "zz", wantErr: "must be base64, hex or a PEM file path"},
+		{name: "wrong length", value: base64.StdEncoding.EncodeToString([]byte("short")), wantErr: "invalid Ed25519 key length 5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadPrivateKey(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(want) {
+				t.Fatal("loaded a different key")
+			}
+		})
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	pub := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)).Public().(ed25519.PublicKey)
+	for _, value := range []string{base64.StdEncoding.EncodeToString(pub), hex.EncodeToString(pub)} {
+		got, err := ParsePublicKey(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(pub) {
+			t.Fatalf("ParsePublicKey(%q) returned a different key", value)
+		}
+	}
+	if _, err := ParsePublicKey(hex.EncodeToString(pub[:16])); err == nil {
+		t.Fatal("short public key accepted")
+	}
+	if _, err := ParsePublicKey("not a key"); err == nil {
+		t.Fatal("invalid public key accepted")
+	}
+}
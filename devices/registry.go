@@ -0,0 +1,61 @@
+package devices
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Registry guarda os secrets de assinatura por dispositivo (IMEI). Dispositivos sem secret
+// próprio usam o SECRET_KEY global. Pode ser substituído em tempo de execução.
+type Registry struct {
+	mu      sync.RWMutex
+	secrets map[string]string
+}
+
+func NewRegistry(secrets map[string]string) *Registry {
+	r := &Registry{}
+	r.Replace(secrets)
+	return r
+}
+
+// Secret retorna o secret registrado para o IMEI.
+func (r *Registry) Secret(imei string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	secret, ok := r.secrets[strings.TrimSpace(imei)]
+	return secret, ok
+}
+
+// IMEIs retorna os dispositivos registrados, ordenados.
+func (r *Registry) IMEIs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	imeis := make([]string, 0, len(r.secrets))
+	for imei := range r.secrets {
+		imeis = append(imeis, imei)
+	}
+	sort.Strings(imeis)
+	return imeis
+}
+
+func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.secrets)
+}
+
+// Replace troca todo o registro (ex: recarga da configuração). Entradas vazias são ignoradas.
+func (r *Registry) Replace(secrets map[string]string) {
+	clean := make(map[string]string, len(secrets))
+	for imei, secret := range secrets {
+		imei = strings.TrimSpace(imei)
+		if imei == "" || secret == "" {
+			continue
+		}
+		clean[imei] = secret
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.secrets = clean
+}
@@ -0,0 +1,35 @@
+package devices
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(map[string]string{
+		" 123456789012 ": "device-a",
+		"999999999999":   "device-b",
+		"":               "no imei",
+		"555555555555":   "",
+	})
+	if r.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", r.Len())
+	}
+	if got := r.IMEIs(); !slices.Equal(got, []string{"123456789012", "999999999999"}) {
+		t.Fatalf("IMEIs() = %v", got)
+	}
+	if secret, ok := r.Secret(" 123456789012"); !ok || secret != "device-a" {
+		t.Fatalf("Secret() = %q, %v", secret, ok)
+	}
+	if _, ok := r.Secret("555555555555"); ok {
+		t.Fatal("device with an empty secret registered")
+	}
+
+	r.Replace(map[string]string{"555555555555": "device-c"})
+	if _, ok := r.Secret("123456789012"); ok {
+		t.Fatal("Replace kept a removed device")
+	}
+	if got := r.IMEIs(); !slices.Equal(got, []string{"555555555555"}) {
+		t.Fatalf("IMEIs() after Replace = %v", got)
+	}
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"dvr-upload/queue"
+)
+
+const (
+	activityRecentSize = 200
+	activityMaxDevices = 2000
+)
+
+// Status de um upload na atividade recente.
+const (
+	activityProcessing = "processing"
+	activityUploaded   = "uploaded"
+	activityPublished  = "published"
+	activityRejected   = "rejected"
+	activityFailed     = "failed"
+)
+
+// UploadActivity resume um upload recente para o dashboard.
+type UploadActivity struct {
+	RequestID  string    `json:"request_id"`
+	IMEI       string    `json:"imei,omitempty"`
+	Type       string    `json:"type,omitempty"`
+	Channel    string    `json:"channel,omitempty"`
+	Filename   string    `json:"filename,omitempty"`
+	Size       int64     `json:"size,omitempty"`
+	Status     string    `json:"status"`
+	Reason     string    `json:"reason,omitempty"`
+	Stage      string    `json:"stage,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Warnings   []string  `json:"warnings,omitempty"` // estágios que falharam sem interromper o upload
+	ReceivedAt time.Time `json:"received_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Duration   string    `json:"duration,omitempty"` // do recebimento até o upload
+}
+
+// DeviceActivity agrega a atividade recente de um dispositivo.
+type DeviceActivity struct {
+	IMEI     string    `json:"imei"`
+	Received int64     `json:"received"`
+	Uploaded int64     `json:"uploaded"`
+	Rejected int64     `json:"rejected"`
+	Failed   int64     `json:"failed"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// activityLog mantém os uploads recentes e a atividade por dispositivo a partir dos eventos do barramento.
+type activityLog struct {
+	mu      sync.Mutex
+	recent  []*UploadActivity // mais antigo primeiro
+	byID    map[string]*UploadActivity
+	devices map[string]*DeviceActivity
+}
+
+func newActivityLog() *activityLog {
+	return &activityLog{
+		byID:    make(map[string]*UploadActivity),
+		devices: make(map[string]*DeviceActivity),
+	}
+}
+
+func (a *activityLog) consume(sub *queue.Subscription) {
+	for msg := range sub.C {
+		a.record(msg)
+	}
+}
+
+func (a *activityLog) record(msg queue.Message) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch payload := msg.Payload.(type) {
+	case queue.LifecycleEvent:
+		if !queue.IsLifecycle(msg.Kind) {
+			return
+		}
+		entry := a.entry(msg, payload.Filename, payload.Size)
+		if payload.Channel != "" {
+			entry.Channel = payload.Channel
+		}
+		switch msg.Kind {
+		case queue.KindMediaReceived:
+			entry.Status = activityProcessing
+			a.device(msg.IMEI, msg.Time).Received++
+		case queue.KindMediaRejected:
+			entry.Status = activityRejected
+			entry.Reason = payload.Reason
+			entry.Error = payload.Error
+			a.device(msg.IMEI, msg.Time).Rejected++
+		case queue.KindProcessingFailed:
+			if payload.Fatal {
+				entry.Status = activityFailed
+				entry.Stage = payload.Stage
+				entry.Error = payload.Error
+				a.device(msg.IMEI, msg.Time).Failed++
+			} else {
+				entry.Warnings = append(entry.Warnings, payload.Stage)
+			}
+		case queue.KindMediaPublished:
+			entry.Status = activityPublished
+		}
+	case queue.UploadEvent:
+		entry := a.entry(msg, payload.Filename, payload.Size)
+		entry.Status = activityUploaded
+		entry.Duration = msg.Time.Sub(entry.ReceivedAt).Truncate(time.Millisecond).String()
+		a.device(msg.IMEI, msg.Time).Uploaded++
+	}
+}
+
+// entry retorna (ou cria) o registro do upload da mensagem.
+func (a *activityLog) entry(msg queue.Message, filename string, size int64) *UploadActivity {
+	entry, ok := a.byID[msg.ID]
+	if !ok {
+		entry = &UploadActivity{RequestID: msg.ID, ReceivedAt: msg.Time}
+		if len(a.recent) >= activityRecentSize {
+			delete(a.byID, a.recent[0].RequestID)
+			a.recent = a.recent[1:]
+		}
+		a.recent = append(a.recent, entry)
+		a.byID[msg.ID] = entry
+	}
+	if msg.IMEI != "" {
+		entry.IMEI = msg.IMEI
+	}
+	if msg.Type != "" {
+		entry.Type = msg.Type
+	}
+	if filename != "" {
+		entry.Filename = filename
+	}
+	if size > 0 {
+		entry.Size = size
+	}
+	entry.UpdatedAt = msg.Time
+	return entry
+}
+
+func (a *activityLog) device(imei string, at time.Time) *DeviceActivity {
+	if imei == "" {
+		imei = "unknown"
+	}
+	d, ok := a.devices[imei]
+	if !ok {
+		if len(a.devices) >= activityMaxDevices {
+			a.evictOldestDevice()
+		}
+		d = &DeviceActivity{IMEI: imei}
+		a.devices[imei] = d
+	}
+	d.LastSeen = at
+	return d
+}
+
+func (a *activityLog) evictOldestDevice() {
+	var oldest *DeviceActivity
+	for _, d := range a.devices {
+		if oldest == nil || d.LastSeen.Before(oldest.LastSeen) {
+			oldest = d
+		}
+	}
+	if oldest != nil {
+		delete(a.devices, oldest.IMEI)
+	}
+}
+
+// Recent retorna os uploads recentes, mais novos primeiro.
+func (a *activityLog) Recent(limit int) []UploadActivity {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make([]UploadActivity, 0, min(limit, len(a.recent)))
+	for i := len(a.recent) - 1; i >= 0 && len(out) < limit; i-- {
+		entry := *a.recent[i]
+		entry.Warnings = append([]string(nil), entry.Warnings...)
+		out = append(out, entry)
+	}
+	return out
+}
+
+// Devices retorna os dispositivos mais recentemente ativos primeiro.
+func (a *activityLog) Devices(limit int) []DeviceActivity {
+	a.mu.Lock()
+	out := make([]DeviceActivity, 0, len(a.devices))
+	for _, d := range a.devices {
+		out = append(out, *d)
+	}
+	a.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].LastSeen.After(out[j].LastSeen) })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
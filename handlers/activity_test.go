@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"dvr-upload/queue"
+)
+
+func TestActivityLog(t *testing.T) {
+	a := newActivityLog()
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	lifecycle := func(kind, id, imei string, at time.Duration, event queue.LifecycleEvent) {
+		event.RequestID, event.IMEI, event.Time = id, imei, start.Add(at)
+		a.record(queue.NewLifecycleMessage(kind, event))
+	}
+
+	// req-1: recebido, compressão falhou sem interromper, enviado e publicado
+	lifecycle(queue.KindMediaReceived, "req-1", "111", 0, queue.LifecycleEvent{Filename: "a.mp4", Size: 100, Channel: "1"})
+	lifecycle(queue.KindProcessingFailed, "req-1", "111", time.Second, queue.LifecycleEvent{Stage: queue.StageCompress, Error: "ffmpeg"})
+	uploaded := queue.NewUploadMessage(queue.UploadEvent{RequestID: "req-1", IMEI: "111", Filename: "a.mp4", Size: 80})
+	uploaded.Time = start.Add(2500 * time.Millisecond)
+	a.record(uploaded)
+	lifecycle(queue.KindMediaPublished, "req-1", "111", 3*time.Second, queue.LifecycleEvent{})
+	// req-2: assinatura inválida; req-3: falha fatal no upload
+	lifecycle(queue.KindMediaRejected, "req-2", "222", 4*time.Second, queue.LifecycleEvent{Reason: rejectInvalidSignature})
+	lifecycle(queue.KindMediaReceived, "req-3", "111", 5*time.Second, queue.LifecycleEvent{Filename: "b.jpg"})
+	lifecycle(queue.KindProcessingFailed, "req-3", "111", 6*time.Second, queue.LifecycleEvent{Stage: queue.StageS3Upload, Fatal: true, Error: "timeout"})
+	// Eventos de progresso não entram na atividade
+	a.record(queue.NewLifecycleMessage(queue.KindSignatureVerified, queue.LifecycleEvent{RequestID: "req-4"}))
+
+	recent := a.Recent(10)
+	if len(recent) != 3 {
+		t.Fatalf("recent = %+v", recent)
+	}
+	first := recent[2]
+	if first.RequestID != "req-1" || first.Status != activityPublished || first.Size != 80 || first.Channel != "1" ||
+		first.Duration != "2.5s" || !slices.Equal(first.Warnings, []string{queue.StageCompress}) {
+		t.Fatalf("req-1 = %+v", first)
+	}
+	if r := recent[1]; r.Status != activityRejected || r.Reason != rejectInvalidSignature {
+		t.Fatalf("req-2 = %+v", r)
+	}
+	if r := recent[0]; r.Status != activityFailed || r.Stage != queue.StageS3Upload || r.Error != "timeout" || r.Filename != "b.jpg" {
+		t.Fatalf("req-3 = %+v", r)
+	}
+	if got := a.Recent(1); len(got) != 1 || got[0].RequestID != "req-3" {
+		t.Fatalf("Recent(1) = %+v", got)
+	}
+
+	devices := a.Devices(10)
+	if len(devices) != 2 {
+		t.Fatalf("devices = %+v", devices)
+	}
+	if d := devices[0]; d.IMEI != "111" || d.Received != 2 || d.Uploaded != 1 || d.Failed != 1 || d.Rejected != 0 || !d.LastSeen.Equal(start.Add(6*time.Second)) {
+		t.Fatalf("device 111 = %+v", d)
+	}
+	if d := devices[1]; d.IMEI != "222" || d.Rejected != 1 {
+		t.Fatalf("device 222 = %+v", d)
+	}
+}
+
+func TestActivityLogLimits(t *testing.T) {
+	a := newActivityLog()
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	for i := 0; i < activityMaxDevices+1; i++ {
+		a.record(queue.NewLifecycleMessage(queue.KindMediaReceived, queue.LifecycleEvent{
+			RequestID: fmt.Sprintf("req-%d", i),
+			IMEI:      fmt.Sprintf("imei-%d", i),
+			Time:      start.Add(time.Duration(i) * time.Second),
+		}))
+	}
+
+	recent := a.Recent(activityRecentSize + 10)
+	if len(recent) != activityRecentSize || recent[0].RequestID != fmt.Sprintf("req-%d", activityMaxDevices) {
+		t.Fatalf("recent has %d entries, newest %q", len(recent), recent[0].RequestID)
+	}
+	devices := a.Devices(activityMaxDevices + 10)
+	if len(devices) != activityMaxDevices {
+		t.Fatalf("devices = %d, want %d", len(devices), activityMaxDevices)
+	}
+	// O dispositivo visto há mais tempo é o descartado
+	if devices[len(devices)-1].IMEI != "imei-1" {
+		t.Fatalf("oldest kept device = %s", devices[len(devices)-1].IMEI)
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"dvr-upload/utils"
+)
+
+// UseLogLevel permite que a API de admin altere o nível de log em tempo de execução.
+func (h *Handler) UseLogLevel(level *slog.LevelVar) {
+	h.logLevel = level
+}
+
+// AdminRoutes registra a API de admin, servida em um endereço separado do das câmeras.
+// Todas as rotas exigem um token de ADMIN_TOKENS.
+func (h *Handler) AdminRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /admin/status", h.RequireAdmin(h.AdminStatusHandler))
+	mux.HandleFunc("GET /admin/jobs", h.RequireAdmin(h.AdminJobsHandler))
+	mux.HandleFunc("POST /admin/jobs/{id}/cancel", h.RequireAdmin(h.AdminCancelJobHandler))
+	mux.HandleFunc("POST /admin/{target}/{action}", h.RequireAdmin(h.AdminPauseHandler))
+	mux.HandleFunc("POST /admin/recovery", h.RequireAdmin(h.AdminRecoveryHandler))
+	mux.HandleFunc("POST /admin/cleanup", h.RequireAdmin(h.AdminCleanupHandler))
+	mux.HandleFunc("GET /admin/log-level", h.RequireAdmin(h.AdminLogLevelHandler))
+	mux.HandleFunc("PUT /admin/log-level", h.RequireAdmin(h.AdminLogLevelHandler))
+	mux.HandleFunc("GET /admin/config", h.RequireAdmin(h.AdminConfigHandler))
+	mux.HandleFunc("GET /admin/webhooks/deliveries", h.RequireAdmin(h.WebhookDeliveriesHandler))
+	mux.HandleFunc("GET /events/stream", h.RequireAdmin(h.EventStreamHandler))
+}
+
+type pauseState struct {
+	Paused bool      `json:"paused"`
+	Since  time.Time `json:"since,omitzero"`
+}
+
+func stateOf(p *pauseSwitch) pauseState {
+	paused, since := p.State()
+	return pauseState{Paused: paused, Since: since}
+}
+
+// AdminStatusHandler responde GET /admin/status com o estado das pausas e dos jobs.
+func (h *Handler) AdminStatusHandler(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSON(w, http.StatusOK, utils.JSONResponse{Code: 200, Message: "ok", Data: map[string]any{
+		"intake":         stateOf(&h.intake),
+		"processing":     stateOf(&h.processing),
+		"jobs":           h.jobs.count(),
+		"event_streams":  h.streams.active(),
+		"active_uploads": atomic.LoadInt64(&h.activeUploads),
+		"log_level":      h.currentLogLevel(),
+	}})
+}
+
+// AdminJobsHandler responde GET /admin/jobs com os jobs em andamento, seu estágio e idade.
+func (h *Handler) AdminJobsHandler(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSON(w, http.StatusOK, utils.JSONResponse{Code: 200, Message: "ok", Data: h.jobs.list()})
+}
+
+// AdminCancelJobHandler responde POST /admin/jobs/{id}/cancel. O job é interrompido antes do próximo
+// estágio; depois do upload para o S3 ele não pode mais ser cancelado.
+func (h *Handler) AdminCancelJobHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !h.jobs.cancel(id) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.JSONResponse{Code: 404, Message: "Job not found"})
+		return
+	}
+	h.log.Info("Job cancellation requested by admin", "request_id", id)
+	utils.WriteJSON(w, http.StatusAccepted, utils.JSONResponse{Code: 202, Message: "ok", Data: id})
+}
+
+// AdminPauseHandler responde POST /admin/{intake|processing}/{pause|resume}. Com o recebimento pausado
+// os uploads recebem 503; com o processamento pausado os arquivos recebidos aguardam na fila.
+func (h *Handler) AdminPauseHandler(w http.ResponseWriter, r *http.Request) {
+	var target *pauseSwitch
+	switch r.PathValue("target") {
+	case "intake":
+		target = &h.intake
+	case "processing":
+		target = &h.processing
+	default:
+		utils.WriteJSON(w, http.StatusNotFound, utils.JSONResponse{Code: 404, Message: "Not found"})
+		return
+	}
+
+	var changed bool
+	switch r.PathValue("action") {
+	case "pause":
+		changed = target.Pause()
+	case "resume":
+		changed = target.Resume()
+	default:
+		utils.WriteJSON(w, http.StatusNotFound, utils.JSONResponse{Code: 404, Message: "Not found"})
+		return
+	}
+	if changed {
+		h.log.Warn("Admin changed pause state", "target", r.PathValue("target"), "action", r.PathValue("action"))
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.JSONResponse{Code: 200, Message: "ok", Data: stateOf(target)})
+}
+
+// AdminRecoveryHandler responde POST /admin/recovery disparando a varredura de arquivos órfãos.
+func (h *Handler) AdminRecoveryHandler(w http.ResponseWriter, r *http.Request) {
+	h.log.Info("Recovery scan requested by admin")
+	go h.StartRecoveryTask()
+	utils.WriteJSON(w, http.StatusAccepted, utils.JSONResponse{Code: 202, Message: "ok"})
+}
+
+// AdminCleanupHandler responde POST /admin/cleanup executando a limpeza de temporários.
+func (h *Handler) AdminCleanupHandler(w http.ResponseWriter, r *http.Request) {
+	h.log.Info("Cleanup requested by admin")
+	removed := utils.RunCleanup(h.cfg.VideoPath, h.cfg.BackupPath, h.log)
+	utils.WriteJSON(w, http.StatusOK, utils.JSONResponse{Code: 200, Message: "ok", Data: map[string]int{"files_removed": removed}})
+}
+
+// AdminLogLevelHandler responde GET/PUT /admin/log-level. O PUT recebe {"level":"debug|info|warn|error"}.
+func (h *Handler) AdminLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	if h.logLevel == nil {
+		utils.WriteJSON(w, http.StatusServiceUnavailable, utils.JSONResponse{Code: 503, Message: "Log level is not adjustable"})
+		return
+	}
+	if r.Method == http.MethodPut {
+		var body struct {
+			Level string `json:"level"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&body); err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.JSONResponse{Code: 400, Message: "Invalid JSON body"})
+			return
+		}
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(strings.TrimSpace(body.Level))); err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.JSONResponse{Code: 400, Message: "Invalid level"})
+			return
+		}
+		previous := h.logLevel.Level()
+		h.logLevel.Set(level)
+		h.log.Warn("Log level changed by admin", "from", previous.String(), "to", level.String())
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.JSONResponse{Code: 200, Message: "ok", Data: map[string]string{"level": h.currentLogLevel()}})
+}
+
+// AdminConfigHandler responde GET /admin/config com a configuração efetiva, segredos ocultos, e os
+// campos alterados por um reload que só passam a valer após reinício.
+func (h *Handler) AdminConfigHandler(w http.ResponseWriter, r *http.Request) {
+	effective, pending := h.effectiveConfig()
+	utils.WriteJSON(w, http.StatusOK, utils.JSONResponse{Code: 200, Message: "ok", Data: map[string]any{
+		"config":          effective.Redacted(),
+		"pending_restart": pending,
+	}})
+}
+
+func (h *Handler) currentLogLevel() string {
+	if h.logLevel == nil {
+		return ""
+	}
+	return h.logLevel.Level().String()
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"dvr-upload/config"
+)
+
+const testAdminToken = "admin-token"
+
+// newAdminServer monta a API de admin e o dashboard de um handler mínimo, como no endereço de admin
+// do main, com o nível de log ajustável.
+func newAdminServer(t *testing.T, cfg *config.Config) (*Handler, *httptest.Server) {
+	t.Helper()
+	cfg.AdminTokens = []string{testAdminToken}
+	cfg.MaxConcurrentWorkers = 1
+	h := NewHandler(cfg, nil, nil, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	h.UseLogLevel(new(slog.LevelVar))
+	mux := http.NewServeMux()
+	h.AdminRoutes(mux)
+	h.DashboardRoutes(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return h, srv
+}
+
+// adminRequest chama a API de admin com o token de teste e decodifica o campo data da resposta em out.
+func adminRequest(t *testing.T, srv *httptest.Server, method, path, body string, out any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		envelope := struct {
+			Data any `json:"data"`
+		}{Data: out}
+		if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestRequireAdmin(t *testing.T) {
+	_, srv := newAdminServer(t, &config.Config{})
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   int
+	}{
+		{name: "no token", want: http.StatusUnauthorized},
+		{name: "wrong token", header: "X-Admin-Token", value: "other", want: http.StatusUnauthorized},
+		{name: "empty bearer", header: "Authorization", value: "Bearer ", want: http.StatusUnauthorized},
+		{name: "X-Admin-Token", header: "X-Admin-Token", value: testAdminToken, want: http.StatusOK},
+		{name: "bearer", header: "Authorization", value: "bearer " + testAdminToken, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, srv.URL+"/admin/status", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status code = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if tt.want == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == "" {
+				t.Fatal("401 without WWW-Authenticate")
+			}
+		})
+	}
+}
+
+// startPausedJob pausa o processamento, inicia um job e espera ele aparecer na API como pausado.
+func startPausedJob(t *testing.T, h *Handler, srv *httptest.Server) <-chan error {
+	t.Helper()
+	var state pauseState
+	if code := adminRequest(t, srv, http.MethodPost, "/admin/processing/pause", "", &state); code != http.StatusOK || !state.Paused {
+		t.Fatalf("pause: status %d, state %+v", code, state)
+	}
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	if err := os.WriteFile(path, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- runJob(t, h, path) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var jobs []JobStatus
+		adminRequest(t, srv, http.MethodGet, "/admin/jobs", "", &jobs)
+		if len(jobs) == 1 && jobs[0].Stage == stagePaused {
+			if jobs[0].RequestID != "req-1" || jobs[0].Filename != "video.mp4" || jobs[0].Size != 5 {
+				t.Fatalf("job = %+v", jobs[0])
+			}
+			return done
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job not paused: %+v", jobs)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAdminPauseAndResumeProcessing(t *testing.T) {
+	h, srv := newAdminServer(t, &config.Config{})
+	done := startPausedJob(t, h, srv)
+
+	var state pauseState
+	if code := adminRequest(t, srv, http.MethodPost, "/admin/processing/resume", "", &state); code != http.StatusOK || state.Paused {
+		t.Fatalf("resume: status %d, state %+v", code, state)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if n := h.jobs.count(); n != 0 {
+		t.Fatalf("%d jobs still tracked", n)
+	}
+}
+
+func TestAdminCancelJob(t *testing.T) {
+	h, srv := newAdminServer(t, &config.Config{})
+	done := startPausedJob(t, h, srv)
+
+	if code := adminRequest(t, srv, http.MethodPost, "/admin/jobs/other/cancel", "", nil); code != http.StatusNotFound {
+		t.Fatalf("cancel unknown job: status %d", code)
+	}
+	if code := adminRequest(t, srv, http.MethodPost, "/admin/jobs/req-1/cancel", "", nil); code != http.StatusAccepted {
+		t.Fatalf("cancel: status %d", code)
+	}
+	if err := <-done; !errors.Is(err, errJobCancelled) {
+		t.Fatalf("job error = %v, want %v", err, errJobCancelled)
+	}
+}
+
+func TestAdminPauseIntake(t *testing.T) {
+	h, srv := newAdminServer(t, &config.Config{})
+	if code := adminRequest(t, srv, http.MethodPost, "/admin/intake/pause", "", nil); code != http.StatusOK {
+		t.Fatalf("pause intake: status %d", code)
+	}
+	for _, path := range []string{"/admin/uploads/pause", "/admin/intake/stop"} {
+		if code := adminRequest(t, srv, http.MethodPost, path, "", nil); code != http.StatusNotFound {
+			t.Fatalf("%s: status %d", path, code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.UploadHandler(rec, httptest.NewRequest(http.MethodPost, "/upload", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("upload with intake paused: status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	var status struct {
+		Intake     pauseState `json:"intake"`
+		Processing pauseState `json:"processing"`
+	}
+	adminRequest(t, srv, http.MethodGet, "/admin/status", "", &status)
+	if !status.Intake.Paused || status.Intake.Since.IsZero() || status.Processing.Paused {
+		t.Fatalf("status = %+v", status)
+	}
+}
+
+func TestAdminLogLevel(t *testing.T) {
+	h, srv := newAdminServer(t, &config.Config{})
+	var level map[string]string
+	if code := adminRequest(t, srv, http.MethodPut, "/admin/log-level", `{"level":"debug"}`, &level); code != http.StatusOK || level["level"] != "DEBUG" {
+		t.Fatalf("set level: status %d, %v", code, level)
+	}
+	if h.logLevel.Level() != slog.LevelDebug {
+		t.Fatalf("level = %v", h.logLevel.Level())
+	}
+	for _, body := range []string{`{"level":"verbose"}`, `not json`} {
+		if code := adminRequest(t, srv, http.MethodPut, "/admin/log-level", body, nil); code != http.StatusBadRequest {
+			t.Fatalf("%s: status %d", body, code)
+		}
+	}
+	if h.logLevel.Level() != slog.LevelDebug {
+		t.Fatalf("invalid request changed the level to %v", h.logLevel.Level())
+	}
+
+	h.logLevel = nil
+	if code := adminRequest(t, srv, http.MethodGet, "/admin/log-level", "", nil); code != http.StatusServiceUnavailable {
+		t.Fatalf("without adjustable level: status %d", code)
+	}
+}
+
+func TestAdminConfig(t *testing.T) {
+	_, srv := newAdminServer(t, &config.Config{SecretKey: "device-secret", VideoPath: "/data/videos"})
+	var out struct {
+		Config         map[string]any `json:"config"`
+		PendingRestart []string       `json:"pending_restart"`
+	}
+	if code := adminRequest(t, srv, http.MethodGet, "/admin/config", "", &out); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	if out.Config["SecretKey"] != "[REDACTED]" || out.Config["AdminTokens"] != "[REDACTED]" || out.Config["VideoPath"] != "/data/videos" {
+		t.Fatalf("config = %v", out.Config)
+	}
+	if out.PendingRestart == nil || len(out.PendingRestart) != 0 {
+		t.Fatalf("pending restart = %v", out.PendingRestart)
+	}
+}
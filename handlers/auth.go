@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"dvr-upload/utils"
+)
+
+// RequireAdmin protege um handler com os tokens de ADMIN_TOKENS, aceitos em
+// "Authorization: Bearer <token>" ou no header X-Admin-Token.
+func (h *Handler) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.isAdmin(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="dvr-upload"`)
+			utils.WriteJSON(w, http.StatusUnauthorized, utils.JSONResponse{Code: 401, Message: "Unauthorized"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (h *Handler) isAdmin(r *http.Request) bool {
+	token := strings.TrimSpace(r.Header.Get("X-Admin-Token"))
+	if auth := r.Header.Get("Authorization"); token == "" && len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		token = strings.TrimSpace(auth[7:])
+	}
+	if token == "" {
+		return false
+	}
+	for _, candidate := range h.settings().AdminTokens {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
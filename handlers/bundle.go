@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"dvr-upload/processor"
+	"dvr-upload/queue"
+	"dvr-upload/scheduler"
+	"dvr-upload/utils"
+)
+
+// addToBundle entrega o upload processado ao agregador de bundles, se o job for de um tipo agrupável.
+func (h *Handler) addToBundle(job *processJob, item queue.BundleChannel) {
+	if h.bundles == nil || job.imei == "" || job.channel == "" || job.captureTime.IsZero() {
+		return
+	}
+	for _, t := range h.cfg.BundleTypes {
+		if t == "*" || strings.EqualFold(t, job.uploadType) {
+			h.bundles.Add(job.imei, job.uploadType, job.captureTime, item)
+			return
+		}
+	}
+}
+
+func (h *Handler) pendingBundles() int {
+	if h.bundles == nil {
+		return 0
+	}
+	return h.bundles.Pending()
+}
+
+// emitBundle é chamado pelo agregador. Roda em goroutine própria porque o bundle pode ser
+// completado de dentro de processFile, que já ocupa uma vaga de worker.
+func (h *Handler) emitBundle(event queue.BundleEvent) {
+	h.bundlesInFlight.Add(1)
+	go func() {
+		defer h.bundlesInFlight.Done()
+		h.publishBundle(event)
+	}()
+}
+
+// CloseBundles emite os bundles que ainda aguardam canais e espera a publicação deles, até o
+// prazo de ctx. Chamado no desligamento, antes de fechar os sinks de eventos.
+func (h *Handler) CloseBundles(ctx context.Context) {
+	if h.bundles == nil {
+		return
+	}
+	if pending := h.bundles.Pending(); pending > 0 {
+		h.log.Info("Flushing pending event bundles", "pending", pending)
+	}
+	h.bundles.Close()
+
+	done := make(chan struct{})
+	go func() {
+		h.bundlesInFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		h.log.Warn("Timed out waiting for event bundles to be published", "error", ctx.Err())
+	}
+}
+
+func (h *Handler) publishBundle(event queue.BundleEvent) {
+	logger := h.log.With("bundle_id", event.BundleID, "imei", event.IMEI)
+
+	if h.cfg.EnableBundleMosaic {
+		event.Mosaic = h.renderBundleMosaic(event, logger)
+	}
+
+	logger.Info("Event bundle ready",
+		"reason", event.Reason,
+		"complete", event.Complete,
+		"channels", len(event.Channels),
+		"missing_channels", event.Missing)
+
+	h.publishEvent(queue.NewBundleMessage(event), logger)
+}
+
+// renderBundleMosaic gera o vídeo em grade dos canais do bundle. Só é possível quando os
+// vídeos dos canais estão no armazenamento local.
+func (h *Handler) renderBundleMosaic(event queue.BundleEvent, logger *slog.Logger) *queue.UploadEvent {
+	var inputs []string
+	for _, ch := range event.Channels {
+		if ch.Path == "" || strings.ToLower(filepath.Ext(ch.Path)) != ".mp4" {
+			continue
+		}
+		if _, err := os.Stat(ch.Path); err == nil {
+			inputs = append(inputs, ch.Path)
+		}
+	}
+	if len(inputs) < 2 {
+		logger.Warn("Skipping bundle mosaic: fewer than two local MP4 channels", "local_channels", len(inputs))
+		return nil
+	}
+
+	// Nome do mosaico segue o padrão do primeiro canal trocando o canal por "mosaic"
+	first := filepath.Base(inputs[0])
+	base := strings.TrimSuffix(first, filepath.Ext(first))
+	if i := strings.LastIndex(base, "_"); i != -1 {
+		base = base[:i]
+	}
+	mosaicName := base + "_mosaic.mp4"
+	outputPath := filepath.Join(os.TempDir(), mosaicName+"."+event.BundleID+".tmp.mp4")
+	defer os.Remove(outputPath)
+
+	class := h.workers.Classify(scheduler.JobInfo{Type: event.Type, Filename: mosaicName})
+	release := h.workers.Acquire(class, h.schedulingKey(event.IMEI))
+	start := time.Now()
+	err := processor.RenderMosaic(inputs, outputPath, logger)
+	release()
+	if err != nil {
+		logger.Error("Failed to render bundle mosaic", "error", err)
+		return nil
+	}
+
+	stat, err := os.Stat(outputPath)
+	if err != nil {
+		logger.Error("Mosaic rendered but could not stat result", "error", err)
+		return nil
+	}
+	mosaic := &queue.UploadEvent{Filename: mosaicName, Size: stat.Size()}
+
+	if h.cfg.EnableS3Upload {
+		if err := h.storage.UploadFileToS3(outputPath, mosaicName, logger); err != nil {
+			logger.Error("Failed to upload bundle mosaic to S3", "error", err)
+			return nil
+		}
+	}
+	if h.cfg.EnableLocalStorage {
+		localPath := filepath.Join(filepath.Dir(inputs[0]), mosaicName)
+		if err := os.Rename(outputPath, localPath); err != nil {
+			if copyErr := utils.CopyFile(outputPath, localPath); copyErr != nil {
+				logger.Error("Failed to store bundle mosaic locally", "error", copyErr)
+			} else {
+				mosaic.Path = localPath
+			}
+		} else {
+			mosaic.Path = localPath
+		}
+	}
+
+	logger.Info("Bundle mosaic rendered", "filename", mosaicName, "duration", time.Since(start).String())
+	return mosaic
+}
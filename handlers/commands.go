@@ -0,0 +1,391 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"dvr-upload/catalog"
+	"dvr-upload/custody"
+	"dvr-upload/processor"
+	"dvr-upload/queue"
+	"dvr-upload/utils"
+
+	"github.com/google/uuid"
+)
+
+// HandleCommand executa um comando recebido na fila de comandos do RabbitMQ.
+func (h *Handler) HandleCommand(cmd queue.Command) queue.CommandResult {
+	var data interface{}
+	var err error
+	switch cmd.Command {
+	case queue.CommandReprocess:
+		data, err = h.reprocessCommand(cmd)
+	case queue.CommandRepublish:
+		data, err = h.republishCommand(cmd)
+	case queue.CommandDelete:
+		data, err = h.deleteCommand(cmd)
+	case queue.CommandRegenerateThumbnails:
+		data, err = h.regenerateThumbnailsCommand(cmd)
+	default:
+		err = fmt.Errorf("unknown command %q", cmd.Command)
+	}
+
+	if err != nil {
+		return queue.CommandResult{Status: queue.CommandStatusError, Error: err.Error(), Data: data}
+	}
+	return queue.CommandResult{Status: queue.CommandStatusOK, Data: data}
+}
+
+// transcodeProfile busca o perfil pelo nome nos perfis configurados e depois nos embutidos.
+func (h *Handler) transcodeProfile(name string) (*processor.TranscodeProfile, bool) {
+	if name == "" {
+		name = processor.DefaultProfile.Name
+	}
+	for _, p := range h.cfg.TranscodeProfiles {
+		if p.Name == name {
+			return &processor.TranscodeProfile{Name: p.Name, CRF: p.CRF, Preset: p.Preset, MaxHeight: p.MaxHeight}, true
+		}
+	}
+	for _, p := range processor.BuiltinProfiles {
+		if p.Name == name {
+			profile := p
+			return &profile, true
+		}
+	}
+	return nil, false
+}
+
+// commandKey valida a key de um comando: só mídias na raiz do bucket/pasta são aceitas.
+func commandKey(key string) (string, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", errors.New("key is required")
+	}
+	if path.Base(key) != key || key == "." || key == ".." {
+		return "", fmt.Errorf("unsupported key %q", key)
+	}
+	return key, nil
+}
+
+// processingDir é a pasta de processamento isolada usada pelos uploads (fora da pasta final).
+func (h *Handler) processingDir() string {
+	uploadDir := h.cfg.VideoPath
+	if h.cfg.DisasterRecoveryMode && h.cfg.BackupPath != "" {
+		uploadDir = h.cfg.BackupPath
+	}
+	return filepath.Join(filepath.Dir(filepath.Clean(uploadDir)), ".processing_"+filepath.Base(filepath.Clean(uploadDir)))
+}
+
+// fetchMedia copia a mídia da key (local ou do S3) para dst. Retorna o caminho local da mídia, se existir.
+func (h *Handler) fetchMedia(key, dst string) (localPath string, err error) {
+	if p, ok := h.storage.LocalPath(key); ok {
+		return p, utils.CopyFile(p, dst)
+	}
+	if !h.storage.S3Enabled() {
+		return "", fmt.Errorf("media %q not found", key)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	if _, err := h.storage.DownloadToFile(ctx, key, dst); err != nil {
+		return "", fmt.Errorf("failed to download %q: %w", key, err)
+	}
+	return "", nil
+}
+
+func (h *Handler) findRecord(key string) (catalog.Record, bool) {
+	if h.catalog == nil {
+		return catalog.Record{}, false
+	}
+	rec, found, err := h.catalog.FindByKey(key)
+	if err != nil {
+		h.log.Warn("Catalog lookup failed", "key", key, "error", err)
+		return catalog.Record{}, false
+	}
+	return rec, found
+}
+
+// reprocessCommand passa a mídia novamente pelo pipeline com o perfil de encode pedido, substituindo
+// o objeto/arquivo da mesma key. Aguarda o fim do processamento para responder.
+func (h *Handler) reprocessCommand(cmd queue.Command) (interface{}, error) {
+	key, err := commandKey(cmd.Key)
+	if err != nil {
+		return nil, err
+	}
+	profile, ok := h.transcodeProfile(cmd.Profile)
+	if !ok {
+		return nil, fmt.Errorf("unknown transcoding profile %q", cmd.Profile)
+	}
+
+	requestID := uuid.New().String()
+	procDir := h.processingDir()
+	if err := os.MkdirAll(procDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create processing dir: %w", err)
+	}
+	procPath := filepath.Join(procDir, key+"."+requestID+".tmp")
+	// Até o processFile assumir o arquivo, qualquer retorno remove a cópia temporária
+	started := false
+	defer func() {
+		if !started {
+			os.Remove(procPath)
+		}
+	}()
+
+	localPath, err := h.fetchMedia(key, procPath)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := os.Stat(procPath)
+	if err != nil {
+		return nil, err
+	}
+
+	targetFinalPath := localPath
+	if targetFinalPath == "" {
+		targetFinalPath = filepath.Join(h.cfg.VideoPath, key)
+	}
+	job := &processJob{
+		requestID:       requestID,
+		path:            procPath,
+		filename:        key,
+		targetFinalPath: targetFinalPath,
+		logger:          h.log.With("request_id", requestID, "command", cmd.Command, "correlation_id", cmd.CorrelationID),
+		isLocal:         h.cfg.EnableLocalStorage,
+		startTime:       time.Now(),
+		initialSize:     stat.Size(),
+		originalName:    key,
+		profile:         profile,
+		skipWatermark:   true, // a mídia armazenada já saiu do pipeline com watermark
+	}
+	if sum, err := utils.FileSHA256(procPath); err == nil {
+		job.originalSHA256 = sum
+	}
+	previous, found := h.findRecord(key)
+	if found {
+		job.imei = previous.IMEI
+		job.uploadType = previous.Type
+		job.channel = previous.Channel
+		job.captureTime = previous.CaptureTime
+		job.raw = previous.Raw
+		job.recordID = previous.ID
+	}
+	job.fillFromFilename()
+
+	done := make(chan error, 1)
+	job.onDone = func(err error) { done <- err }
+	started = true
+	go h.processFile(job)
+	if err := <-done; err != nil {
+		return map[string]string{"request_id": requestID}, err
+	}
+	return map[string]string{"request_id": requestID, "key": key, "profile": profile.Name}, nil
+}
+
+// republishCommand publica novamente o evento de upload a partir do registro do catálogo.
+func (h *Handler) republishCommand(cmd queue.Command) (interface{}, error) {
+	key, err := commandKey(cmd.Key)
+	if err != nil {
+		return nil, err
+	}
+	if h.catalog == nil {
+		return nil, errors.New("media catalog disabled")
+	}
+	rec, found := h.findRecord(key)
+	if !found {
+		return nil, fmt.Errorf("no catalog record for key %q", key)
+	}
+	if h.events == nil {
+		return nil, errors.New("no event sink configured")
+	}
+
+	event := queue.UploadEvent{
+		RequestID: rec.ID,
+		IMEI:      rec.IMEI,
+		Type:      rec.Type,
+		Channel:   rec.Channel,
+		Filename:  rec.Filename,
+		Size:      rec.Size,
+		Path:      rec.LocalPath,
+		Raw:       rec.Raw,
+	}
+	if err := h.events.Publish(queue.NewUploadMessage(event)); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// deleteResult resume a exclusão por IMEI/intervalo.
+type deleteResult struct {
+	DryRun  bool     `json:"dry_run"`
+	Matched int      `json:"matched"`
+	Deleted []string `json:"deleted"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// deleteCommand remove as mídias (objeto, arquivo local, manifesto, variantes e pacote HLS) e os
+// registros do catálogo de um IMEI dentro do intervalo de captura.
+func (h *Handler) deleteCommand(cmd queue.Command) (interface{}, error) {
+	if h.catalog == nil {
+		return nil, errors.New("media catalog disabled")
+	}
+	imei := strings.TrimSpace(cmd.IMEI)
+	if imei == "" || cmd.From.IsZero() || cmd.To.IsZero() {
+		return nil, errors.New("imei, from and to are required")
+	}
+	if cmd.To.Before(cmd.From) {
+		return nil, errors.New("to must be after from")
+	}
+
+	var records []catalog.Record
+	q := catalog.Query{IMEI: imei, From: cmd.From, To: cmd.To, Limit: catalog.MaxPageSize}
+	for {
+		page, err := h.catalog.Query(q)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, page.Items...)
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+
+	result := deleteResult{DryRun: cmd.DryRun, Matched: len(records), Deleted: []string{}}
+	for _, rec := range records {
+		if cmd.DryRun {
+			result.Deleted = append(result.Deleted, rec.Filename)
+			continue
+		}
+		if errs := h.deleteMedia(rec); len(errs) > 0 {
+			for _, err := range errs {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", rec.Filename, err))
+			}
+			continue
+		}
+		if _, err := h.catalog.Delete(rec.ID); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", rec.Filename, err))
+			continue
+		}
+		result.Deleted = append(result.Deleted, rec.Filename)
+	}
+	if len(result.Errors) > 0 {
+		return result, fmt.Errorf("%d of %d media could not be deleted", len(result.Errors), len(records))
+	}
+	return result, nil
+}
+
+// derivedNames lista os arquivos derivados de uma mídia: manifesto e variantes de imagem.
+func (h *Handler) derivedNames(filename string) []string {
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+	names := []string{filename + custody.ManifestSuffix}
+	formats := append([]string{"jpg"}, h.cfg.ImageExtraFormats...)
+	for _, v := range h.cfg.ImageVariants {
+		for _, format := range formats {
+			names = append(names, base+"_"+v.Name+"."+format)
+		}
+	}
+	return names
+}
+
+func (h *Handler) deleteMedia(rec catalog.Record) []error {
+	var errs []error
+	base := strings.TrimSuffix(rec.Filename, filepath.Ext(rec.Filename))
+
+	if rec.ObjectKey != "" && h.storage.S3Enabled() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+		if err := h.storage.DeleteObject(ctx, rec.ObjectKey); err != nil {
+			errs = append(errs, err)
+		}
+		for _, name := range h.derivedNames(rec.ObjectKey) {
+			// DeleteObject de key inexistente não é erro no S3
+			if err := h.storage.DeleteObject(ctx, name); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if _, err := h.storage.DeletePrefix(ctx, "hls/"+base+"/"); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if rec.LocalPath != "" {
+		dir := filepath.Dir(rec.LocalPath)
+		if err := os.Remove(rec.LocalPath); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+		for _, name := range h.derivedNames(filepath.Base(rec.LocalPath)) {
+			if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+		}
+		if err := os.RemoveAll(filepath.Join(dir, "hls", base)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// regenerateThumbnailsCommand gera novamente as variantes de imagem da key. Para vídeos as
+// variantes são geradas a partir de um quadro representativo.
+func (h *Handler) regenerateThumbnailsCommand(cmd queue.Command) (interface{}, error) {
+	key, err := commandKey(cmd.Key)
+	if err != nil {
+		return nil, err
+	}
+	if len(h.cfg.ImageVariants) == 0 {
+		return nil, errors.New("no image variants configured")
+	}
+
+	procDir := h.processingDir()
+	if err := os.MkdirAll(procDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create processing dir: %w", err)
+	}
+	tmpID := uuid.New().String()
+	srcPath := filepath.Join(procDir, key+"."+tmpID+".tmp")
+	defer os.Remove(srcPath)
+
+	localPath, err := h.fetchMedia(key, srcPath)
+	if err != nil {
+		return nil, err
+	}
+
+	imagePath := srcPath
+	switch strings.ToLower(filepath.Ext(key)) {
+	case ".jpg", ".jpeg":
+	case ".mp4", ".ts":
+		imagePath = srcPath + ".frame.jpg"
+		defer os.Remove(imagePath)
+		if err := processor.ExtractFrame(srcPath, imagePath); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported media type for thumbnails: %q", filepath.Ext(key))
+	}
+
+	logger := h.log.With("command", cmd.Command, "correlation_id", cmd.CorrelationID, "key", key)
+	specs := make([]processor.ImageVariantSpec, 0, len(h.cfg.ImageVariants))
+	for _, v := range h.cfg.ImageVariants {
+		specs = append(specs, processor.ImageVariantSpec{Name: v.Name, MaxSize: v.MaxSize})
+	}
+	variants, err := processor.ProcessImage(imagePath, processor.ImageOptions{
+		EXIFMode:     processor.EXIFKeep,
+		Variants:     specs,
+		Quality:      h.cfg.ImageJPEGQuality,
+		ExtraFormats: h.cfg.ImageExtraFormats,
+		ExtraQuality: h.cfg.ImageExtraQuality,
+	}, logger)
+	if err != nil {
+		for _, v := range variants {
+			os.Remove(v.Path)
+		}
+		return nil, err
+	}
+
+	job := &processJob{isLocal: localPath != "", targetFinalPath: localPath}
+	return h.storeImageVariants(job, variants, key, logger), nil
+}
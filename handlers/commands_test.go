@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"dvr-upload/catalog"
+	"dvr-upload/config"
+	"dvr-upload/queue"
+	"dvr-upload/storage"
+)
+
+// newCommandHandler monta um handler com armazenamento local em um diretório temporário e o
+// catálogo aberto. Retorna o handler e o diretório de vídeos.
+func newCommandHandler(t *testing.T, cfg *config.Config) (*Handler, string) {
+	t.Helper()
+	root := t.TempDir()
+	cfg.VideoPath = filepath.Join(root, "videos")
+	cfg.EnableLocalStorage = true
+	cfg.MaxConcurrentWorkers = 1
+	if err := os.MkdirAll(cfg.VideoPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	media, err := catalog.Open(filepath.Join(root, "catalog.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { media.Close() })
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewHandler(cfg, storage.NewStorageService(cfg, logger), nil, nil, media, logger), cfg.VideoPath
+}
+
+func TestReprocessDoesNotWatermarkAgain(t *testing.T) {
+	// Com o watermark obrigatório, um .ts não convertido falharia se o reprocessamento tentasse marcá-lo
+	h, videos := newCommandHandler(t, &config.Config{
+		EnableWatermark:   true,
+		WatermarkRequired: true,
+		WatermarkTemplate: "IMEI {imei}",
+	})
+	key := "EVENT_123456789012_00000000_2024_05_01_08_30_15_I_1.ts"
+	if err := os.WriteFile(filepath.Join(videos, key), []byte("stored media"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if res := h.HandleCommand(queue.Command{Command: queue.CommandReprocess, Key: key}); res.Status != queue.CommandStatusOK {
+		t.Fatalf("reprocess failed: %s", res.Error)
+	}
+	got, err := os.ReadFile(filepath.Join(videos, key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "stored media" {
+		t.Fatalf("stored media = %q", got)
+	}
+	if leftovers, _ := os.ReadDir(h.processingDir()); len(leftovers) != 0 {
+		t.Fatalf("processing dir not cleaned: %v", leftovers)
+	}
+}
+
+func TestReprocessCleansUpOnError(t *testing.T) {
+	h, _ := newCommandHandler(t, &config.Config{})
+
+	if res := h.HandleCommand(queue.Command{Command: queue.CommandReprocess, Key: "missing.mp4"}); res.Status != queue.CommandStatusError {
+		t.Fatal("reprocess of a missing key succeeded")
+	}
+	if leftovers, _ := os.ReadDir(h.processingDir()); len(leftovers) != 0 {
+		t.Fatalf("processing dir not cleaned: %v", leftovers)
+	}
+}
+
+func TestCommandsReportProcessingDirErrors(t *testing.T) {
+	h, videos := newCommandHandler(t, &config.Config{ImageVariants: []config.ImageVariant{{Name: "thumb", MaxSize: 160}}})
+	key := "123456789012_00_1_0.jpg"
+	if err := os.WriteFile(filepath.Join(videos, key), []byte("stored media"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Um arquivo no lugar do diretório de processamento faz o MkdirAll falhar
+	if err := os.WriteFile(h.processingDir(), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, command := range []string{queue.CommandReprocess, queue.CommandRegenerateThumbnails} {
+		res := h.HandleCommand(queue.Command{Command: command, Key: key})
+		if !strings.Contains(res.Error, "failed to create processing dir") {
+			t.Fatalf("%s: status %s, error %q", command, res.Status, res.Error)
+		}
+	}
+}
+
+func TestDeleteCommand(t *testing.T) {
+	h, videos := newCommandHandler(t, &config.Config{ImageVariants: []config.ImageVariant{{Name: "thumb", MaxSize: 160}}})
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	records := []catalog.Record{
+		{ID: "in-1", IMEI: "123456789012", CaptureTime: day.Add(8 * time.Hour), Filename: "in_1.mp4"},
+		{ID: "in-2", IMEI: "123456789012", CaptureTime: day.Add(23 * time.Hour), Filename: "in_2.jpg"},
+		{ID: "after", IMEI: "123456789012", CaptureTime: day.Add(25 * time.Hour), Filename: "after.mp4"},
+		{ID: "other-imei", IMEI: "999999999999", CaptureTime: day.Add(8 * time.Hour), Filename: "other.mp4"},
+	}
+	for i := range records {
+		rec := &records[i]
+		rec.LocalPath = filepath.Join(videos, rec.Filename)
+		base := strings.TrimSuffix(rec.Filename, filepath.Ext(rec.Filename))
+		// Mídia, manifesto, variante e pacote HLS
+		for _, name := range []string{rec.Filename, rec.Filename + ".manifest.json", base + "_thumb.jpg", filepath.Join("hls", base, "index.m3u8")} {
+			path := filepath.Join(videos, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := h.catalog.Put(*rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	remaining := func() []string {
+		var names []string
+		for _, rec := range records {
+			if _, err := os.Stat(rec.LocalPath); err == nil {
+				names = append(names, rec.Filename)
+			}
+		}
+		return names
+	}
+	cmd := queue.Command{Command: queue.CommandDelete, IMEI: "123456789012", From: day, To: day.Add(24*time.Hour - time.Second), DryRun: true}
+
+	res := h.HandleCommand(cmd)
+	if res.Status != queue.CommandStatusOK {
+		t.Fatalf("dry run failed: %s", res.Error)
+	}
+	got := res.Data.(deleteResult)
+	if !got.DryRun || got.Matched != 2 || len(got.Deleted) != 2 {
+		t.Fatalf("dry run result = %+v", got)
+	}
+	if names := remaining(); len(names) != len(records) {
+		t.Fatalf("dry run removed media, remaining %v", names)
+	}
+	if page, _ := h.catalog.Query(catalog.Query{}); len(page.Items) != len(records) {
+		t.Fatalf("dry run removed catalog records, remaining %d", len(page.Items))
+	}
+
+	cmd.DryRun = false
+	res = h.HandleCommand(cmd)
+	if res.Status != queue.CommandStatusOK {
+		t.Fatalf("delete failed: %s", res.Error)
+	}
+	got = res.Data.(deleteResult)
+	slices.Sort(got.Deleted)
+	if got.DryRun || got.Matched != 2 || strings.Join(got.Deleted, ",") != "in_1.mp4,in_2.jpg" {
+		t.Fatalf("delete result = %+v", got)
+	}
+	if names := strings.Join(remaining(), ","); names != "after.mp4,other.mp4" {
+		t.Fatalf("remaining media = %s", names)
+	}
+	for _, name := range []string{"in_1.mp4.manifest.json", "in_1_thumb.jpg", "hls/in_1", "in_2_thumb.jpg"} {
+		if _, err := os.Stat(filepath.Join(videos, name)); !os.IsNotExist(err) {
+			t.Fatalf("%s not removed: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(videos, "after_thumb.jpg")); err != nil {
+		t.Fatalf("derived file out of range removed: %v", err)
+	}
+	page, err := h.catalog.Query(catalog.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 2 {
+		t.Fatalf("catalog records = %+v", page.Items)
+	}
+}
+
+func TestDeleteCommandValidation(t *testing.T) {
+	h, _ := newCommandHandler(t, &config.Config{})
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		cmd  queue.Command
+		want string
+	}{
+		{name: "no imei", cmd: queue.Command{From: from, To: from.Add(time.Hour)}, want: "imei, from and to are required"},
+		{name: "no range", cmd: queue.Command{IMEI: "123456789012"}, want: "imei, from and to are required"},
+		{name: "inverted range", cmd: queue.Command{IMEI: "123456789012", From: from, To: from.Add(-time.Hour)}, want: "to must be after from"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cmd.Command = queue.CommandDelete
+			if res := h.HandleCommand(tt.cmd); res.Status != queue.CommandStatusError || res.Error != tt.want {
+				t.Fatalf("status %s, error %q; want %q", res.Status, res.Error, tt.want)
+			}
+		})
+	}
+}
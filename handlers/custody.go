@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"dvr-upload/custody"
+	"dvr-upload/storage"
+	"dvr-upload/utils"
+)
+
+// Limite para o manifesto enviado no formulário de verificação.
+const maxManifestSize = 1 << 20
+
+// newManifest inicia o manifesto do job com o artefato original. Retorna nil se a custódia estiver desativada.
+func (h *Handler) newManifest(job *processJob) *custody.Manifest {
+	if h.custodyKey == nil {
+		return nil
+	}
+
+	m := &custody.Manifest{
+		RequestID:  job.requestID,
+		IMEI:       job.imei,
+		ReceivedAt: job.startTime.UTC(),
+	}
+
+	originalName := job.originalName
+	if originalName == "" {
+		originalName = job.filename
+	}
+	if job.originalSHA256 != "" {
+		m.Artifacts = append(m.Artifacts, custody.Artifact{
+			Stage:     custody.StageOriginal,
+			Filename:  originalName,
+			Size:      job.initialSize,
+			SHA256:    job.originalSHA256,
+			CreatedAt: job.startTime.UTC(),
+		})
+	} else {
+		// Arquivos recuperados após crash não têm o hash do stream; o arquivo em processamento ainda é o original
+		h.addArtifact(m, custody.StageOriginal, originalName, job.path, job.logger)
+	}
+	return m
+}
+
+func (h *Handler) addArtifact(m *custody.Manifest, stage, filename, path string, logger *slog.Logger) {
+	if m == nil {
+		return
+	}
+	if _, err := m.AddArtifact(stage, filename, path); err != nil {
+		logger.Error("Failed to hash artifact for custody manifest", "stage", stage, "error", err)
+	}
+}
+
+// storeManifest assina o manifesto e o grava ao lado da mídia: <key>.manifest.json no S3
+// e <arquivo>.manifest.json no armazenamento local.
+func (h *Handler) storeManifest(m *custody.Manifest, filename, localPath string, logger *slog.Logger) {
+	if m == nil {
+		return
+	}
+
+	m.Filename = filename
+	if h.cfg.EnableS3Upload && h.storage.S3Enabled() {
+		m.ObjectKey = filename
+	}
+	if err := m.Sign(h.custodyKey); err != nil {
+		logger.Error("Failed to sign custody manifest", "error", err)
+		return
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		logger.Error("Failed to encode custody manifest", "error", err)
+		return
+	}
+
+	if m.ObjectKey != "" {
+		if err := h.storage.PutObjectBytes(m.ObjectKey+custody.ManifestSuffix, data, logger); err != nil {
+			logger.Error("Failed to upload custody manifest to S3", "error", err)
+		}
+	}
+	if localPath != "" {
+		if err := os.WriteFile(localPath+custody.ManifestSuffix, data, 0644); err != nil {
+			logger.Error("Failed to write custody manifest", "error", err)
+		}
+	}
+}
+
+// loadManifest busca o manifesto de uma key no armazenamento local ou no S3.
+func (h *Handler) loadManifest(ctx context.Context, key string) (*custody.Manifest, error) {
+	manifestKey := key + custody.ManifestSuffix
+	if path, ok := h.storage.LocalPath(manifestKey); ok {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return custody.Parse(f)
+	}
+	if h.storage.S3Enabled() {
+		obj, err := h.storage.GetObject(ctx, manifestKey, storage.GetObjectOptions{})
+		if err != nil {
+			return nil, err
+		}
+		defer obj.Body.Close()
+		return custody.Parse(io.LimitReader(obj.Body, maxManifestSize))
+	}
+	return nil, os.ErrNotExist
+}
+
+// VerifyHandler responde POST /verify (multipart): campo "file" com a mídia a verificar e
+// o manifesto no campo "manifest" ou a "key" da mídia para buscá-lo no armazenamento.
+func (h *Handler) VerifyHandler(w http.ResponseWriter, r *http.Request) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.JSONResponse{Code: 400, Message: "Expected multipart/form-data"})
+		return
+	}
+
+	var fileHash string
+	var fileSize int64
+	var manifestData []byte
+	var key string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.JSONResponse{Code: 400, Message: "Error reading request"})
+			return
+		}
+		switch part.FormName() {
+		case "file":
+			hasher := sha256.New()
+			if fileSize, err = io.Copy(hasher, part); err != nil {
+				utils.WriteJSON(w, http.StatusBadRequest, utils.JSONResponse{Code: 400, Message: "Error reading file"})
+				return
+			}
+			fileHash = hex.EncodeToString(hasher.Sum(nil))
+		case "manifest":
+			if manifestData, err = io.ReadAll(io.LimitReader(part, maxManifestSize)); err != nil {
+				utils.WriteJSON(w, http.StatusBadRequest, utils.JSONResponse{Code: 400, Message: "Error reading manifest"})
+				return
+			}
+		case "key":
+			value, _ := io.ReadAll(io.LimitReader(part, 4096))
+			key = string(value)
+		}
+	}
+
+	if fileHash == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.JSONResponse{Code: 400, Message: "File is required"})
+		return
+	}
+
+	var manifest *custody.Manifest
+	if len(manifestData) > 0 {
+		manifest, err = custody.Parse(bytes.NewReader(manifestData))
+	} else if key != "" {
+		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		defer cancel()
+		manifest, err = h.loadManifest(ctx, key)
+	} else {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.JSONResponse{Code: 400, Message: "Manifest or key is required"})
+		return
+	}
+	if err != nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.JSONResponse{Code: 404, Message: "Manifest not found or invalid"})
+		return
+	}
+
+	result := map[string]interface{}{
+		"sha256":     fileHash,
+		"size":       fileSize,
+		"request_id": manifest.RequestID,
+	}
+	valid := true
+	status := "verified"
+	if h.custodyKey == nil {
+		// Sem chave confiável só há a chave embutida no próprio manifesto, que qualquer um pode
+		// trocar junto com a assinatura: a assinatura não é verificada e o resultado é "unverified"
+		valid = false
+		status = "unverified"
+		result["signature_error"] = "no trusted custody key, CUSTODY_SIGNING_KEY is not set"
+	} else if err := manifest.VerifySignature(h.custodyKey.Public().(ed25519.PublicKey)); err != nil {
+		valid = false
+		status = "failed"
+		result["signature_error"] = err.Error()
+	}
+	if artifact, err := manifest.Match(fileHash); err != nil {
+		valid = false
+		status = "failed"
+		result["hash_error"] = err.Error()
+	} else {
+		result["matched_artifact"] = artifact
+	}
+	result["valid"] = valid
+	result["status"] = status
+
+	message := "verification passed"
+	switch status {
+	case "failed":
+		message = "verification failed"
+	case "unverified":
+		message = "signature not verified, CUSTODY_SIGNING_KEY is not set"
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.JSONResponse{Code: 200, Message: message, Data: result})
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dvr-upload/custody"
+)
+
+func TestVerifyHandler(t *testing.T) {
+	key := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{3}, ed25519.SeedSize))
+	other := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{4}, ed25519.SeedSize))
+
+	media := filepath.Join(t.TempDir(), "video.mp4")
+	if err := os.WriteFile(media, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifest := func(k ed25519.PrivateKey) []byte {
+		m := &custody.Manifest{RequestID: "req-1", Filename: "video.mp4"}
+		if _, err := m.AddArtifact(custody.StageOriginal, "video.mp4", media); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Sign(k); err != nil {
+			t.Fatal(err)
+		}
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	tests := []struct {
+		name       string
+		custodyKey ed25519.PrivateKey
+		file       string
+		manifest   []byte
+		wantCode   int
+		wantStatus string
+		wantValid  bool
+	}{
+		{name: "verified", custodyKey: key, file: "video", manifest: manifest(key), wantCode: 200, wantStatus: "verified", wantValid: true},
+		{name: "signed by another key", custodyKey: key, file: "video", manifest: manifest(other), wantCode: 200, wantStatus: "failed"},
+		{name: "file changed", custodyKey: key, file: "edited video", manifest: manifest(key), wantCode: 200, wantStatus: "failed"},
+		{name: "no trusted key", file: "video", manifest: manifest(key), wantCode: 200, wantStatus: "unverified"},
+		{name: "invalid manifest", custodyKey: key, file: "video", manifest: []byte("{"), wantCode: 404},
+		{name: "no manifest or key", custodyKey: key, file: "video", wantCode: 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			fw, _ := mw.CreateFormFile("file", "video.mp4")
+			fw.Write([]byte(tt.file))
+			if tt.manifest != nil {
+				fw, _ := mw.CreateFormFile("manifest", "video.mp4.manifest.json")
+				fw.Write(tt.manifest)
+			}
+			mw.Close()
+
+			req := httptest.NewRequest(http.MethodPost, "/verify", &body)
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			rec := httptest.NewRecorder()
+			h := &Handler{custodyKey: tt.custodyKey}
+			h.VerifyHandler(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status code = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			if tt.wantStatus == "" {
+				return
+			}
+			var resp struct {
+				Data struct {
+					Status string `json:"status"`
+					Valid  bool   `json:"valid"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Data.Status != tt.wantStatus || resp.Data.Valid != tt.wantValid {
+				t.Fatalf("status = %q, valid = %v; want %q, %v", resp.Data.Status, resp.Data.Valid, tt.wantStatus, tt.wantValid)
+			}
+		})
+	}
+}
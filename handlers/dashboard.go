@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"embed"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"dvr-upload/queue"
+	"dvr-upload/utils"
+)
+
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+// DashboardRoutes registra o dashboard de operação. Os arquivos estáticos são públicos (não contêm
+// dados); os dados e o upload de teste exigem um token de ADMIN_TOKENS.
+func (h *Handler) DashboardRoutes(mux *http.ServeMux) {
+	static, _ := fs.Sub(dashboardFiles, "dashboard")
+	mux.Handle("GET /dashboard/", http.StripPrefix("/dashboard/", http.FileServer(http.FS(static))))
+	mux.Handle("GET /{$}", http.RedirectHandler("/dashboard/", http.StatusFound))
+	mux.HandleFunc("GET /admin/dashboard", h.RequireAdmin(h.DashboardDataHandler))
+	mux.HandleFunc("POST /admin/dashboard/test-upload", h.RequireAdmin(h.DashboardTestUploadHandler))
+}
+
+// deadLetterStatus resume o que não pôde ser entregue: a fila de dead-letter do RabbitMQ e as
+// entregas de webhook que esgotaram as tentativas.
+type deadLetterStatus struct {
+	Queue           string           `json:"queue,omitempty"`
+	Messages        int              `json:"messages"`
+	Error           string           `json:"error,omitempty"`
+	WebhookFailures []queue.Delivery `json:"webhook_failures"`
+}
+
+func (h *Handler) deadLetters() deadLetterStatus {
+	status := deadLetterStatus{Queue: h.cfg.RabbitMQDeadLetterQueue, WebhookFailures: []queue.Delivery{}}
+	if status.Queue != "" {
+		if h.rabbitMQ == nil {
+			status.Error = "rabbitmq unavailable"
+		} else if n, err := h.rabbitMQ.QueueDepth(status.Queue); err != nil {
+			status.Error = err.Error()
+		} else {
+			status.Messages = n
+		}
+	}
+	if l := h.deliveryLogger(); l != nil {
+		status.WebhookFailures = append(status.WebhookFailures, l.Deliveries(queue.DeliveryQuery{Status: queue.DeliveryFailed, Limit: 20})...)
+	}
+	return status
+}
+
+// DashboardDataHandler responde GET /admin/dashboard com tudo que o dashboard exibe.
+func (h *Handler) DashboardDataHandler(w http.ResponseWriter, r *http.Request) {
+	status, health := h.healthReport()
+	utils.WriteJSON(w, http.StatusOK, utils.JSONResponse{Code: 200, Message: "ok", Data: map[string]interface{}{
+		"healthy":            status == http.StatusOK,
+		"health":             health,
+		"intake":             stateOf(&h.intake),
+		"processing":         stateOf(&h.processing),
+		"jobs":               h.jobs.list(),
+		"recent":             h.activity.Recent(50),
+		"devices":            h.activity.Devices(50),
+		"dead_letters":       h.deadLetters(),
+		"registered_devices": h.devices.IMEIs(),
+	}})
+}
+
+// DashboardTestUploadHandler responde POST /admin/dashboard/test-upload. Recebe o arquivo e os campos
+// do formulário, assina com o secret do dispositivo (ou o global) e repassa ao UploadHandler, percorrendo
+// o mesmo caminho de um upload de câmera. O secret nunca chega ao navegador.
+func (h *Handler) DashboardTestUploadHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.JSONResponse{Code: 400, Message: "Expected multipart/form-data"})
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.JSONResponse{Code: 400, Message: "File is required"})
+		return
+	}
+	defer file.Close()
+
+	filename := strings.TrimSpace(r.FormValue("filename"))
+	if filename == "" {
+		filename = header.Filename
+	}
+	imei := strings.TrimSpace(r.FormValue("imei"))
+	timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	fields := [][2]string{
+		{"filename", filename},
+		{"timestamp", timestamp},
+		{"sign", utils.GenerateSign(filename, timestamp, h.signingSecret(imei))},
+		{"imei", imei},
+	}
+	for _, name := range []string{"type", "channel", "datetime"} {
+		if v := strings.TrimSpace(r.FormValue(name)); v != "" {
+			fields = append(fields, [2]string{name, v})
+		}
+	}
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeTestUpload(mw, fields, header.Filename, file))
+	}()
+	defer pr.Close()
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, "/upload", pr)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.JSONResponse{Code: 500, Message: "Internal server error"})
+		return
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.RemoteAddr = r.RemoteAddr
+	h.log.Info("Test upload from dashboard", "filename", filename, "imei", imei, "registered_device", imei != "" && h.isRegistered(imei))
+	h.UploadHandler(w, req)
+}
+
+func writeTestUpload(mw *multipart.Writer, fields [][2]string, filename string, file io.Reader) error {
+	for _, f := range fields {
+		if err := mw.WriteField(f[0], f[1]); err != nil {
+			return err
+		}
+	}
+	part, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return err
+	}
+	return mw.Close()
+}
+
+func (h *Handler) isRegistered(imei string) bool {
+	_, ok := h.devices.Secret(imei)
+	return ok
+}
@@ -0,0 +1,203 @@
+// Dashboard de operação: consome a API de admin com o token salvo no navegador.
+const tokenKey = 'dvr-admin-token';
+const $ = (id) => document.getElementById(id);
+
+function token() {
+    return sessionStorage.getItem(tokenKey) || '';
+}
+
+async function api(path, options = {}) {
+    options.headers = Object.assign({ 'X-Admin-Token': token() }, options.headers || {});
+    const res = await fetch(path, options);
+    const body = await res.json().catch(() => ({ code: res.status, message: res.statusText }));
+    if (res.status === 401) {
+        throw new Error('Token de admin inválido ou ausente');
+    }
+    return body;
+}
+
+function text(value) {
+    const span = document.createElement('span');
+    span.textContent = value === undefined || value === null ? '' : String(value);
+    return span.innerHTML;
+}
+
+function badge(label, kind) {
+    return `<span class="badge ${kind}">${text(label)}</span>`;
+}
+
+function time(value) {
+    if (!value) return '';
+    return new Date(value).toLocaleTimeString();
+}
+
+function size(bytes) {
+    if (!bytes) return '';
+    const units = ['B', 'KB', 'MB', 'GB'];
+    let i = 0;
+    while (bytes >= 1024 && i < units.length - 1) { bytes /= 1024; i++; }
+    return bytes.toFixed(i ? 1 : 0) + ' ' + units[i];
+}
+
+function rows(id, items, render, empty) {
+    $(id).innerHTML = items && items.length
+        ? items.map((item) => '<tr>' + render(item).map((c) => `<td>${c}</td>`).join('') + '</tr>').join('')
+        : `<tr><td class="muted" colspan="8">${empty}</td></tr>`;
+}
+
+const statusKinds = { published: 'ok', uploaded: 'ok', processing: 'warn', rejected: 'bad', failed: 'bad' };
+
+function render(d) {
+    const h = d.health;
+    $('status-dot').className = 'dot ' + (d.healthy ? 'ok' : 'bad');
+    $('status-text').textContent = d.healthy ? 'Operacional' : 'Degradado';
+    $('uptime').textContent = h.uptime;
+    $('total-incoming').textContent = h.total_incoming;
+    $('active-uploads').textContent = h.active_uploads;
+    $('active-processors').textContent = h.active_processors;
+    $('waiting-processors').textContent = h.waiting_processors;
+    $('media-total').textContent = h.media_total_count;
+    $('success-count').textContent = 'OK: ' + h.successful_uploads;
+    $('fail-count').textContent = 'FAIL: ' + h.failed_uploads;
+    $('interrupted-count').textContent = 'INT: ' + h.interrupted_uploads;
+    $('rate-limited-count').textContent = '429: ' + h.rate_limited_uploads;
+    $('avg-camera').textContent = h.metrics.avg_camera_send_time;
+    $('avg-conversion').textContent = h.metrics.avg_conversion_time;
+    $('avg-s3').textContent = h.metrics.avg_s3_upload_time;
+    $('last-at').textContent = h.last_processed_at;
+
+    $('dependencies').innerHTML = Object.entries(h.dependencies).map(([name, status]) => {
+        const kind = status === 'ok' ? 'ok' : (status === 'disabled' ? '' : 'bad');
+        const label = status === 'ok' ? 'online' : (status === 'disabled' ? 'n/a' : 'offline');
+        return `<li><span title="${text(status)}">${text(name)}</span>${badge(label, kind)}</li>`;
+    }).join('');
+    $('intake-state').outerHTML = `<span id="intake-state">${badge(d.intake.paused ? 'pausado' : 'ativo', d.intake.paused ? 'warn' : 'ok')}</span>`;
+    $('processing-state').outerHTML = `<span id="processing-state">${badge(d.processing.paused ? 'pausado' : 'ativo', d.processing.paused ? 'warn' : 'ok')}</span>`;
+
+    const dl = d.dead_letters;
+    $('dead-letters').innerHTML = dl.queue
+        ? `<li>${text(dl.queue)} ${dl.error ? badge(dl.error, 'bad') : badge(dl.messages + ' mensagens', dl.messages ? 'warn' : 'ok')}</li>`
+        : '<li class="muted">Fila de dead-letter não configurada</li>';
+    rows('webhook-failures', dl.webhook_failures, (f) => [text(f.endpoint), text(f.kind), text(f.imei), text(f.attempts), text(f.error)], 'Nenhuma entrega de webhook falhou');
+
+    rows('jobs', d.jobs, (j) => [text(j.request_id.slice(0, 8)), text(j.filename), text(j.imei), text(j.priority_class), badge(j.stage, j.cancelled ? 'bad' : 'warn'), text(j.age)], 'Nenhum job em andamento');
+    rows('recent', d.recent, (u) => [
+        text(time(u.received_at)), text(u.filename), text(u.imei), text(u.type), text(size(u.size)),
+        badge(u.status, statusKinds[u.status] || ''),
+        text(u.reason || (u.stage ? u.stage + ': ' + u.error : '') || (u.warnings || []).join(', ')),
+        text(u.duration),
+    ], 'Nenhum upload recente');
+    rows('devices', d.devices, (v) => [text(v.imei), text(v.received), text(v.uploaded), text(v.rejected), text(v.failed), text(time(v.last_seen))], 'Nenhuma atividade');
+
+    const select = $('imei-select');
+    const current = select.value;
+    const options = ['<option value="">(secret global)</option>'].concat((d.registered_devices || []).map((imei) => `<option value="${text(imei)}">${text(imei)}</option>`));
+    if (select.dataset.options !== options.join('')) {
+        select.innerHTML = options.join('');
+        select.dataset.options = options.join('');
+        select.value = current;
+    }
+}
+
+async function refresh() {
+    if (!token()) {
+        $('error').textContent = 'Informe o token de admin para carregar o dashboard';
+        $('error').classList.remove('hidden');
+        return;
+    }
+    try {
+        const body = await api('/admin/dashboard');
+        render(body.data);
+        $('error').classList.add('hidden');
+    } catch (err) {
+        $('error').textContent = err.message;
+        $('error').classList.remove('hidden');
+    }
+}
+
+$('save-token').onclick = () => {
+    sessionStorage.setItem(tokenKey, $('token').value.trim());
+    $('token').value = '';
+    refresh();
+};
+
+$('upload-form').onsubmit = async (e) => {
+    e.preventDefault();
+    const output = $('upload-output');
+    output.classList.remove('hidden');
+    output.textContent = 'Enviando...';
+    const form = new FormData(e.target);
+    const custom = form.get('imei_custom');
+    form.delete('imei_custom');
+    if (custom) form.set('imei', custom);
+    try {
+        const body = await api('/admin/dashboard/test-upload', { method: 'POST', body: form });
+        output.textContent = JSON.stringify(body, null, 2);
+        setTimeout(refresh, 1000);
+    } catch (err) {
+        output.textContent = 'Error: ' + err.message;
+    }
+};
+
+// Acompanhamento ao vivo via /events/stream (SSE). Usa fetch em vez de EventSource para enviar o token no header.
+let stream = null;
+const streamKinds = {
+    'media.received': 'warn', 'media.signature_verified': 'ok', 'media.converted': 'ok',
+    'media.uploaded': 'ok', 'media.published': 'ok', 'media.rejected': 'bad', 'media.processing_failed': 'bad',
+};
+
+function streamRow(event) {
+    const d = event.data || {};
+    const detail = d.reason || (d.stage ? d.stage + (d.error ? ': ' + d.error : '') : '') || (d.sinks || []).join(', ');
+    const row = document.createElement('tr');
+    row.innerHTML = [text(time(event.time)), badge(event.event, streamKinds[event.event] || ''), text(event.imei), text(d.filename), text(detail)]
+        .map((c) => `<td>${c}</td>`).join('');
+    const body = $('stream');
+    body.prepend(row);
+    while (body.children.length > 50) body.removeChild(body.lastChild);
+}
+
+async function follow(params) {
+    stream = new AbortController();
+    $('stream-toggle').textContent = 'Parar';
+    try {
+        const res = await fetch('/events/stream?' + params, { headers: { 'X-Admin-Token': token() }, signal: stream.signal });
+        if (!res.ok) {
+            const body = await res.json().catch(() => ({ message: res.statusText }));
+            throw new Error(body.message);
+        }
+        const reader = res.body.pipeThrough(new TextDecoderStream()).getReader();
+        let buffer = '';
+        for (;;) {
+            const { value, done } = await reader.read();
+            if (done) break;
+            buffer += value;
+            let idx;
+            while ((idx = buffer.indexOf('\n\n')) >= 0) {
+                const chunk = buffer.slice(0, idx);
+                buffer = buffer.slice(idx + 2);
+                const data = chunk.split('\n').filter((l) => l.startsWith('data: ')).map((l) => l.slice(6)).join('\n');
+                if (data && !chunk.includes('event: dropped')) streamRow(JSON.parse(data));
+            }
+        }
+    } catch (err) {
+        if (err.name !== 'AbortError') {
+            $('error').textContent = 'Stream: ' + err.message;
+            $('error').classList.remove('hidden');
+        }
+    }
+    stream = null;
+    $('stream-toggle').textContent = 'Acompanhar';
+}
+
+$('stream-form').onsubmit = (e) => {
+    e.preventDefault();
+    if (stream) {
+        stream.abort();
+        return;
+    }
+    follow(new URLSearchParams(new FormData(e.target)).toString());
+};
+
+setInterval(refresh, 3000);
+refresh();
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"dvr-upload/config"
+)
+
+func TestDashboardStatic(t *testing.T) {
+	_, srv := newAdminServer(t, &config.Config{})
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	resp, err := client.Get(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/dashboard/" {
+		t.Fatalf("GET / = %d, Location %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	// Os arquivos estáticos não exigem token
+	for _, path := range []string{"/dashboard/", "/dashboard/app.js", "/dashboard/style.css"} {
+		resp, err := client.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || len(body) == 0 {
+			t.Fatalf("GET %s = %d (%d bytes)", path, resp.StatusCode, len(body))
+		}
+	}
+	resp, err = client.Get(srv.URL + "/admin/dashboard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("dashboard data without token: status %d", resp.StatusCode)
+	}
+}
+
+func TestDashboardTestUpload(t *testing.T) {
+	const imei = "123456789012"
+	videos := filepath.Join(t.TempDir(), "videos")
+	h, srv := newAdminServer(t, &config.Config{
+		EnableSecret:       true,
+		SecretKey:          "global-secret",
+		DeviceSecrets:      map[string]string{imei: "device-secret"},
+		EnableLocalStorage: true,
+		VideoPath:          videos,
+	})
+	if err := os.MkdirAll(videos, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("imei", imei)
+	mw.WriteField("filename", "test.mp4")
+	fw, _ := mw.CreateFormFile("file", "clip.mp4")
+	fw.Write([]byte("video"))
+	mw.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/admin/dashboard/test-upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("X-Admin-Token", testAdminToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	// O upload só passa pela verificação de assinatura se foi assinado com o secret do dispositivo
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("test upload: status %d", resp.StatusCode)
+	}
+	if h.signingSecret(imei) != "device-secret" {
+		t.Fatal("registered device does not use its own secret")
+	}
+
+	var data struct {
+		Recent            []UploadActivity `json:"recent"`
+		Devices           []DeviceActivity `json:"devices"`
+		RegisteredDevices []string         `json:"registered_devices"`
+		DeadLetters       deadLetterStatus `json:"dead_letters"`
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data.Recent = nil
+		adminRequest(t, srv, http.MethodGet, "/admin/dashboard", "", &data)
+		if len(data.Recent) == 1 && data.Recent[0].Status == activityUploaded {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("recent = %+v", data.Recent)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if r := data.Recent[0]; r.IMEI != imei || r.Filename != "test.mp4" || r.Size != 5 {
+		t.Fatalf("recent upload = %+v", r)
+	}
+	if len(data.Devices) != 1 || data.Devices[0].IMEI != imei || data.Devices[0].Uploaded != 1 {
+		t.Fatalf("devices = %+v", data.Devices)
+	}
+	if !slices.Equal(data.RegisteredDevices, []string{imei}) || data.DeadLetters.WebhookFailures == nil {
+		t.Fatalf("registered devices %v, dead letters %+v", data.RegisteredDevices, data.DeadLetters)
+	}
+	if stored, err := os.ReadFile(filepath.Join(videos, "test.mp4")); err != nil || string(stored) != "video" {
+		t.Fatalf("stored upload = %q, %v", stored, err)
+	}
+}
+
+func TestDashboardTestUploadRequiresFile(t *testing.T) {
+	_, srv := newAdminServer(t, &config.Config{})
+	for _, tt := range []struct {
+		contentType, body, want string
+	}{
+		{"text/plain", "x", "Expected multipart/form-data"},
+		{"multipart/form-data; boundary=b", "--b\r\nContent-Disposition: form-data; name=\"imei\"\r\n\r\n1\r\n--b--\r\n", "File is required"},
+	} {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/admin/dashboard/test-upload", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		req.Header.Set("X-Admin-Token", testAdminToken)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(msg), tt.want) {
+			t.Fatalf("status %d: %s, want %q", resp.StatusCode, msg, tt.want)
+		}
+	}
+}
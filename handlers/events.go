@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"dvr-upload/queue"
+	"dvr-upload/utils"
+)
+
+// publishEvent entrega a mensagem ao barramento interno e a todos os sinks configurados
+// (RabbitMQ, webhooks...). Retorna os sinks externos que receberam a mensagem e o erro agregado.
+func (h *Handler) publishEvent(msg queue.Message, logger *slog.Logger) ([]string, error) {
+	h.bus.Publish(msg)
+	if h.events == nil {
+		return nil, nil
+	}
+	delivered, err := h.events.Deliver(msg)
+	if err != nil {
+		logger.Error("Failed to publish event", "kind", msg.Kind, "error", err)
+	}
+	return delivered, err
+}
+
+// deliveryLogger retorna o primeiro sink com log de entregas (webhooks), ou nil.
+func (h *Handler) deliveryLogger() queue.DeliveryLogger {
+	if h.events == nil {
+		return nil
+	}
+	for _, s := range h.events.Sinks() {
+		if l, ok := s.(queue.DeliveryLogger); ok {
+			return l
+		}
+	}
+	return nil
+}
+
+// WebhookDeliveriesHandler responde GET /admin/webhooks/deliveries?endpoint=&status=&imei=&limit=
+// com o log de entregas dos webhooks, mais recentes primeiro.
+func (h *Handler) WebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	logSink := h.deliveryLogger()
+	if logSink == nil {
+		utils.WriteJSON(w, http.StatusServiceUnavailable, utils.JSONResponse{Code: 503, Message: "Webhooks disabled"})
+		return
+	}
+
+	params := r.URL.Query()
+	limit := 100
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.JSONResponse{Code: 400, Message: "Invalid limit"})
+			return
+		}
+		limit = n
+	}
+
+	deliveries := logSink.Deliveries(queue.DeliveryQuery{
+		Endpoint: params.Get("endpoint"),
+		Status:   params.Get("status"),
+		IMEI:     params.Get("imei"),
+		Limit:    limit,
+	})
+	utils.WriteJSON(w, http.StatusOK, utils.JSONResponse{Code: 200, Message: "ok", Data: deliveries})
+}
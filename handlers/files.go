@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"dvr-upload/storage"
+	"dvr-upload/utils"
+)
+
+// localSignedURL monta a URL assinada servida pelo próprio serviço em /files/{key}.
+func (h *Handler) localSignedURL(r *http.Request, key string, expiresAt time.Time, filename string) string {
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	params := url.Values{}
+	params.Set("expires", expires)
+	if filename != "" {
+		params.Set("filename", filename)
+	}
+	params.Set("sig", utils.SignURL(h.settings().URLSigningKey, key, expires, filename))
+
+	base := h.cfg.PublicBaseURL
+	if base == "" {
+		scheme := "http"
+		if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host
+	}
+
+	segments := strings.Split(key, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return base + "/files/" + strings.Join(segments, "/") + "?" + params.Encode()
+}
+
+// signedURLsEnabled indica se há chave dedicada para as URLs assinadas locais. Sem ela o acesso a
+// /files exige token de admin: o SECRET_KEY não serve de fallback porque é conhecido pelas câmeras.
+func (h *Handler) signedURLsEnabled() bool {
+	return h.settings().URLSigningKey != ""
+}
+
+// validSignedRequest verifica os parâmetros expires/sig de uma URL gerada por localSignedURL.
+func (h *Handler) validSignedRequest(r *http.Request, key string) bool {
+	if !h.signedURLsEnabled() {
+		return false
+	}
+	params := r.URL.Query()
+	expires := params.Get("expires")
+	sig := params.Get("sig")
+	if expires == "" || sig == "" {
+		return false
+	}
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+	return utils.VerifyURLSignature(h.settings().URLSigningKey, sig, key, expires, params.Get("filename"))
+}
+
+// FilesHandler serve GET /files/{key...} a partir do armazenamento local ou, se o arquivo
+// não existir localmente, do S3. Suporta Range, ETag/Last-Modified e requisições condicionais.
+// O acesso exige token de admin ou uma URL assinada.
+func (h *Handler) FilesHandler(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.PathValue("key"), "/")
+	if key == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.JSONResponse{Code: 400, Message: "Missing key"})
+		return
+	}
+	if !h.isAdmin(r) && !h.validSignedRequest(r, key) {
+		utils.WriteJSON(w, http.StatusForbidden, utils.JSONResponse{Code: 403, Message: "Invalid or expired signature"})
+		return
+	}
+
+	if filename := r.URL.Query().Get("filename"); filename != "" {
+		w.Header().Set("Content-Disposition", storage.ContentDisposition(filename))
+	}
+
+	if path, ok := h.storage.LocalPath(key); ok {
+		h.serveLocalFile(w, r, path, key)
+		return
+	}
+	if h.storage.S3Enabled() {
+		h.serveS3Object(w, r, key)
+		return
+	}
+	w.Header().Del("Content-Disposition")
+	utils.WriteJSON(w, http.StatusNotFound, utils.JSONResponse{Code: 404, Message: "Media not found"})
+}
+
+func (h *Handler) serveLocalFile(w http.ResponseWriter, r *http.Request, path, key string) {
+	f, err := os.Open(path)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.JSONResponse{Code: 404, Message: "Media not found"})
+		return
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.JSONResponse{Code: 500, Message: "Failed to read media"})
+		return
+	}
+
+	// ETag derivado de tamanho e mtime: barato e muda sempre que o arquivo é substituído
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, stat.Size(), stat.ModTime().UnixNano()))
+	w.Header().Set("Content-Type", storage.ContentTypeFor(key))
+	// ServeContent cuida de Range, If-Range, If-None-Match e If-Modified-Since
+	http.ServeContent(w, r, stat.Name(), stat.ModTime(), f)
+}
+
+func (h *Handler) serveS3Object(w http.ResponseWriter, r *http.Request, key string) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	obj, err := h.storage.GetObject(ctx, key, storage.GetObjectOptions{
+		Range:             r.Header.Get("Range"),
+		IfNoneMatch:       r.Header.Get("If-None-Match"),
+		IfModifiedSince:   r.Header.Get("If-Modified-Since"),
+		IfMatch:           r.Header.Get("If-Match"),
+		IfUnmodifiedSince: r.Header.Get("If-Unmodified-Since"),
+	})
+	if err != nil {
+		var statusErr interface{ HTTPStatusCode() int }
+		status := http.StatusBadGateway
+		if errors.As(err, &statusErr) {
+			status = statusErr.HTTPStatusCode()
+		}
+		switch status {
+		case http.StatusNotModified:
+			w.WriteHeader(http.StatusNotModified)
+		case http.StatusPreconditionFailed, http.StatusRequestedRangeNotSatisfiable:
+			w.Header().Del("Content-Disposition")
+			utils.WriteJSON(w, status, utils.JSONResponse{Code: status, Message: http.StatusText(status)})
+		case http.StatusNotFound, http.StatusForbidden:
+			w.Header().Del("Content-Disposition")
+			utils.WriteJSON(w, http.StatusNotFound, utils.JSONResponse{Code: 404, Message: "Media not found"})
+		default:
+			h.log.Error("Failed to stream media from S3", "key", key, "error", err)
+			w.Header().Del("Content-Disposition")
+			utils.WriteJSON(w, http.StatusBadGateway, utils.JSONResponse{Code: 502, Message: "Failed to read media"})
+		}
+		return
+	}
+	defer obj.Body.Close()
+
+	header := w.Header()
+	header.Set("Accept-Ranges", "bytes")
+	header.Set("Content-Type", obj.ContentType)
+	header.Set("Content-Length", strconv.FormatInt(obj.ContentLength, 10))
+	if obj.ETag != "" {
+		header.Set("ETag", obj.ETag)
+	}
+	if !obj.LastModified.IsZero() {
+		header.Set("Last-Modified", obj.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	status := http.StatusOK
+	if obj.ContentRange != "" {
+		header.Set("Content-Range", obj.ContentRange)
+		status = http.StatusPartialContent
+	}
+	w.WriteHeader(status)
+
+	if r.Method == http.MethodHead {
+		return
+	}
+	buffer := make([]byte, 1<<20) // 1MB buffer
+	if _, err := io.CopyBuffer(w, obj.Body, buffer); err != nil {
+		h.log.Warn("Media stream interrupted", "key", key, "error", err)
+	}
+}
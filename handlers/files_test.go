@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"dvr-upload/config"
+)
+
+func newSigningHandler(signingKey string) *Handler {
+	cfg := &config.Config{URLSigningKey: signingKey, PublicBaseURL: "https://dvr.example.com"}
+	h := &Handler{cfg: cfg}
+	h.live.Store(cfg)
+	return h
+}
+
+func TestSignedURLs(t *testing.T) {
+	const key = "2024/05/01/EVENT 1.mp4"
+
+	tests := []struct {
+		name     string
+		verifier string           // URL_SIGNING_KEY de quem verifica
+		expires  time.Duration    // validade a partir de agora
+		tamper   func(u *url.URL) // altera a URL já assinada
+		want     bool
+	}{
+		{name: "valid", verifier: "k1", expires: time.Hour, want: true},
+		{name: "expired", verifier: "k1", expires: -time.Second},
+		{name: "other signing key", verifier: "k2", expires: time.Hour},
+		{name: "signing disabled", verifier: "", expires: time.Hour},
+		{
+			name: "other object", verifier: "k1", expires: time.Hour,
+			tamper: func(u *url.URL) { u.Path = strings.Replace(u.Path, "EVENT 1", "EVENT 2", 1) },
+		},
+		{
+			name: "extended expiry", verifier: "k1", expires: time.Hour,
+			tamper: func(u *url.URL) { setQuery(u, "expires", "99999999999") },
+		},
+		{
+			name: "changed download name", verifier: "k1", expires: time.Hour,
+			tamper: func(u *url.URL) { setQuery(u, "filename", "other.mp4") },
+		},
+		{
+			name: "missing signature", verifier: "k1", expires: time.Hour,
+			tamper: func(u *url.URL) { setQuery(u, "sig", "") },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := newSigningHandler("k1")
+			signed := signer.localSignedURL(httptest.NewRequest("GET", "/media/url", nil), key, time.Now().Add(tt.expires), "evento.mp4")
+			if !strings.HasPrefix(signed, "https://dvr.example.com/files/") {
+				t.Fatalf("unexpected signed URL %s", signed)
+			}
+
+			u, err := url.Parse(signed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.tamper != nil {
+				tt.tamper(u)
+			}
+
+			r := httptest.NewRequest("GET", u.String(), nil)
+			got := newSigningHandler(tt.verifier).validSignedRequest(r, strings.TrimPrefix(u.Path, "/files/"))
+			if got != tt.want {
+				t.Fatalf("validSignedRequest = %v, want %v (url %s)", got, tt.want, u)
+			}
+		})
+	}
+}
+
+func setQuery(u *url.URL, name, value string) {
+	q := u.Query()
+	q.Set(name, value)
+	u.RawQuery = q.Encode()
+}
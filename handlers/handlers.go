@@ -1,6 +1,12 @@
 package handlers
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -10,22 +16,45 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
+	"dvr-upload/bundle"
+	"dvr-upload/catalog"
 	"dvr-upload/config"
+	"dvr-upload/custody"
+	"dvr-upload/devices"
 	"dvr-upload/processor"
 	"dvr-upload/queue"
+	"dvr-upload/rawblock"
+	"dvr-upload/scheduler"
 	"dvr-upload/storage"
+	"dvr-upload/telemetry"
+	"dvr-upload/tracing"
 	"dvr-upload/utils"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Handler struct {
 	cfg                *config.Config
 	storage            *storage.StorageService
 	rabbitMQ           *queue.RabbitMQClient
+	events             *queue.MultiSink
+	bus                *queue.Bus // barramento interno (eventos de ciclo de vida, streaming)
+	jobs               *jobTracker
+	activity           *activityLog
+	streams            *streamLimiter
+	probes             *probeCache
+	live               atomic.Pointer[config.Config] // última configuração carregada (ver settings)
+	devices            *devices.Registry
+	intake             pauseSwitch // recebimento de uploads pausado pela API de admin
+	processing         pauseSwitch // processamento pausado pela API de admin
+	logLevel           *slog.LevelVar
+	catalog            *catalog.Catalog
 	log                *slog.Logger
 	mediaCount         int64
 	successfulUploads  int64
@@ -36,8 +65,14 @@ type Handler struct {
 	waitingProcessors  int64
 	activeProcessors   int64
 	lastUploadTime     int64 // Unix timestamp
+	rateLimitedUploads int64
 	startTime          time.Time
-	workerSemaphore    chan struct{}
+	workers            *scheduler.PriorityScheduler
+	limiter            *scheduler.DeviceLimiter
+	bundles            *bundle.Aggregator
+	bundlesInFlight    sync.WaitGroup // publicações de bundle em andamento (ver emitBundle)
+	custodyKey         ed25519.PrivateKey
+	rawDecoders        *rawblock.Registry
 
 	// Métricas de tempo (em nanosegundos para precisão no atomic)
 	totalConversionTime int64
@@ -48,27 +83,145 @@ type Handler struct {
 	cameraSendCount     int64
 }
 
-func NewHandler(cfg *config.Config, storage *storage.StorageService, rabbitMQ *queue.RabbitMQClient, log *slog.Logger) *Handler {
+func NewHandler(cfg *config.Config, storage *storage.StorageService, rabbitMQ *queue.RabbitMQClient, events *queue.MultiSink, mediaCatalog *catalog.Catalog, log *slog.Logger) *Handler {
 	maxWorkers := cfg.MaxConcurrentWorkers
 	if maxWorkers <= 0 {
 		maxWorkers = 2 // Default seguro
 	}
 
-	return &Handler{
-		cfg:             cfg,
-		storage:         storage,
-		rabbitMQ:        rabbitMQ,
-		log:             log,
-		startTime:       time.Now(),
-		workerSemaphore: make(chan struct{}, maxWorkers),
+	workers, err := scheduler.NewPriorityScheduler(cfg.PriorityClasses, maxWorkers)
+	if err != nil {
+		log.Error("Invalid priority classes, falling back to a single worker pool", "error", err)
+		workers, _ = scheduler.NewPriorityScheduler(nil, maxWorkers)
+	}
+
+	h := &Handler{
+		cfg:       cfg,
+		storage:   storage,
+		rabbitMQ:  rabbitMQ,
+		events:    events,
+		bus:       queue.NewBus(),
+		jobs:      newJobTracker(),
+		activity:  newActivityLog(),
+		streams:   newStreamLimiter(cfg.StreamMaxConnections, cfg.StreamMaxConnectionsPerClient),
+		probes:    newProbeCache(),
+		devices:   devices.NewRegistry(cfg.DeviceSecrets),
+		catalog:   mediaCatalog,
+		log:       log,
+		startTime: time.Now(),
+		workers:   workers,
+		limiter:   scheduler.NewDeviceLimiter(cfg.RateLimitUploadsPerMinute, cfg.RateLimitBytesPerHour),
+	}
+	// Atividade recente (dashboard) alimentada pelo barramento interno
+	h.live.Store(cfg)
+	go h.activity.consume(h.bus.Subscribe(1024, nil))
+	if cfg.EnableRawDecoding {
+		h.rawDecoders = rawblock.DefaultRegistry()
+	}
+	if cfg.EnableCustody {
+		key, err := custody.LoadPrivateKey(cfg.CustodySigningKey)
+		if err != nil {
+			log.Error("Invalid custody signing key, chain of custody manifests disabled", "error", err)
+		} else {
+			h.custodyKey = key
+		}
+	}
+	if cfg.EnableBundles {
+		h.bundles = bundle.NewAggregator(time.Duration(cfg.BundleWindow)*time.Second, cfg.BundleExpectedChannels, h.emitBundle)
+	}
+	return h
+}
+
+// processJob agrupa os dados de um arquivo recebido que segue para processamento assíncrono.
+type processJob struct {
+	requestID       string
+	path            string
+	filename        string
+	targetFinalPath string
+	logger          *slog.Logger
+	isLocal         bool
+	startTime       time.Time
+	initialSize     int64
+	imei            string
+	uploadType      string
+	channel         string
+	captureTime     time.Time
+	originalName    string
+	originalSHA256  string
+	raw             json.RawMessage
+	telemetry       *telemetry.Telemetry
+	profile         *processor.TranscodeProfile // perfil de encode explícito (reprocessamento)
+	recordID        string                      // registro do catálogo atualizado no lugar (reprocessamento)
+	skipWatermark   bool                        // mídia armazenada já saiu com watermark (reprocessamento)
+	onDone          func(err error)             // chamado ao fim do processamento (nil em caso de sucesso)
+	ctx             context.Context             // cancelado pela API de admin; carrega o span do processamento
+}
+
+// fillFromFilename completa IMEI, tipo e canal do job a partir do nome do arquivo quando
+// os campos do formulário não foram enviados.
+func (j *processJob) fillFromFilename() {
+	info, ok := utils.ParseStandardFilename(j.filename)
+	if !ok {
+		return
+	}
+	if j.imei == "" {
+		j.imei = info.IMEI
+	}
+	if j.uploadType == "" {
+		j.uploadType = info.Type
+	}
+	if j.channel == "" {
+		j.channel = info.Channel
 	}
+	if j.captureTime.IsZero() {
+		j.captureTime = info.CaptureTime
+	}
+}
+
+// schedulingKey define a chave usada pelo escalonador justo. Com o escalonamento
+// justo desativado todos os jobs compartilham a mesma chave (FIFO).
+func (h *Handler) schedulingKey(imei string) string {
+	if !h.cfg.EnableFairScheduling {
+		return ""
+	}
+	return imei
+}
+
+// signingSecret retorna o secret de assinatura do dispositivo ou, sem registro, o SECRET_KEY global.
+func (h *Handler) signingSecret(imei string) string {
+	if secret, ok := h.devices.Secret(imei); ok {
+		return secret
+	}
+	return h.settings().SecretKey
+}
+
+// imeiFor resolve o IMEI de um upload pelo campo do formulário ou, na falta dele, pelo nome do arquivo.
+func imeiFor(formIMEI, filename string) string {
+	if imei := strings.TrimSpace(formIMEI); imei != "" {
+		return imei
+	}
+	if info, ok := utils.ParseStandardFilename(filename); ok {
+		return info.IMEI
+	}
+	return ""
 }
 
 func (h *Handler) HealthHandler(w http.ResponseWriter, r *http.Request) {
+	status, report := h.healthReport()
+	utils.WriteJSON(w, status, utils.JSONResponse{
+		Code:    status,
+		Message: "health check report",
+		Data:    report,
+	})
+}
+
+// healthReport monta os contadores e o estado das dependências (usado também pelo dashboard).
+func (h *Handler) healthReport() (int, map[string]interface{}) {
 	mediaCount := atomic.LoadInt64(&h.mediaCount)
 	successCount := atomic.LoadInt64(&h.successfulUploads)
 	failCount := atomic.LoadInt64(&h.failedUploads)
 	interruptedCount := atomic.LoadInt64(&h.interruptedUploads)
+	rateLimitedCount := atomic.LoadInt64(&h.rateLimitedUploads)
 	totalIncoming := atomic.LoadInt64(&h.totalIncoming)
 	activeUploads := atomic.LoadInt64(&h.activeUploads)
 	activeProcessors := atomic.LoadInt64(&h.activeProcessors)
@@ -100,18 +253,15 @@ func (h *Handler) HealthHandler(w http.ResponseWriter, r *http.Request) {
 		avgS3Upload = "0s"
 	}
 
-	s3Status := "ok"
-	if err := h.storage.Ping(); err != nil {
-		s3Status = fmt.Sprintf("error: %v", err)
-	}
-
-	rmqStatus := "ok"
-	if h.rabbitMQ != nil {
-		if err := h.rabbitMQ.Ping(); err != nil {
-			rmqStatus = fmt.Sprintf("error: %v", err)
+	// Estado das dependências vem do cache das verificações em segundo plano (ver probes.go)
+	status := http.StatusOK
+	cached, _ := h.probes.snapshot()
+	dependencies := make(map[string]string, len(cached))
+	for name, dep := range cached {
+		dependencies[name] = dep.healthString()
+		if dep.Status == dependencyError {
+			status = http.StatusServiceUnavailable
 		}
-	} else {
-		rmqStatus = "not_configured"
 	}
 
 	lastProcessed := "never"
@@ -119,281 +269,29 @@ func (h *Handler) HealthHandler(w http.ResponseWriter, r *http.Request) {
 		lastProcessed = time.Unix(lastTime, 0).Format(time.RFC3339)
 	}
 
-	status := http.StatusOK
-	if s3Status != "ok" || (h.rabbitMQ != nil && rmqStatus != "ok") {
-		status = http.StatusServiceUnavailable
-	}
-
-	utils.WriteJSON(w, status, utils.JSONResponse{
-		Code:    status,
-		Message: "health check report",
-		Data: map[string]interface{}{
-			"status":              "running",
-			"uptime":              time.Since(h.startTime).String(),
-			"media_total_count":   mediaCount,
-			"successful_uploads":  successCount,
-			"failed_uploads":      failCount,
-			"interrupted_uploads": interruptedCount,
-			"total_incoming":      totalIncoming,
-			"active_uploads":      activeUploads,
-			"active_processors":   activeProcessors,
-			"waiting_processors":  waitingProcessors,
-			"last_processed_at":   lastProcessed,
-			"metrics": map[string]string{
-				"avg_camera_send_time": avgCameraSend,
-				"avg_conversion_time":  avgConversion,
-				"avg_s3_upload_time":   avgS3Upload,
-			},
-			"dependencies": map[string]string{
-				"s3_storage": s3Status,
-				"rabbitmq":   rmqStatus,
-			},
+	return status, map[string]interface{}{
+		"status":               "running",
+		"uptime":               time.Since(h.startTime).String(),
+		"media_total_count":    mediaCount,
+		"successful_uploads":   successCount,
+		"failed_uploads":       failCount,
+		"interrupted_uploads":  interruptedCount,
+		"rate_limited_uploads": rateLimitedCount,
+		"total_incoming":       totalIncoming,
+		"active_uploads":       activeUploads,
+		"active_processors":    activeProcessors,
+		"waiting_processors":   waitingProcessors,
+		"last_processed_at":    lastProcessed,
+		"metrics": map[string]string{
+			"avg_camera_send_time": avgCameraSend,
+			"avg_conversion_time":  avgConversion,
+			"avg_s3_upload_time":   avgS3Upload,
 		},
-	})
-}
-
-func (h *Handler) TestPageHandler(w http.ResponseWriter, r *http.Request) {
-	html := `<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>DVR Monitor</title>
-    <script src="https://cdn.tailwindcss.com"></script>
-    <link href="https://fonts.googleapis.com/css2?family=Inter:wght@400;500;600;700&display=swap" rel="stylesheet">
-    <style>
-        body { font-family: 'Inter', sans-serif; background-color: #0f172a; color: #f8fafc; }
-        .stat-card { background: #1e293b; border: 1px solid #334155; transition: all 0.3s ease; }
-        .stat-card:hover { border-color: #4f46e5; transform: translateY(-2px); }
-    </style>
-</head>
-<body class="p-4 md:p-8">
-    <div class="max-w-7xl mx-auto">
-        <div class="flex flex-col md:flex-row justify-between items-center mb-10 gap-6">
-            <div>
-                <div class="flex items-center gap-3">
-                    <div class="p-2 bg-indigo-600 rounded-lg">
-                        <svg class="w-6 h-6 text-white" fill="none" stroke="currentColor" viewBox="0 0 24 24"><path stroke-linecap="round" stroke-linejoin="round" stroke-width="2" d="M9 19v-6a2 2 0 00-2-2H5a2 2 0 00-2 2v6a2 2 0 002 2h2a2 2 0 002-2zm0 0V9a2 2 0 012-2h2a2 2 0 012 2v10m-6 0a2 2 0 002 2h2a2 2 0 002-2m0 0V5a2 2 0 012-2h2a2 2 0 012 2v14a2 2 0 01-2 2h-2a2 2 0 01-2-2z"></path></svg>
-                    </div>
-                    <h1 class="text-3xl font-bold text-white tracking-tight">DVR Service Monitor</h1>
-                </div>
-                <p class="text-slate-400 mt-2 ml-1">Performance em tempo real e sincronização em nuvem</p>
-            </div>
-            
-            <div class="flex items-center bg-slate-800/50 p-2 rounded-2xl border border-slate-700">
-                <div class="px-4 border-r border-slate-700">
-                    <div class="flex items-center gap-2">
-                        <span id="status-dot" class="h-2.5 w-2.5 rounded-full bg-green-500 animate-pulse"></span>
-                        <span id="status-text" class="text-xs font-bold uppercase tracking-widest text-slate-300">Operacional</span>
-                    </div>
-                </div>
-                <div class="px-4 text-xs font-mono text-indigo-400">
-                    Up: <span id="uptime">0s</span>
-                </div>
-                <button onclick="refreshData()" class="ml-2 p-2 hover:bg-slate-700 rounded-xl transition-colors text-slate-400 hover:text-white">
-                    <svg class="w-5 h-5" fill="none" stroke="currentColor" viewBox="0 0 24 24"><path stroke-linecap="round" stroke-linejoin="round" stroke-width="2" d="M4 4v5h.582m15.356 2A8.001 8.001 0 004.582 9m0 0H9m11 11v-5h-.581m0 0a8.003 8.003 0 01-15.357-2m15.357 2H15"></path></svg>
-                </button>
-            </div>
-        </div>
-
-        <div class="grid grid-cols-1 md:grid-cols-2 gap-5 mb-8">
-            <div class="bg-indigo-600/10 border border-indigo-500/20 p-6 rounded-3xl flex items-center justify-between">
-                <div>
-                    <p class="text-[10px] font-bold text-indigo-400 uppercase tracking-widest">Total de Requisições</p>
-                    <h2 id="total-incoming" class="text-4xl font-black mt-2 text-white">0</h2>
-                    <p class="text-[10px] text-slate-500 mt-1">Total de arquivos recebidos via POST</p>
-                </div>
-                <div class="bg-indigo-600/20 p-4 rounded-2xl">
-                    <svg class="w-8 h-8 text-indigo-400" fill="none" stroke="currentColor" viewBox="0 0 24 24"><path stroke-linecap="round" stroke-linejoin="round" stroke-width="2" d="M12 4v16m8-8H4"></path></svg>
-                </div>
-            </div>
-
-            <div class="bg-purple-600/10 border border-purple-500/20 p-6 rounded-3xl flex items-center justify-between">
-                <div>
-                    <p class="text-[10px] font-bold text-purple-400 uppercase tracking-widest">Ativos Agora</p>
-                    <div class="flex items-center gap-3 mt-2">
-                        <span class="relative flex h-3 w-3">
-                            <span class="animate-ping absolute inline-flex h-full w-full rounded-full bg-purple-400 opacity-75"></span>
-                            <span class="relative inline-flex rounded-full h-3 w-3 bg-purple-500"></span>
-                        </span>
-                        <h2 id="active-processors" class="text-4xl font-black text-white">0</h2>
-                        <div class="ml-4 border-l border-purple-500/30 pl-4">
-                            <p class="text-[10px] font-bold text-slate-500 uppercase">Aguardando CPU</p>
-                            <p id="waiting-processors" class="text-xl font-bold text-purple-300">0</p>
-                        </div>
-                        <div class="ml-4 border-l border-purple-500/30 pl-4">
-                            <p class="text-[10px] font-bold text-indigo-500 uppercase">Em Transmissão</p>
-                            <p id="active-uploads" class="text-xl font-bold text-indigo-300">0</p>
-                        </div>
-                    </div>
-                </div>
-                <div class="bg-purple-600/20 p-4 rounded-2xl">
-                    <svg class="w-8 h-8 text-purple-400" fill="none" stroke="currentColor" viewBox="0 0 24 24"><path stroke-linecap="round" stroke-linejoin="round" stroke-width="2" d="M13 10V3L4 14h7v7l9-11h-7z"></path></svg>
-                </div>
-            </div>
-        </div>
-
-        <div class="grid grid-cols-1 md:grid-cols-2 lg:grid-cols-4 gap-5 mb-8">
-            <div class="stat-card p-6 rounded-3xl relative overflow-hidden">
-                <p class="text-[10px] font-bold text-slate-500 uppercase tracking-widest">Total de Arquivos</p>
-                <h2 id="media-total" class="text-4xl font-black mt-2 text-white">0</h2>
-                <div class="mt-4 flex items-center gap-2">
-                    <span class="text-[10px] text-green-400 font-bold" id="success-count">OK: 0</span>
-                    <span class="text-[10px] text-red-400 font-bold" id="fail-count">FAIL: 0</span>
-                    <span class="text-[10px] text-orange-400 font-bold" title="Upload da câmera interrompido">INT: <span id="interrupted-count">0</span></span>
-                    <span class="text-[10px] text-slate-400 ml-auto font-bold" id="success-rate">0%</span>
-                </div>
-            </div>
-
-            <div class="stat-card p-6 rounded-3xl">
-                <p class="text-[10px] font-bold text-slate-500 uppercase tracking-widest">Latência Câmera</p>
-                <h2 id="avg-camera" class="text-4xl font-black text-indigo-400 mt-2">0s</h2>
-                <p class="text-[10px] text-slate-500 mt-4 italic">Média de envio da câmera</p>
-            </div>
-
-            <div class="stat-card p-6 rounded-3xl">
-                <p class="text-[10px] font-bold text-slate-500 uppercase tracking-widest">Processamento</p>
-                <h2 id="avg-conversion" class="text-4xl font-black text-purple-400 mt-2">0s</h2>
-                <p class="text-[10px] text-slate-500 mt-4 italic">Média CPU FFmpeg</p>
-            </div>
-
-            <div class="stat-card p-6 rounded-3xl">
-                <p class="text-[10px] font-bold text-slate-500 uppercase tracking-widest">Upload Nuvem</p>
-                <h2 id="avg-s3" class="text-4xl font-black text-emerald-400 mt-2">0s</h2>
-                <p class="text-[10px] text-slate-500 mt-4 italic">Média Latência S3</p>
-            </div>
-        </div>
-
-        <div class="grid grid-cols-1 lg:grid-cols-3 gap-8">
-            <div class="stat-card p-8 rounded-3xl lg:col-span-1">
-                <h3 class="text-sm font-bold mb-6 text-white uppercase tracking-widest">Integridade</h3>
-                <div class="space-y-4">
-                    <div class="flex justify-between items-center p-3 bg-slate-800/40 rounded-xl border border-slate-700/50">
-                        <span class="text-xs text-slate-400">S3 Storage</span>
-                        <span id="badge-s3" class="text-[10px] font-black px-2 py-1 rounded-md uppercase">...</span>
-                    </div>
-                    <div class="flex justify-between items-center p-3 bg-slate-800/40 rounded-xl border border-slate-700/50">
-                        <span class="text-xs text-slate-400">RabbitMQ</span>
-                        <span id="badge-rmq" class="text-[10px] font-black px-2 py-1 rounded-md uppercase">...</span>
-                    </div>
-                    <div class="pt-4 border-t border-slate-700/50 text-right">
-                        <span class="text-[10px] text-slate-500 uppercase tracking-widest">Última Atividade:</span>
-                        <p id="last-at" class="text-[10px] font-mono text-slate-400">Never</p>
-                    </div>
-                </div>
-            </div>
-
-            <div class="stat-card p-8 rounded-3xl lg:col-span-2 bg-gradient-to-br from-slate-800 to-slate-900 border-indigo-500/20">
-                <h3 class="text-sm font-bold mb-6 text-white uppercase tracking-widest">Simular Upload</h3>
-                <form id="uploadForm" class="space-y-4">
-                    <div class="grid grid-cols-2 gap-4">
-                        <input type="file" name="file" required class="col-span-2 text-xs text-slate-400 file:mr-4 file:py-2 file:px-4 file:rounded-lg file:border-0 file:text-[10px] file:font-bold file:bg-indigo-600 file:text-white bg-slate-800/50 rounded-lg p-1">
-                        <input type="text" name="filename" placeholder="Override Filename" class="bg-slate-800/50 border border-slate-700 rounded-lg p-2 text-xs text-white outline-none focus:border-indigo-500">
-                        <input type="text" name="imei" value="864993060014264" class="bg-slate-800/50 border border-slate-700 rounded-lg p-2 text-xs text-white outline-none">
-                    </div>
-                    <button type="submit" class="w-full bg-indigo-600 hover:bg-indigo-500 text-white font-bold py-2 rounded-lg text-xs transition-all active:scale-[0.98]">ENVIAR ARQUIVO</button>
-                    <div id="uploadStatus" class="mt-4 p-3 bg-black/40 rounded-lg hidden border border-white/5 font-mono text-[10px] text-indigo-400">
-                        <pre id="responseOutput" class="whitespace-pre-wrap"></pre>
-                    </div>
-                </form>
-            </div>
-        </div>
-    </div>
-
-    <script src="https://cdnjs.cloudflare.com/ajax/libs/crypto-js/4.1.1/crypto-js.min.js"></script>
-    <script>
-        const secretKey = "{{.SecretKey}}";
-        const enableSecret = {{.EnableSecret}};
-
-        async function refreshData() {
-            try {
-                const res = await fetch('/health');
-                const d = await res.json();
-                const metrics = d.data.metrics;
-                const deps = d.data.dependencies;
-
-                document.getElementById('total-incoming').innerText = d.data.total_incoming || 0;
-                document.getElementById('active-processors').innerText = d.data.active_processors || 0;
-                document.getElementById('waiting-processors').innerText = d.data.waiting_processors || 0;
-                document.getElementById('active-uploads').innerText = d.data.active_uploads || 0;
-                document.getElementById('media-total').innerText = d.data.media_total_count;
-                document.getElementById('success-count').innerText = "OK: " + d.data.successful_uploads;
-                document.getElementById('fail-count').innerText = "FAIL: " + d.data.failed_uploads;
-                document.getElementById('interrupted-count').innerText = d.data.interrupted_uploads || 0;
-                
-                const total = d.data.successful_uploads + d.data.failed_uploads;
-                const rate = total > 0 ? ((d.data.successful_uploads / total) * 100).toFixed(1) : 0;
-                document.getElementById('success-rate').innerText = rate + "%";
-
-                document.getElementById('avg-camera').innerText = metrics.avg_camera_send_time;
-                document.getElementById('avg-conversion').innerText = metrics.avg_conversion_time;
-                document.getElementById('avg-s3').innerText = metrics.avg_s3_upload_time;
-                document.getElementById('uptime').innerText = d.data.uptime;
-                document.getElementById('last-at').innerText = d.data.last_processed_at || 'None';
-
-                updateBadge('badge-s3', deps.s3_storage);
-                updateBadge('badge-rmq', deps.rabbitmq);
-            } catch (err) {}
-        }
-
-        function updateBadge(id, status) {
-            const el = document.getElementById(id);
-            if (status === 'ok') {
-                el.innerText = 'ONLINE';
-                el.className = 'text-[10px] font-black px-2 py-1 bg-green-500/10 text-green-400 rounded-md';
-            } else if (status === 'not_configured') {
-                el.innerText = 'N/A';
-                el.className = 'text-[10px] font-black px-2 py-1 bg-slate-700/50 text-slate-500 rounded-md';
-            } else {
-                el.innerText = 'OFFLINE';
-                el.className = 'text-[10px] font-black px-2 py-1 bg-red-500/10 text-red-500 rounded-md';
-            }
-        }
-
-        document.getElementById('uploadForm').onsubmit = async (e) => {
-            e.preventDefault();
-            const statusDiv = document.getElementById('uploadStatus');
-            const output = document.getElementById('responseOutput');
-            statusDiv.classList.remove('hidden');
-            output.innerText = "Transmitting...";
-            
-            const formData = new FormData(e.target);
-            const ts = Date.now().toString();
-            formData.append('timestamp', ts);
-
-            if (enableSecret) {
-                const fileName = e.target.filename.value || e.target.file.files[0].name;
-                const hash = CryptoJS.MD5(fileName + ts + secretKey).toString();
-                formData.append('sign', btoa(hash));
-            }
-
-            try {
-                const res = await fetch('/upload', { method: 'POST', body: formData });
-                const result = await res.json();
-                output.innerText = JSON.stringify(result, null, 2);
-                setTimeout(refreshData, 1000);
-            } catch (err) {
-                output.innerText = "Error: " + err.message;
-            }
-        }
-
-        setInterval(refreshData, 3000);
-        refreshData();
-    </script>
-</body>
-</html>
-`
-
-	w.Header().Set("Content-Type", "text/html")
-
-	tmpl := strings.ReplaceAll(html, "{{.SecretKey}}", h.cfg.SecretKey)
-	if h.cfg.EnableSecret {
-		tmpl = strings.ReplaceAll(tmpl, "{{.EnableSecret}}", "true")
-	} else {
-		tmpl = strings.ReplaceAll(tmpl, "{{.EnableSecret}}", "false")
+		"rate_limited_devices": h.limiter.Offenders(),
+		"priority_classes":     h.workers.Stats(10),
+		"pending_bundles":      h.pendingBundles(),
+		"dependencies":         dependencies,
 	}
-
-	w.Write([]byte(tmpl))
 }
 
 func (h *Handler) UploadHandler(w http.ResponseWriter, r *http.Request) {
@@ -403,7 +301,16 @@ func (h *Handler) UploadHandler(w http.ResponseWriter, r *http.Request) {
 	requestID := uuid.New().String()
 	resultStatus := "nak" // Default is NAK (Negative Acknowledgement)
 
+	// Span do recebimento; continua o trace do cliente quando a requisição traz traceparent
+	ctx, span := tracing.Tracer().Start(tracing.Extract(r.Context(), r.Header), "receive",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(tracing.AttrRequestID.String(requestID)))
+
 	defer func() {
+		if resultStatus != "ack" {
+			span.SetStatus(codes.Error, "upload rejected")
+		}
+		span.End()
 		atomic.AddInt64(&h.activeUploads, -1)
 		endTime := time.Now()
 		h.log.Info("POST request summary",
@@ -422,11 +329,20 @@ func (h *Handler) UploadHandler(w http.ResponseWriter, r *http.Request) {
 		"uri", r.RequestURI,
 	)
 
+	if h.intake.Paused() {
+		logger.Warn("Upload rejected: intake paused")
+		h.rejectUpload(requestID, "", "", 0, rejectIntakePaused, nil, logger)
+		w.Header().Set("Retry-After", "30")
+		utils.WriteJSON(w, http.StatusServiceUnavailable, utils.JSONResponse{Code: 503, Message: "Intake paused"})
+		return
+	}
+
 	// Usar MultipartReader para processamento mais eficiente de grandes volumes de dados (streaming)
 	reader, err := r.MultipartReader()
 	if err != nil {
 		atomic.AddInt64(&h.failedUploads, 1)
 		logger.Error("Failed to create multipart reader", "error", err)
+		h.rejectUpload(requestID, "", "", 0, rejectInvalidRequest, err, logger)
 		utils.WriteJSON(w, http.StatusBadRequest, utils.JSONResponse{Code: 400, Message: "Expected multipart/form-data"})
 		return
 	}
@@ -443,6 +359,7 @@ func (h *Handler) UploadHandler(w http.ResponseWriter, r *http.Request) {
 
 	var handlerFilename string
 	var handlerSize int64
+	var originalSHA256 string
 	var providedFilename string
 	var timestamp string
 	var sign string
@@ -453,6 +370,8 @@ func (h *Handler) UploadHandler(w http.ResponseWriter, r *http.Request) {
 	var pattern string
 	var raw string
 	var index string
+	var firmware string
+	telemetryFields := make(map[string]string)
 
 	for {
 		part, err := reader.NextPart()
@@ -466,6 +385,7 @@ func (h *Handler) UploadHandler(w http.ResponseWriter, r *http.Request) {
 				break
 			}
 			logger.Error("Failed to read multipart part", "error", err)
+			h.rejectUpload(requestID, imei, handlerFilename, handlerSize, rejectStreamError, err, logger)
 			atomic.AddInt64(&h.failedUploads, 1)
 			utils.WriteJSON(w, http.StatusInternalServerError, utils.JSONResponse{Code: 500, Message: "Error reading upload stream"})
 			return
@@ -496,24 +416,29 @@ func (h *Handler) UploadHandler(w http.ResponseWriter, r *http.Request) {
 			if err != nil {
 				atomic.AddInt64(&h.failedUploads, 1)
 				logger.Error("Failed to create temporary file for streaming", "error", err)
+				h.rejectUpload(requestID, imei, handlerFilename, 0, rejectStorageError, err, logger)
 				utils.WriteJSON(w, http.StatusInternalServerError, utils.JSONResponse{Code: 500, Message: "Internal server error"})
 				return
 			}
 			streamedTempPath = tempFile.Name()
 
 			// Usar um buffer maior para io.Copy para melhorar performance de rede/disco
+			// O SHA-256 dos bytes originais é calculado durante o stream (cadeia de custódia)
+			hasher := sha256.New()
 			buffer := make([]byte, 1<<20) // 1MB buffer
-			n, err := io.CopyBuffer(tempFile, part, buffer)
+			n, err := io.CopyBuffer(io.MultiWriter(tempFile, hasher), part, buffer)
 			if err != nil {
 				atomic.AddInt64(&h.interruptedUploads, 1)
 				tempFile.Close()
 				os.Remove(streamedTempPath)
 				streamedTempPath = "" // Reseta para o defer não tentar remover de novo
 				logger.Error("Error saving file part stream", "bytes_read", n, "error", err)
+				h.rejectUpload(requestID, imei, handlerFilename, n, rejectInterrupted, err, logger)
 				utils.WriteJSON(w, http.StatusInternalServerError, utils.JSONResponse{Code: 500, Message: "Failed to receive file content"})
 				return
 			}
 			handlerSize = n
+			originalSHA256 = hex.EncodeToString(hasher.Sum(nil))
 			tempFile.Close() // Fecha o arquivo pois já terminou a escrita do stream
 
 			// Métrica: Tempo que a câmera levou para enviar o arquivo
@@ -552,12 +477,19 @@ func (h *Handler) UploadHandler(w http.ResponseWriter, r *http.Request) {
 			raw = valStr
 		case "index":
 			index = valStr
+		case "firmware":
+			firmware = valStr
+		case "lat", "lon", "speed", "heading", "alarm", "meta":
+			telemetryFields[formName] = valStr
+		default:
+			logger.Debug("Ignoring unknown form field", "field", formName)
 		}
 	}
 
 	if streamedTempPath == "" {
 		atomic.AddInt64(&h.failedUploads, 1)
 		logger.Error("File is required in the form")
+		h.rejectUpload(requestID, imei, providedFilename, 0, rejectMissingFile, nil, logger)
 		utils.WriteJSON(w, http.StatusBadRequest, utils.JSONResponse{Code: 400, Message: "File is required"})
 		return
 	}
@@ -606,25 +538,65 @@ func (h *Handler) UploadHandler(w http.ResponseWriter, r *http.Request) {
 	if len(finalFilename) > utils.MaxFilenameLength {
 		atomic.AddInt64(&h.failedUploads, 1) // Corrigindo: Incrementa falha para tirar da fila
 		reqLogger.Error("Final filename exceeds max length")
+		h.rejectUpload(requestID, imeiFor(imei, handlerFilename), finalFilename, fileSize, rejectFilenameTooLong, nil, reqLogger)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.JSONResponse{Code: 500, Message: "File name too long"})
 		return
 	}
 
-	if h.cfg.EnableSecret {
+	if h.settings().EnableSecret {
 		baseForSign := providedFilename
 		if strings.TrimSpace(baseForSign) == "" {
 			baseForSign = finalFilename
 		}
-		expected := utils.GenerateSign(baseForSign, timestamp, h.cfg.SecretKey)
+		expected := utils.GenerateSign(baseForSign, timestamp, h.signingSecret(imeiFor(imei, finalFilename)))
 		if sign != expected {
 			atomic.AddInt64(&h.failedUploads, 1)
 			reqLogger.Warn("Invalid signature",
 				"received_sign", sign,
 				"expected_sign", expected,
 				"base_for_sign", baseForSign)
+			h.rejectUpload(requestID, imeiFor(imei, finalFilename), finalFilename, fileSize, rejectInvalidSignature, nil, reqLogger)
 			utils.WriteJSON(w, http.StatusBadRequest, utils.JSONResponse{Code: 400, Message: "Signature error"})
 			return
 		}
+		h.emitProgress(queue.KindSignatureVerified, queue.LifecycleEvent{
+			RequestID: requestID,
+			IMEI:      imeiFor(imei, finalFilename),
+			Filename:  finalFilename,
+			Size:      fileSize,
+		})
+	}
+
+	// Rate limiting por dispositivo: verificado após a assinatura para que requisições forjadas
+	// não consumam a cota de um IMEI legítimo
+	deviceIMEI := imeiFor(imei, finalFilename)
+	if ok, reason := h.limiter.Allow(deviceIMEI, fileSize); !ok {
+		atomic.AddInt64(&h.rateLimitedUploads, 1)
+		reqLogger.Warn("Upload rejected by per-device rate limit", "imei", deviceIMEI, "limit", reason)
+		h.rejectUpload(requestID, deviceIMEI, finalFilename, fileSize, rejectRateLimited, fmt.Errorf("%s limit exceeded", reason), reqLogger)
+		utils.WriteJSON(w, http.StatusTooManyRequests, utils.JSONResponse{Code: 429, Message: "Rate limit exceeded"})
+		return
+	}
+
+	deviceTelemetry, err := telemetry.Parse(telemetryFields)
+	if err != nil {
+		atomic.AddInt64(&h.failedUploads, 1)
+		reqLogger.Warn("Upload rejected: invalid telemetry fields", "error", err)
+		h.rejectUpload(requestID, deviceIMEI, finalFilename, fileSize, rejectInvalidTelemetry, err, reqLogger)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.JSONResponse{Code: 400, Message: "Invalid telemetry: " + err.Error()})
+		return
+	}
+
+	rawDecoded, rawErr := h.decodeRaw(raw, pattern, finalFilename, firmware)
+	if rawErr != nil {
+		if h.cfg.RawRejectInvalid {
+			atomic.AddInt64(&h.failedUploads, 1)
+			reqLogger.Warn("Upload rejected: malformed raw block", "error", rawErr)
+			h.rejectUpload(requestID, deviceIMEI, finalFilename, fileSize, rejectInvalidRawBlock, rawErr, reqLogger)
+			utils.WriteJSON(w, http.StatusBadRequest, utils.JSONResponse{Code: 400, Message: "Invalid raw block"})
+			return
+		}
+		reqLogger.Warn("Could not decode raw block", "error", rawErr)
 	}
 
 	var savedPath string
@@ -636,6 +608,7 @@ func (h *Handler) UploadHandler(w http.ResponseWriter, r *http.Request) {
 		if h.cfg.BackupPath == "" {
 			atomic.AddInt64(&h.failedUploads, 1)
 			reqLogger.Error("Disaster Recovery mode is ON but BACKUP_VIDEO_PATH is not set.")
+			h.rejectUpload(requestID, deviceIMEI, finalFilename, fileSize, rejectStorageError, fmt.Errorf("BACKUP_VIDEO_PATH not set"), reqLogger)
 			utils.WriteJSON(w, http.StatusInternalServerError, utils.JSONResponse{Code: 500, Message: "Disaster recovery misconfigured"})
 			return
 		}
@@ -664,6 +637,7 @@ func (h *Handler) UploadHandler(w http.ResponseWriter, r *http.Request) {
 		if err := utils.CopyFile(streamedTempPath, processingPath); err != nil {
 			atomic.AddInt64(&h.failedUploads, 1)
 			reqLogger.Error("Processing copy failed", "error", err)
+			h.rejectUpload(requestID, deviceIMEI, finalFilename, fileSize, rejectStorageError, err, reqLogger)
 			utils.WriteJSON(w, http.StatusInternalServerError, utils.JSONResponse{Code: 500, Message: "Failed to save file"})
 			return
 		}
@@ -671,28 +645,75 @@ func (h *Handler) UploadHandler(w http.ResponseWriter, r *http.Request) {
 		streamedTempPath = ""
 	}
 
-	go h.processFile(processingPath, finalFilename, savedPath, reqLogger, h.cfg.EnableLocalStorage, startTime, fileSize)
+	job := &processJob{
+		requestID:       requestID,
+		path:            processingPath,
+		filename:        finalFilename,
+		targetFinalPath: savedPath,
+		logger:          reqLogger,
+		isLocal:         h.cfg.EnableLocalStorage,
+		startTime:       startTime,
+		initialSize:     fileSize,
+		imei:            deviceIMEI,
+		uploadType:      strings.ToUpper(strings.TrimSpace(typ)),
+		channel:         strings.TrimSpace(channel),
+		originalName:    handlerFilename,
+		originalSHA256:  originalSHA256,
+		raw:             rawDecoded,
+		telemetry:       deviceTelemetry,
+		ctx:             tracing.Detach(ctx), // o processamento sobrevive à requisição
+	}
+	if datetime != "" {
+		if t, err := utils.ParseDateTime(datetime); err == nil {
+			job.captureTime = t
+		}
+	}
+	job.fillFromFilename()
+	span.SetAttributes(
+		tracing.AttrIMEI.String(job.imei),
+		tracing.AttrType.String(job.uploadType),
+		tracing.AttrFilename.String(finalFilename),
+		tracing.AttrSize.Int64(fileSize),
+		tracing.AttrExt.String(strings.ToLower(filepath.Ext(finalFilename))),
+	)
+	h.emitLifecycle(queue.KindMediaReceived, jobEvent(job, finalFilename, fileSize), reqLogger)
+	go h.processFile(job)
 
 	resultStatus = "ack" // Mark as ACK (Acknowledgement) for the summary log
 	utils.WriteJSON(w, http.StatusOK, utils.JSONResponse{Code: 200, Message: "File upload success", Data: finalFilename})
 }
 
-func (h *Handler) processFile(path string, filename string, targetFinalPath string, logger *slog.Logger, isLocal bool, startTime time.Time, initialSize int64) {
+func (h *Handler) processFile(job *processJob) {
+	filename := job.filename
+	logger := job.logger
+	startTime := job.startTime
+
+	class := h.workers.Classify(scheduler.JobInfo{Type: job.uploadType, Channel: job.channel, Filename: filename})
+	logger = logger.With("priority_class", class)
+
+	h.jobs.track(job, class)
 	atomic.AddInt64(&h.waitingProcessors, 1)
-	// Adquire semáforo para limitar processamento paralelo
-	h.workerSemaphore <- struct{}{}
+	// Aguarda vaga na classe de prioridade (com round-robin entre IMEIs) para limitar processamento paralelo
+	release, err := h.acquireWorker(job, class)
 	atomic.AddInt64(&h.waitingProcessors, -1)
+	if err != nil {
+		h.abandonJob(job, logger)
+		return
+	}
 	atomic.AddInt64(&h.activeProcessors, 1)
 
-	uploadPath := path
+	uploadPath := job.path
 	uploadFilename := filename
-	currentSize := initialSize
+	currentSize := job.initialSize
 	ext := strings.ToLower(filepath.Ext(filename))
+	var failure error // erro final do processamento, repassado a job.onDone
 
 	defer func() {
 		atomic.AddInt64(&h.activeProcessors, -1)
 		if r := recover(); r != nil {
 			logger.Error("Panic in processing goroutine", "panic", r)
+			failure = fmt.Errorf("panic during processing: %v", r)
+			h.processingFailed(job, queue.StagePanic, true, failure, logger)
 		}
 		// Cleanup: remove arquivo de processamento se ainda existir e não for local
 		if uploadPath != "" && strings.Contains(uploadPath, ".processing") {
@@ -700,11 +721,21 @@ func (h *Handler) processFile(path string, filename string, targetFinalPath stri
 				os.Remove(uploadPath)
 			}
 		}
-		<-h.workerSemaphore
+		release()
+		h.jobs.untrack(job)
+		if job.onDone != nil {
+			job.onDone(failure)
+		}
 	}()
 
+	manifest := h.newManifest(job)
+
 	// Conversão opcional TS -> MP4 antes do upload.
 	if ext == ".ts" && h.cfg.EnableTsToMp4 {
+		if !h.enterStage(job, queue.StageConvert, logger) {
+			failure = errJobCancelled
+			return
+		}
 		convStart := time.Now()
 		convertedPath, err := processor.ConvertTSToMP4(uploadPath, logger)
 		if err == nil {
@@ -720,36 +751,59 @@ func (h *Handler) processFile(path string, filename string, targetFinalPath stri
 				uploadPath = convertedPath
 				uploadFilename = strings.TrimSuffix(filename, ext) + ".mp4"
 				ext = ".mp4" // Atualiza extensão para o próximo passo (compressão)
+				h.addArtifact(manifest, custody.StageRemuxed, uploadFilename, uploadPath, logger)
+				converted := jobEvent(job, uploadFilename, currentSize)
+				converted.Stage = queue.StageConvert
+				h.emitProgress(queue.KindMediaConverted, converted)
 			} else {
 				logger.Warn("TS->MP4 conversion succeeded but could not stat result", "error", statErr)
 				os.Remove(convertedPath)
 			}
 		} else {
 			logger.Warn("TS->MP4 conversion failed, will attempt to upload original as TS", "error", err)
+			h.processingFailed(job, queue.StageConvert, false, err, logger)
 			// Se falhar a conversão, mantemos o arquivo original .ts para upload
 		}
 	}
 
-	// Mantém a compressão atual apenas para MP4 (se aplicável).
-	if ext == ".mp4" && h.cfg.EnableCompression {
+	// Mantém a compressão atual apenas para MP4 (se aplicável). O watermark é aplicado no mesmo encode
+	// e um perfil explícito (reprocessamento) força a compressão.
+	watermark := h.watermarkFor(job)
+	if watermark != nil && ext == ".ts" {
+		// O watermark só existe no encode MP4: um TS não convertido (conversão desativada ou com falha) sairia sem ele
+		if failure = h.watermarkNotApplied(job, uploadFilename, currentSize, errWatermarkNeedsMP4, logger); failure != nil {
+			return
+		}
+	}
+	if ext == ".mp4" && (h.cfg.EnableCompression || watermark != nil || job.profile != nil) {
+		if !h.enterStage(job, queue.StageCompress, logger) {
+			failure = errJobCancelled
+			return
+		}
 		compStart := time.Now()
-		compressedPath, err := processor.CompressWithFFmpeg(uploadPath, logger)
+		compressedPath, err := processor.CompressWithFFmpeg(uploadPath, job.profile, watermark, logger)
+		encoded := false
 		if err == nil {
 			// Somar tempo de compressão à métrica de conversão
 			atomic.AddInt64(&h.totalConversionTime, int64(time.Since(compStart)))
 			atomic.AddInt64(&h.conversionCount, 1)
 
 			// Comparar tamanhos: se o comprimido for maior que o original (comum com CRF 0 ou arquivos pequenos),
-			// descartamos o comprimido e usamos o original.
+			// descartamos o comprimido e usamos o original. Com watermark ou perfil explícito o resultado é sempre mantido.
 			origStat, errOrig := os.Stat(uploadPath)
 			compStat, errComp := os.Stat(compressedPath)
 
 			if errOrig == nil && errComp == nil {
-				if compStat.Size() < origStat.Size() && compStat.Size() > 0 {
+				if compStat.Size() > 0 && (watermark != nil || job.profile != nil || compStat.Size() < origStat.Size()) {
 					compSize := compStat.Size()
 					os.Remove(uploadPath)
 					uploadPath = compressedPath
 					currentSize = compSize
+					encoded = true
+					h.addArtifact(manifest, custody.StageCompressed, uploadFilename, uploadPath, logger)
+					compressed := jobEvent(job, uploadFilename, currentSize)
+					compressed.Stage = queue.StageCompress
+					h.emitProgress(queue.KindMediaConverted, compressed)
 				} else {
 					os.Remove(compressedPath)
 				}
@@ -757,13 +811,105 @@ func (h *Handler) processFile(path string, filename string, targetFinalPath stri
 				os.Remove(compressedPath)
 			}
 		}
+
+		// Sem o encode a mídia sairia sem watermark: com WATERMARK_REQUIRED o job falha em vez de enviar o original.
+		// Caminho vazio sem erro é um arquivo sem stream de vídeo, que não tem onde receber o watermark.
+		if watermark != nil && !encoded && (err != nil || compressedPath != "") {
+			if err == nil {
+				err = errors.New("watermarked encode produced no usable output")
+			}
+			if failure = h.watermarkNotApplied(job, uploadFilename, currentSize, err, logger); failure != nil {
+				return
+			}
+		}
+		if err != nil {
+			h.processingFailed(job, queue.StageCompress, false, err, logger)
+		}
+	}
+
+	// Telemetria opcional como faixa de legenda (depois da compressão, que descartaria a faixa)
+	if ext == ".mp4" && h.cfg.EnableTelemetrySubtitle && job.telemetry.Caption() != "" {
+		if !h.enterStage(job, queue.StageSubtitle, logger) {
+			failure = errJobCancelled
+			return
+		}
+		if subtitledPath, err := processor.EmbedSubtitle(uploadPath, job.telemetry.Caption(), logger); err == nil {
+			os.Remove(uploadPath)
+			uploadPath = subtitledPath
+			if stat, statErr := os.Stat(uploadPath); statErr == nil {
+				currentSize = stat.Size()
+			}
+			h.addArtifact(manifest, custody.StageSubtitled, uploadFilename, uploadPath, logger)
+		} else {
+			logger.Warn("Failed to embed telemetry subtitle, uploading without it", "error", err)
+			h.processingFailed(job, queue.StageSubtitle, false, err, logger)
+		}
 	}
 
+	// Snapshots: valida o JPEG, trata o EXIF e gera as variantes antes do upload do original
+	var imageVariants []processor.ImageVariant
+	if h.isSnapshot(ext) {
+		if !h.enterStage(job, queue.StageImage, logger) {
+			failure = errJobCancelled
+			return
+		}
+		variants, err := h.processSnapshot(uploadPath, logger)
+		if err != nil {
+			for _, v := range variants {
+				os.Remove(v.Path)
+			}
+			if h.cfg.ImageRejectInvalid {
+				failure = fmt.Errorf("snapshot rejected: %w", err)
+				logger.Error("Snapshot rejected by image pipeline", "error", err)
+				h.processingFailed(job, queue.StageImage, true, err, logger)
+				atomic.AddInt64(&h.failedUploads, 1)
+				h.recordMedia(job, catalog.Record{
+					Filename: uploadFilename,
+					Size:     currentSize,
+					Status:   catalog.StatusFailed,
+					Error:    err.Error(),
+				}, "")
+				return
+			}
+			logger.Warn("Image pipeline failed, storing original snapshot as received", "error", err)
+			h.processingFailed(job, queue.StageImage, false, err, logger)
+		} else {
+			imageVariants = variants
+			if stat, statErr := os.Stat(uploadPath); statErr == nil {
+				currentSize = stat.Size()
+			}
+			// O tratamento do EXIF pode reescrever o arquivo: o manifesto registra o que será armazenado
+			h.addArtifact(manifest, custody.StageImage, uploadFilename, uploadPath, logger)
+			for _, v := range variants {
+				h.addArtifact(manifest, custody.StageVariant, variantFilename(uploadFilename, v), v.Path, logger)
+			}
+		}
+	}
+
+	// Último ponto de cancelamento: depois do upload o job segue até o fim
+	if !h.enterStage(job, queue.StageS3Upload, logger) {
+		failure = errJobCancelled
+		for _, v := range imageVariants {
+			os.Remove(v.Path)
+		}
+		return
+	}
 	if h.cfg.EnableS3Upload {
 		s3Start := time.Now()
-		if err := h.storage.UploadFileToS3(uploadPath, uploadFilename, logger); err != nil {
+		if err := h.storage.UploadFileToS3WithMetadata(uploadPath, uploadFilename, job.telemetry.Metadata(), logger); err != nil {
+			failure = fmt.Errorf("S3 upload failed: %w", err)
 			logger.Error("Failed to upload to S3", "error", err)
+			h.processingFailed(job, queue.StageS3Upload, true, err, logger)
 			atomic.AddInt64(&h.failedUploads, 1)
+			for _, v := range imageVariants {
+				os.Remove(v.Path)
+			}
+			h.recordMedia(job, catalog.Record{
+				Filename: uploadFilename,
+				Size:     currentSize,
+				Status:   catalog.StatusFailed,
+				Error:    err.Error(),
+			}, "")
 			return
 		}
 		// Métrica: Sucesso no upload S3
@@ -775,12 +921,26 @@ func (h *Handler) processFile(path string, filename string, targetFinalPath stri
 		atomic.AddInt64(&h.successfulUploads, 1)
 	}
 
+	var variantInfo []queue.ImageVariant
+	if len(imageVariants) > 0 {
+		h.jobs.setStage(job, queue.StageImage)
+		variantInfo = h.storeImageVariants(job, imageVariants, uploadFilename, logger)
+	}
+
+	// Estágio opcional de empacotamento HLS (gravações longas)
+	var hlsInfo *queue.HLSInfo
+	if h.shouldPackageHLS(job, uploadPath, ext, logger) {
+		h.jobs.setStage(job, queue.StageHLS)
+		hlsInfo = h.packageHLS(job, uploadPath, uploadFilename, logger)
+	}
+
 	atomic.StoreInt64(&h.lastUploadTime, time.Now().Unix())
 
 	finalDestPath := uploadPath
-	if isLocal {
+	if job.isLocal {
+		h.jobs.setStage(job, queue.StageLocalMove)
 		// Define o caminho final baseado no nome final do arquivo (pode ter mudado de .ts para .mp4)
-		finalDestPath = filepath.Join(filepath.Dir(targetFinalPath), uploadFilename)
+		finalDestPath = filepath.Join(filepath.Dir(job.targetFinalPath), uploadFilename)
 
 		// Se o diretório destino não existe, cria (caso tenha sido removido por outro serviço)
 		os.MkdirAll(filepath.Dir(finalDestPath), 0755)
@@ -791,6 +951,7 @@ func (h *Handler) processFile(path string, filename string, targetFinalPath stri
 				os.Remove(uploadPath)
 			} else {
 				logger.Error("Final move/copy failed", "error", copyErr)
+				h.processingFailed(job, queue.StageLocalMove, false, copyErr, logger)
 				finalDestPath = uploadPath
 			}
 		}
@@ -798,19 +959,60 @@ func (h *Handler) processFile(path string, filename string, targetFinalPath stri
 		finalDestPath = ""
 	}
 
+	h.storeManifest(manifest, uploadFilename, finalDestPath, logger)
+
 	// Incrementa contador e dispara evento RabbitMQ apenas após sucesso no upload
 	atomic.AddInt64(&h.mediaCount, 1)
 
-	if h.cfg.EnableRabbitMQ && h.rabbitMQ != nil {
-		err := h.rabbitMQ.PublishEvent(queue.UploadEvent{
-			Filename: uploadFilename,
-			Size:     currentSize,
-			Path:     finalDestPath,
-		})
-		if err != nil {
-			logger.Error("Failed to publish event to RabbitMQ after processing", "error", err)
-		}
+	record := catalog.Record{
+		Filename:  uploadFilename,
+		LocalPath: finalDestPath,
+		Size:      currentSize,
+		Status:    catalog.StatusStored,
+	}
+	if h.cfg.EnableS3Upload && h.storage.S3Enabled() {
+		record.ObjectKey = uploadFilename
+		record.Status = catalog.StatusUploaded
+	}
+	mediaPath := finalDestPath
+	if mediaPath == "" {
+		// Sem armazenamento local o arquivo temporário ainda existe até o defer de limpeza
+		mediaPath = uploadPath
 	}
+	h.recordMedia(job, record, mediaPath)
+
+	h.jobs.setStage(job, queue.StagePublish)
+	uploaded := queue.NewUploadMessage(queue.UploadEvent{
+		RequestID: job.requestID,
+		IMEI:      job.imei,
+		Type:      job.uploadType,
+		Channel:   job.channel,
+		Filename:  uploadFilename,
+		Size:      currentSize,
+		Path:      finalDestPath,
+		HLS:       hlsInfo,
+		Variants:  variantInfo,
+		Raw:       job.raw,
+		Telemetry: job.telemetry,
+	})
+	uploaded.Trace = tracing.Inject(h.jobs.traceContext(job))
+	delivered, publishErr := h.publishEvent(uploaded, logger)
+	if publishErr != nil {
+		h.processingFailed(job, queue.StagePublish, false, publishErr, logger)
+	}
+	if len(delivered) > 0 {
+		published := jobEvent(job, uploadFilename, currentSize)
+		published.Sinks = delivered
+		h.emitLifecycle(queue.KindMediaPublished, published, logger)
+	}
+
+	h.addToBundle(job, queue.BundleChannel{
+		Channel:   job.channel,
+		Filename:  uploadFilename,
+		Size:      currentSize,
+		Path:      finalDestPath,
+		ObjectKey: record.ObjectKey,
+	})
 	logger.Info("Upload and processing finished",
 		"filename", uploadFilename,
 		"size", currentSize,
@@ -852,11 +1054,16 @@ func (h *Handler) StartRecoveryTask() {
 				if time.Since(info.ModTime()) < 10*time.Second {
 					continue
 				}
+				// Em uma varredura sob demanda o arquivo pode pertencer a um job em andamento
+				if h.jobs.hasPath(filePath) {
+					continue
+				}
 
 				logger.Info("Recovering orphaned file", "file", entry.Name())
 
 				// Tenta recuperar o nome original (remove .UUID.tmp do final)
 				originalName := entry.Name()
+				requestID := ""
 				if strings.HasSuffix(originalName, ".tmp") {
 					tempName := strings.TrimSuffix(originalName, ".tmp")
 					lastDot := strings.LastIndex(tempName, ".")
@@ -866,6 +1073,7 @@ func (h *Handler) StartRecoveryTask() {
 						uuidPart := tempName[lastDot+1:]
 						if len(uuidPart) == 36 || len(uuidPart) == 32 {
 							originalName = tempName[:lastDot]
+							requestID = uuidPart
 						}
 					}
 				}
@@ -877,7 +1085,27 @@ func (h *Handler) StartRecoveryTask() {
 				// No novo modelo, procDir está fora da pasta final, então usamos basePath diretamente
 				targetFinalPath := filepath.Join(basePath, originalName)
 
-				go h.processFile(filePath, originalName, targetFinalPath, logger, isLocal, time.Now(), info.Size())
+				if requestID == "" {
+					requestID = uuid.New().String()
+				}
+
+				job := &processJob{
+					requestID:       requestID,
+					path:            filePath,
+					filename:        originalName,
+					targetFinalPath: targetFinalPath,
+					logger:          logger,
+					isLocal:         isLocal,
+					startTime:       time.Now(),
+					initialSize:     info.Size(),
+				}
+				job.fillFromFilename()
+				if raw, err := h.decodeRaw("", "", originalName, ""); err == nil {
+					job.raw = raw
+				} else {
+					logger.Warn("Could not decode raw block", "error", err)
+				}
+				go h.processFile(job)
 			}
 		}
 	}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"dvr-upload/processor"
+	"dvr-upload/queue"
+	"dvr-upload/utils"
+)
+
+// shouldPackageHLS decide se o upload passa pelo estágio HLS (tipo configurado e duração mínima).
+func (h *Handler) shouldPackageHLS(job *processJob, mediaPath, ext string, logger *slog.Logger) bool {
+	if !h.cfg.EnableHLS || (ext != ".mp4" && ext != ".ts") {
+		return false
+	}
+
+	typeMatches := false
+	for _, t := range h.cfg.HLSTypes {
+		if t == "*" || strings.EqualFold(t, job.uploadType) {
+			typeMatches = true
+			break
+		}
+	}
+	if !typeMatches {
+		return false
+	}
+
+	if h.cfg.HLSMinDuration > 0 {
+		duration, err := processor.ProbeDuration(mediaPath)
+		if err != nil {
+			logger.Warn("Could not probe duration for HLS packaging, skipping", "error", err)
+			return false
+		}
+		if duration < float64(h.cfg.HLSMinDuration) {
+			return false
+		}
+	}
+	return true
+}
+
+// packageHLS gera o pacote HLS do vídeo, envia para o S3 sob hls/<nome>/ e, com armazenamento
+// local, copia para a mesma estrutura ao lado do arquivo final. Falhas não interrompem o upload principal.
+func (h *Handler) packageHLS(job *processJob, mediaPath, mediaFilename string, logger *slog.Logger) *queue.HLSInfo {
+	baseName := strings.TrimSuffix(mediaFilename, filepath.Ext(mediaFilename))
+	prefix := "hls/" + baseName
+	outputDir := mediaPath + ".hls"
+	defer os.RemoveAll(outputDir)
+
+	result, err := processor.PackageHLS(mediaPath, outputDir, processor.HLSOptions{
+		SegmentDuration: h.cfg.HLSSegmentDuration,
+		SegmentFormat:   h.cfg.HLSSegmentFormat,
+		Renditions:      h.cfg.HLSRenditions,
+	}, logger)
+	if err != nil {
+		logger.Error("HLS packaging failed", "error", err)
+		h.processingFailed(job, queue.StageHLS, false, err, logger)
+		return nil
+	}
+
+	info := &queue.HLSInfo{
+		Prefix:     prefix + "/",
+		Playlist:   prefix + "/" + result.Playlist,
+		Renditions: result.Renditions,
+	}
+
+	if h.cfg.EnableS3Upload {
+		keys, err := h.storage.UploadDirToS3(outputDir, prefix, logger)
+		if err != nil {
+			logger.Error("Failed to upload HLS package to S3", "error", err, "uploaded_keys", len(keys))
+			h.processingFailed(job, queue.StageHLS, false, err, logger)
+			return nil
+		}
+	}
+
+	if job.isLocal {
+		localDir := filepath.Join(filepath.Dir(job.targetFinalPath), "hls", baseName)
+		if err := copyDir(outputDir, localDir); err != nil {
+			logger.Error("Failed to store HLS package locally", "error", err, "path", localDir)
+		} else {
+			info.LocalPath = localDir
+		}
+	}
+
+	return info
+}
+
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		return utils.CopyFile(path, target)
+	})
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dvr-upload/config"
+)
+
+func TestShouldPackageHLS(t *testing.T) {
+	tests := []struct {
+		name       string
+		enabled    bool
+		types      []string
+		uploadType string
+		ext        string
+		want       bool
+	}{
+		{name: "disabled", types: []string{"*"}, uploadType: "F", ext: ".mp4"},
+		{name: "configured type", enabled: true, types: []string{"F"}, uploadType: "F", ext: ".mp4", want: true},
+		{name: "type is case insensitive", enabled: true, types: []string{"f"}, uploadType: "F", ext: ".ts", want: true},
+		{name: "other type", enabled: true, types: []string{"F"}, uploadType: "I", ext: ".mp4"},
+		{name: "wildcard", enabled: true, types: []string{"*"}, uploadType: "I", ext: ".mp4", want: true},
+		{name: "snapshot", enabled: true, types: []string{"*"}, uploadType: "F", ext: ".jpg"},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{cfg: &config.Config{EnableHLS: tt.enabled, HLSTypes: tt.types}}
+			job := &processJob{uploadType: tt.uploadType}
+			if got := h.shouldPackageHLS(job, "video"+tt.ext, tt.ext, logger); got != tt.want {
+				t.Fatalf("shouldPackageHLS = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShouldPackageHLSMinDurationWithoutProbe(t *testing.T) {
+	// Sem ffprobe no PATH a duração não pode ser medida e o estágio é pulado
+	t.Setenv("PATH", t.TempDir())
+	h := &Handler{cfg: &config.Config{EnableHLS: true, HLSTypes: []string{"*"}, HLSMinDuration: 30}}
+	if h.shouldPackageHLS(&processJob{uploadType: "F"}, "video.mp4", ".mp4", slog.New(slog.NewTextHandler(io.Discard, nil))) {
+		t.Fatal("HLS packaging without a probed duration")
+	}
+}
+
+func TestCopyDir(t *testing.T) {
+	src := t.TempDir()
+	files := map[string]string{
+		"index.m3u8":            "#EXTM3U\n",
+		"source/index.m3u8":     "#EXTM3U\nseg_00000.ts\n",
+		"source/seg_00000.ts":   "segment",
+		"low/seg_00000.m4s":     "segment",
+		"low/init.mp4":          "init",
+		"low/nested/extra.m3u8": "#EXTM3U\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dst := filepath.Join(t.TempDir(), "hls", "video")
+	if err := copyDir(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		got, err := os.ReadFile(filepath.Join(dst, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != content {
+			t.Fatalf("%s = %q, want %q", name, got, content)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"dvr-upload/processor"
+	"dvr-upload/queue"
+	"dvr-upload/utils"
+)
+
+// isSnapshot indica se o arquivo passa pelo pipeline de imagens.
+func (h *Handler) isSnapshot(ext string) bool {
+	return h.cfg.EnableImagePipeline && (ext == ".jpg" || ext == ".jpeg")
+}
+
+// processSnapshot valida o JPEG, trata o EXIF do original no lugar e gera as variantes configuradas
+// ao lado do arquivo temporário.
+func (h *Handler) processSnapshot(mediaPath string, logger *slog.Logger) ([]processor.ImageVariant, error) {
+	variants := make([]processor.ImageVariantSpec, 0, len(h.cfg.ImageVariants))
+	for _, v := range h.cfg.ImageVariants {
+		variants = append(variants, processor.ImageVariantSpec{Name: v.Name, MaxSize: v.MaxSize})
+	}
+	return processor.ProcessImage(mediaPath, processor.ImageOptions{
+		EXIFMode:     h.cfg.ImageEXIFMode,
+		Variants:     variants,
+		Quality:      h.cfg.ImageJPEGQuality,
+		ExtraFormats: h.cfg.ImageExtraFormats,
+		ExtraQuality: h.cfg.ImageExtraQuality,
+	}, logger)
+}
+
+// variantFilename é o nome com que a variante é armazenada: <base>_<nome>.<formato>.
+func variantFilename(mediaFilename string, v processor.ImageVariant) string {
+	return strings.TrimSuffix(mediaFilename, filepath.Ext(mediaFilename)) + "_" + v.Name + "." + v.Format
+}
+
+// storeImageVariants envia as variantes para o S3 (<base>_<nome>.<formato>) e, com armazenamento local,
+// move para o lado do arquivo final. Os temporários são sempre removidos; falhas não interrompem o upload principal.
+func (h *Handler) storeImageVariants(job *processJob, variants []processor.ImageVariant, mediaFilename string, logger *slog.Logger) []queue.ImageVariant {
+	var stored []queue.ImageVariant
+
+	for _, v := range variants {
+		info := queue.ImageVariant{
+			Name:     v.Name,
+			Format:   v.Format,
+			Filename: variantFilename(mediaFilename, v),
+			Width:    v.Width,
+			Height:   v.Height,
+			Size:     v.Size,
+		}
+
+		if h.cfg.EnableS3Upload {
+			if err := h.storage.UploadFileToS3(v.Path, info.Filename, logger); err != nil {
+				logger.Error("Failed to upload image variant to S3", "error", err, "variant", info.Filename)
+				os.Remove(v.Path)
+				continue
+			}
+			if h.storage.S3Enabled() {
+				info.Key = info.Filename
+			}
+		}
+
+		if job.isLocal {
+			dest := filepath.Join(filepath.Dir(job.targetFinalPath), info.Filename)
+			if err := os.Rename(v.Path, dest); err != nil {
+				if copyErr := utils.CopyFile(v.Path, dest); copyErr != nil {
+					logger.Error("Failed to store image variant locally", "error", copyErr, "path", dest)
+				} else {
+					info.Path = dest
+				}
+			} else {
+				info.Path = dest
+			}
+		}
+		os.Remove(v.Path)
+		stored = append(stored, info)
+	}
+	return stored
+}
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"dvr-upload/tracing"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Estágios de um job antes do processamento propriamente dito (os demais seguem queue.Stage*).
+const (
+	stageQueued = "queued"
+	stagePaused = "paused"
+)
+
+var errJobCancelled = errors.New("job cancelled by admin")
+
+// JobStatus é a visão de um job em andamento exposta pela API de admin.
+type JobStatus struct {
+	RequestID  string    `json:"request_id"`
+	Filename   string    `json:"filename"`
+	IMEI       string    `json:"imei,omitempty"`
+	Type       string    `json:"type,omitempty"`
+	Channel    string    `json:"channel,omitempty"`
+	Class      string    `json:"priority_class,omitempty"`
+	Stage      string    `json:"stage"`
+	Size       int64     `json:"size"`
+	QueuedAt   time.Time `json:"queued_at"`
+	StageSince time.Time `json:"stage_since"`
+	Age        string    `json:"age"`
+	Cancelled  bool      `json:"cancelled,omitempty"`
+}
+
+type trackedJob struct {
+	job        *processJob
+	class      string
+	stage      string
+	queuedAt   time.Time
+	stageSince time.Time
+	cancel     context.CancelFunc
+	span       trace.Span // span do processamento inteiro
+	stageSpan  trace.Span // span do estágio atual (filho de span)
+}
+
+// jobTracker acompanha os jobs entre o recebimento e o fim do processamento.
+type jobTracker struct {
+	mu   sync.Mutex
+	jobs map[*processJob]*trackedJob
+}
+
+func newJobTracker() *jobTracker {
+	return &jobTracker{jobs: make(map[*processJob]*trackedJob)}
+}
+
+// track registra o job e associa a ele um contexto cancelável pela API de admin. O contexto carrega
+// o span do processamento, filho do span do recebimento quando job.ctx já traz um (ver UploadHandler).
+func (t *jobTracker) track(job *processJob, class string) {
+	now := time.Now()
+	parent := job.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, span := tracing.Tracer().Start(parent, "process", trace.WithAttributes(
+		tracing.AttrRequestID.String(job.requestID),
+		tracing.AttrIMEI.String(job.imei),
+		tracing.AttrType.String(job.uploadType),
+		tracing.AttrChannel.String(job.channel),
+		tracing.AttrFilename.String(job.filename),
+		tracing.AttrSize.Int64(job.initialSize),
+		tracing.AttrExt.String(strings.ToLower(filepath.Ext(job.filename))),
+		tracing.AttrClass.String(class),
+	))
+	ctx, cancel := context.WithCancel(ctx)
+	job.ctx = ctx
+	tj := &trackedJob{job: job, class: class, queuedAt: now, cancel: cancel, span: span}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.startStage(tj, stageQueued, now)
+	t.jobs[job] = tj
+}
+
+func (t *jobTracker) untrack(job *processJob) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if tj, ok := t.jobs[job]; ok {
+		tj.cancel()
+		tj.stageSpan.End()
+		tj.span.End()
+		delete(t.jobs, job)
+	}
+}
+
+func (t *jobTracker) setStage(job *processJob, stage string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if tj, ok := t.jobs[job]; ok && tj.stage != stage {
+		tj.stageSpan.End()
+		t.startStage(tj, stage, time.Now())
+	}
+}
+
+// startStage troca o estágio do job e abre o span dele. Chamado com t.mu travado.
+func (t *jobTracker) startStage(tj *trackedJob, stage string, now time.Time) {
+	tj.stage = stage
+	tj.stageSince = now
+	_, tj.stageSpan = tracing.Tracer().Start(trace.ContextWithSpan(context.Background(), tj.span), stage,
+		trace.WithTimestamp(now), trace.WithAttributes(tracing.AttrStage.String(stage)))
+}
+
+// traceContext retorna um contexto com o span do estágio atual, usado para propagar o trace nas
+// mensagens publicadas pelo job.
+func (t *jobTracker) traceContext(job *processJob) context.Context {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if tj, ok := t.jobs[job]; ok {
+		return trace.ContextWithSpan(context.Background(), tj.stageSpan)
+	}
+	return context.Background()
+}
+
+// recordFailure registra a falha de um estágio no span dele. Falhas fatais marcam também o span
+// do processamento como erro.
+func (t *jobTracker) recordFailure(job *processJob, stage string, fatal bool, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tj, ok := t.jobs[job]
+	if !ok || err == nil {
+		return
+	}
+	span := tj.span
+	if tj.stage == stage {
+		span = tj.stageSpan
+	}
+	span.RecordError(err, trace.WithAttributes(tracing.AttrStage.String(stage)))
+	if fatal {
+		tj.stageSpan.SetStatus(codes.Error, err.Error())
+		tj.span.SetStatus(codes.Error, stage+": "+err.Error())
+	}
+}
+
+// cancel cancela os jobs com o request ID informado. O cancelamento vale a partir do próximo estágio.
+func (t *jobTracker) cancel(requestID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	found := false
+	for job, tj := range t.jobs {
+		if job.requestID == requestID {
+			tj.cancel()
+			found = true
+		}
+	}
+	return found
+}
+
+// hasPath indica se algum job em andamento usa o arquivo (evita que a recuperação o reprocesse).
+func (t *jobTracker) hasPath(path string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for job := range t.jobs {
+		if job.path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// list retorna os jobs em andamento, mais antigos primeiro.
+func (t *jobTracker) list() []JobStatus {
+	now := time.Now()
+	t.mu.Lock()
+	statuses := make([]JobStatus, 0, len(t.jobs))
+	for job, tj := range t.jobs {
+		statuses = append(statuses, JobStatus{
+			RequestID:  job.requestID,
+			Filename:   job.filename,
+			IMEI:       job.imei,
+			Type:       job.uploadType,
+			Channel:    job.channel,
+			Class:      tj.class,
+			Stage:      tj.stage,
+			Size:       job.initialSize,
+			QueuedAt:   tj.queuedAt,
+			StageSince: tj.stageSince,
+			Age:        now.Sub(tj.queuedAt).Truncate(time.Millisecond).String(),
+			Cancelled:  job.ctx.Err() != nil,
+		})
+	}
+	t.mu.Unlock()
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].QueuedAt.Before(statuses[j].QueuedAt) })
+	return statuses
+}
+
+func (t *jobTracker) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.jobs)
+}
+
+// pauseSwitch pausa o recebimento ou o processamento de uploads pela API de admin.
+type pauseSwitch struct {
+	mu     sync.Mutex
+	paused bool
+	since  time.Time
+	resume chan struct{}
+}
+
+// Pause retorna false se já estava pausado.
+func (p *pauseSwitch) Pause() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused {
+		return false
+	}
+	p.paused = true
+	p.since = time.Now()
+	p.resume = make(chan struct{})
+	return true
+}
+
+// Resume retorna false se não estava pausado.
+func (p *pauseSwitch) Resume() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.paused {
+		return false
+	}
+	p.paused = false
+	p.since = time.Now()
+	close(p.resume)
+	return true
+}
+
+// State retorna se está pausado e desde quando está no estado atual.
+func (p *pauseSwitch) State() (bool, time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused, p.since
+}
+
+func (p *pauseSwitch) Paused() bool {
+	paused, _ := p.State()
+	return paused
+}
+
+// Wait bloqueia enquanto estiver pausado.
+func (p *pauseSwitch) Wait(ctx context.Context) error {
+	for {
+		p.mu.Lock()
+		paused, resume := p.paused, p.resume
+		p.mu.Unlock()
+		if !paused {
+			return nil
+		}
+		select {
+		case <-resume:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// acquireWorker aguarda o fim de uma pausa de processamento e uma vaga na classe de prioridade.
+// Se o processamento for pausado enquanto o job esperava a vaga, a vaga é devolvida.
+func (h *Handler) acquireWorker(job *processJob, class string) (func(), error) {
+	for {
+		if h.processing.Paused() {
+			h.jobs.setStage(job, stagePaused)
+		}
+		if err := h.processing.Wait(job.ctx); err != nil {
+			return nil, err
+		}
+		h.jobs.setStage(job, stageQueued)
+		release := h.workers.Acquire(class, h.schedulingKey(job.imei))
+		if job.ctx.Err() != nil {
+			release()
+			return nil, job.ctx.Err()
+		}
+		if !h.processing.Paused() {
+			return release, nil
+		}
+		release()
+	}
+}
+
+// enterStage registra o estágio atual do job e retorna false se ele foi cancelado.
+func (h *Handler) enterStage(job *processJob, stage string, logger *slog.Logger) bool {
+	h.jobs.setStage(job, stage)
+	if job.ctx.Err() == nil {
+		return true
+	}
+	logger.Warn("Job cancelled by admin", "stage", stage)
+	h.processingFailed(job, stage, true, errJobCancelled, logger)
+	return false
+}
+
+// abandonJob encerra um job cancelado antes de conseguir uma vaga de processamento.
+func (h *Handler) abandonJob(job *processJob, logger *slog.Logger) {
+	logger.Warn("Job cancelled by admin while queued")
+	if strings.Contains(job.path, ".processing") {
+		os.Remove(job.path)
+	}
+	h.processingFailed(job, stageQueued, true, errJobCancelled, logger)
+	h.jobs.untrack(job)
+	if job.onDone != nil {
+		job.onDone(errJobCancelled)
+	}
+}
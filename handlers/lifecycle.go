@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"log/slog"
+
+	"dvr-upload/queue"
+)
+
+// Motivos de rejeição informados em media.rejected.
+const (
+	rejectInvalidRequest   = "invalid_request"
+	rejectStreamError      = "stream_error"
+	rejectInterrupted      = "interrupted"
+	rejectMissingFile      = "missing_file"
+	rejectFilenameTooLong  = "filename_too_long"
+	rejectInvalidSignature = "invalid_signature"
+	rejectRateLimited      = "rate_limited"
+	rejectInvalidTelemetry = "invalid_telemetry"
+	rejectInvalidRawBlock  = "invalid_raw_block"
+	rejectStorageError     = "storage_error"
+	rejectIntakePaused     = "intake_paused"
+)
+
+// emitLifecycle publica um evento de ciclo de vida. O barramento interno sempre recebe o evento;
+// os sinks externos apenas com ENABLE_LIFECYCLE_EVENTS.
+func (h *Handler) emitLifecycle(kind string, event queue.LifecycleEvent, logger *slog.Logger) {
+	msg := queue.NewLifecycleMessage(kind, event)
+	h.bus.Publish(msg)
+	if !h.cfg.EnableLifecycleEvents || h.events == nil {
+		return
+	}
+	if err := h.events.Publish(msg); err != nil {
+		logger.Warn("Failed to publish lifecycle event", "kind", kind, "error", err)
+	}
+}
+
+// emitProgress publica um evento de progresso apenas no barramento interno (streaming ao vivo).
+func (h *Handler) emitProgress(kind string, event queue.LifecycleEvent) {
+	h.bus.Publish(queue.NewLifecycleMessage(kind, event))
+}
+
+// rejectUpload anuncia um upload recusado antes de entrar na fila de processamento.
+func (h *Handler) rejectUpload(requestID, imei, filename string, size int64, reason string, err error, logger *slog.Logger) {
+	event := queue.LifecycleEvent{
+		RequestID: requestID,
+		IMEI:      imei,
+		Filename:  filename,
+		Size:      size,
+		Reason:    reason,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	h.emitLifecycle(queue.KindMediaRejected, event, logger)
+}
+
+// processingFailed anuncia a falha de um estágio do processamento. fatal indica que o upload
+// foi abandonado; caso contrário o processamento seguiu sem o estágio.
+func (h *Handler) processingFailed(job *processJob, stage string, fatal bool, err error, logger *slog.Logger) {
+	event := queue.LifecycleEvent{
+		RequestID: job.requestID,
+		IMEI:      job.imei,
+		Type:      job.uploadType,
+		Channel:   job.channel,
+		Filename:  job.filename,
+		Size:      job.initialSize,
+		Stage:     stage,
+		Fatal:     fatal,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	h.jobs.recordFailure(job, stage, fatal, err)
+	h.emitLifecycle(queue.KindProcessingFailed, event, logger)
+}
+
+// jobEvent monta o evento de ciclo de vida de um job já aceito.
+func jobEvent(job *processJob, filename string, size int64) queue.LifecycleEvent {
+	return queue.LifecycleEvent{
+		RequestID: job.requestID,
+		IMEI:      job.imei,
+		Type:      job.uploadType,
+		Channel:   job.channel,
+		Filename:  filename,
+		Size:      size,
+	}
+}
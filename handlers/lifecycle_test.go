@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"dvr-upload/config"
+	"dvr-upload/queue"
+)
+
+// recordingSink guarda as mensagens publicadas; com err definido recusa todas.
+type recordingSink struct {
+	name string
+	err  error
+
+	mu   sync.Mutex
+	msgs []queue.Message
+}
+
+func (s *recordingSink) Name() string { return s.name }
+
+func (s *recordingSink) Publish(msg queue.Message) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.msgs = append(s.msgs, msg)
+	return nil
+}
+
+func (s *recordingSink) Close() {}
+
+func (s *recordingSink) kinds() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var kinds []string
+	for _, m := range s.msgs {
+		kinds = append(kinds, m.Kind)
+	}
+	return kinds
+}
+
+// lifecycleEvents retorna os eventos de ciclo de vida já entregues na assinatura do barramento.
+func lifecycleEvents(sub *queue.Subscription) []queue.LifecycleEvent {
+	var events []queue.LifecycleEvent
+	for {
+		select {
+		case msg := <-sub.C:
+			if event, ok := msg.Payload.(queue.LifecycleEvent); ok {
+				events = append(events, event)
+			}
+		default:
+			return events
+		}
+	}
+}
+
+func newLifecycleHandler(t *testing.T, cfg *config.Config, sinks ...queue.EventSink) (*Handler, *queue.Subscription) {
+	t.Helper()
+	cfg.MaxConcurrentWorkers = 1
+	h := NewHandler(cfg, nil, nil, queue.NewMultiSink(sinks...), nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	return h, h.bus.Subscribe(64, func(msg queue.Message) bool { return queue.IsLifecycle(msg.Kind) })
+}
+
+func TestProcessingLifecycleEvents(t *testing.T) {
+	ok := &recordingSink{name: "ok"}
+	broken := &recordingSink{name: "broken", err: errors.New("broker down")}
+	h, sub := newLifecycleHandler(t, &config.Config{EnableLifecycleEvents: true}, ok, broken)
+
+	path := filepath.Join(t.TempDir(), "EVENT_123456789012_00000000_2024_05_01_08_30_15_I_1.mp4")
+	if err := os.WriteFile(path, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runJob(t, h, path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Falha em um sink: o evento sai como processing_failed (não fatal) e published lista só quem aceitou
+	if got := strings.Join(ok.kinds(), ","); got != queue.KindMediaUploaded+","+queue.KindProcessingFailed+","+queue.KindMediaPublished {
+		t.Fatalf("sink received %s", got)
+	}
+	events := lifecycleEvents(sub)
+	if len(events) != 2 {
+		t.Fatalf("bus events = %+v", events)
+	}
+	failed, published := events[0], events[1]
+	if failed.Event != queue.KindProcessingFailed || failed.Stage != queue.StagePublish || failed.Fatal ||
+		!strings.Contains(failed.Error, "broken: broker down") || failed.RequestID != "req-1" || failed.IMEI != "123456789012" {
+		t.Fatalf("processing_failed = %+v", failed)
+	}
+	if published.Event != queue.KindMediaPublished || strings.Join(published.Sinks, ",") != "ok" || published.Size != 5 {
+		t.Fatalf("published = %+v", published)
+	}
+}
+
+func TestLifecycleEventsDisabled(t *testing.T) {
+	sink := &recordingSink{name: "ok"}
+	h, sub := newLifecycleHandler(t, &config.Config{}, sink)
+
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	if err := os.WriteFile(path, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runJob(t, h, path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sem ENABLE_LIFECYCLE_EVENTS os sinks externos recebem apenas o upload; o barramento recebe tudo
+	if got := strings.Join(sink.kinds(), ","); got != queue.KindMediaUploaded {
+		t.Fatalf("sink received %s", got)
+	}
+	if events := lifecycleEvents(sub); len(events) != 1 || events[0].Event != queue.KindMediaPublished {
+		t.Fatalf("bus events = %+v", events)
+	}
+}
+
+func TestUploadRejectedEvent(t *testing.T) {
+	sink := &recordingSink{name: "ok"}
+	h, sub := newLifecycleHandler(t, &config.Config{EnableLifecycleEvents: true}, sink)
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("not multipart"))
+	rec := httptest.NewRecorder()
+	h.UploadHandler(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status code = %d", rec.Code)
+	}
+
+	events := lifecycleEvents(sub)
+	if len(events) != 1 {
+		t.Fatalf("bus events = %+v", events)
+	}
+	if e := events[0]; e.Event != queue.KindMediaRejected || e.Reason != rejectInvalidRequest || e.Error == "" || e.RequestID == "" || e.Time.IsZero() {
+		t.Fatalf("rejected = %+v", e)
+	}
+	if got := strings.Join(sink.kinds(), ","); got != queue.KindMediaRejected {
+		t.Fatalf("sink received %s", got)
+	}
+}
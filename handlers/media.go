@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"dvr-upload/catalog"
+	"dvr-upload/processor"
+	"dvr-upload/utils"
+)
+
+// recordMedia grava o resultado do processamento no catálogo, completando os dados do job.
+// Quando mediaPath é informado calcula também hash e duração do arquivo final.
+func (h *Handler) recordMedia(job *processJob, rec catalog.Record, mediaPath string) {
+	if h.catalog == nil {
+		return
+	}
+
+	rec.ID = job.requestID
+	if job.recordID != "" {
+		// Reprocessamento: o registro existente é atualizado no lugar (um único Put) e, se o
+		// reprocessamento falhar, continua descrevendo a mídia que segue armazenada
+		if rec.Status == catalog.StatusFailed {
+			job.logger.Warn("Reprocessing failed, keeping the previous catalog record", "record_id", job.recordID)
+			return
+		}
+		rec.ID = job.recordID
+	}
+	rec.IMEI = job.imei
+	rec.Type = job.uploadType
+	rec.Channel = job.channel
+	rec.CaptureTime = job.captureTime
+	rec.ReceivedAt = job.startTime
+	rec.Raw = job.raw
+
+	if mediaPath != "" {
+		if sum, err := utils.FileSHA256(mediaPath); err == nil {
+			rec.SHA256 = sum
+		} else {
+			job.logger.Warn("Failed to hash media for catalog", "error", err)
+		}
+		switch strings.ToLower(filepath.Ext(rec.Filename)) {
+		case ".mp4", ".ts":
+			if d, err := processor.ProbeDuration(mediaPath); err == nil {
+				rec.Duration = d
+			}
+		}
+	}
+
+	if err := h.catalog.Put(rec); err != nil {
+		job.logger.Error("Failed to write media catalog record", "error", err)
+	}
+}
+
+// MediaHandler responde GET /media?imei=&from=&to=&type=&channel=&limit=&cursor= consultando o catálogo.
+func (h *Handler) MediaHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteJSON(w, http.StatusMethodNotAllowed, utils.JSONResponse{Code: 405, Message: "Method not allowed"})
+		return
+	}
+	if h.catalog == nil {
+		utils.WriteJSON(w, http.StatusServiceUnavailable, utils.JSONResponse{Code: 503, Message: "Media catalog disabled"})
+		return
+	}
+
+	params := r.URL.Query()
+	q := catalog.Query{
+		IMEI:    strings.TrimSpace(params.Get("imei")),
+		Type:    strings.ToUpper(strings.TrimSpace(params.Get("type"))),
+		Channel: strings.TrimSpace(params.Get("channel")),
+		Cursor:  params.Get("cursor"),
+	}
+
+	var err error
+	if q.From, err = parseQueryTime(params.Get("from")); err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.JSONResponse{Code: 400, Message: "Invalid from"})
+		return
+	}
+	if q.To, err = parseQueryTime(params.Get("to")); err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.JSONResponse{Code: 400, Message: "Invalid to"})
+		return
+	}
+	if limit := params.Get("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil || q.Limit <= 0 {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.JSONResponse{Code: 400, Message: "Invalid limit"})
+			return
+		}
+	}
+
+	page, err := h.catalog.Query(q)
+	if err != nil {
+		if errors.Is(err, catalog.ErrInvalidCursor) {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.JSONResponse{Code: 400, Message: "Invalid cursor"})
+			return
+		}
+		h.log.Error("Media catalog query failed", "error", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.JSONResponse{Code: 500, Message: "Catalog query failed"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.JSONResponse{Code: 200, Message: "ok", Data: page})
+}
+
+func parseQueryTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return utils.ParseDateTime(v)
+}
+
+// MediaURLHandler responde GET /media/{key}/url?expires=&filename= com uma URL GET temporária:
+// presigned do S3 quando o upload para o S3 está ativo, ou assinada pelo próprio serviço
+// (servida em /files/{key}) em deployments apenas locais.
+func (h *Handler) MediaURLHandler(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.PathValue("key"), "/")
+	if key == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.JSONResponse{Code: 400, Message: "Missing key"})
+		return
+	}
+
+	expiry := time.Duration(h.cfg.PresignExpiry) * time.Second
+	if v := r.URL.Query().Get("expires"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil || secs <= 0 {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.JSONResponse{Code: 400, Message: "Invalid expires"})
+			return
+		}
+		expiry = time.Duration(secs) * time.Second
+	}
+	if maxExpiry := time.Duration(h.cfg.PresignMaxExpiry) * time.Second; maxExpiry > 0 && expiry > maxExpiry {
+		expiry = maxExpiry
+	}
+	filename := strings.TrimSpace(r.URL.Query().Get("filename"))
+	expiresAt := time.Now().Add(expiry)
+
+	var signedURL, backend string
+	if h.cfg.EnableS3Upload && h.storage.S3Enabled() {
+		u, err := h.storage.PresignGetURL(key, expiry, filename)
+		if err != nil {
+			h.log.Error("Failed to presign media URL", "key", key, "error", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.JSONResponse{Code: 500, Message: "Failed to generate URL"})
+			return
+		}
+		signedURL, backend = u, "s3"
+	} else {
+		if !h.signedURLsEnabled() {
+			utils.WriteJSON(w, http.StatusServiceUnavailable, utils.JSONResponse{Code: 503, Message: "Signed URLs disabled, URL_SIGNING_KEY is not set"})
+			return
+		}
+		if _, ok := h.storage.LocalPath(key); !ok {
+			utils.WriteJSON(w, http.StatusNotFound, utils.JSONResponse{Code: 404, Message: "Media not found"})
+			return
+		}
+		signedURL, backend = h.localSignedURL(r, key, expiresAt, filename), "local"
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.JSONResponse{
+		Code:    200,
+		Message: "ok",
+		Data: map[string]interface{}{
+			"url":        signedURL,
+			"method":     http.MethodGet,
+			"storage":    backend,
+			"expires_at": expiresAt.UTC().Format(time.RFC3339),
+		},
+	})
+}
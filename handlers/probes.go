@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"dvr-upload/utils"
+)
+
+// Estado de uma dependência nas verificações em segundo plano.
+const (
+	dependencyOK       = "ok"
+	dependencyError    = "error"
+	dependencyDisabled = "disabled"
+)
+
+// DependencyStatus é o resultado da última verificação de uma dependência.
+type DependencyStatus struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Latency   string    `json:"latency,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// healthString resume o estado no formato do /health ("ok", "disabled" ou "error: ...").
+func (d DependencyStatus) healthString() string {
+	if d.Status == dependencyError {
+		return "error: " + d.Error
+	}
+	return d.Status
+}
+
+// dependencyCheck verifica uma dependência. Retorna (false, nil) quando ela está desativada.
+type dependencyCheck func(ctx context.Context) (enabled bool, err error)
+
+// probeCache guarda o resultado das verificações, feitas em intervalo fixo em vez de a cada
+// requisição de /health ou /readyz.
+type probeCache struct {
+	mu        sync.RWMutex
+	results   map[string]DependencyStatus
+	checkedAt time.Time
+}
+
+func newProbeCache() *probeCache {
+	return &probeCache{results: make(map[string]DependencyStatus)}
+}
+
+func (p *probeCache) snapshot() (map[string]DependencyStatus, time.Time) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	out := make(map[string]DependencyStatus, len(p.results))
+	for name, status := range p.results {
+		out[name] = status
+	}
+	return out, p.checkedAt
+}
+
+// dependencyChecks lista as dependências verificadas.
+func (h *Handler) dependencyChecks() map[string]dependencyCheck {
+	checks := map[string]dependencyCheck{
+		"s3_storage": func(ctx context.Context) (bool, error) {
+			// Sem bucket/endpoint o upload para o object storage fica desativado (ver storage.initS3)
+			if !h.cfg.EnableS3Upload || h.cfg.S3Bucket == "" || h.cfg.S3Endpoint == "" {
+				return false, nil
+			}
+			return true, h.storage.Ping(ctx)
+		},
+		"rabbitmq": func(ctx context.Context) (bool, error) {
+			if !h.cfg.EnableRabbitMQ {
+				return false, nil
+			}
+			if h.rabbitMQ == nil {
+				return true, fmt.Errorf("not connected")
+			}
+			return true, h.rabbitMQ.Ping()
+		},
+		"disk": func(ctx context.Context) (bool, error) {
+			return true, h.checkDisk()
+		},
+	}
+	if h.cfg.EnableNATS {
+		checks["nats"] = func(ctx context.Context) (bool, error) {
+			for _, s := range h.events.Sinks() {
+				if p, ok := s.(interface{ Ping() error }); ok && s.Name() == "nats" {
+					return true, p.Ping()
+				}
+			}
+			return true, fmt.Errorf("not connected")
+		}
+	}
+	return checks
+}
+
+// checkDisk confirma que os diretórios de processamento e de armazenamento local aceitam escrita.
+func (h *Handler) checkDisk() error {
+	dirs := []string{h.processingDir()}
+	if h.cfg.EnableLocalStorage {
+		dirs = append(dirs, h.cfg.VideoPath)
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		f, err := os.CreateTemp(dir, ".readyz-*")
+		if err != nil {
+			return fmt.Errorf("%s not writable: %w", dir, err)
+		}
+		name := f.Name()
+		_, werr := f.Write([]byte("ok"))
+		f.Close()
+		os.Remove(name)
+		if werr != nil {
+			return fmt.Errorf("%s not writable: %w", dir, werr)
+		}
+	}
+	return nil
+}
+
+// runProbes executa todas as verificações em paralelo, cada uma com timeout próprio.
+func (h *Handler) runProbes() {
+	checks := h.dependencyChecks()
+	timeout := time.Duration(h.cfg.ProbeTimeout) * time.Second
+	results := make(map[string]DependencyStatus, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			start := time.Now()
+			status := DependencyStatus{Status: dependencyOK}
+			enabled, err := check(ctx)
+			switch {
+			case !enabled:
+				status.Status = dependencyDisabled
+			case err != nil:
+				status.Status = dependencyError
+				status.Error = err.Error()
+			}
+			if enabled {
+				status.Latency = time.Since(start).Truncate(time.Millisecond).String()
+			}
+			status.CheckedAt = time.Now().UTC()
+			mu.Lock()
+			results[name] = status
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	h.probes.mu.Lock()
+	previous := h.probes.results
+	h.probes.results = results
+	h.probes.checkedAt = time.Now().UTC()
+	h.probes.mu.Unlock()
+
+	for name, status := range results {
+		if old, ok := previous[name]; ok && old.Status == status.Status {
+			continue
+		}
+		if status.Status == dependencyError {
+			h.log.Warn("Dependency check failed", "dependency", name, "error", status.Error)
+		} else if _, ok := previous[name]; ok {
+			h.log.Info("Dependency check recovered", "dependency", name, "status", status.Status)
+		}
+	}
+}
+
+// StartProbes verifica as dependências imediatamente e depois a cada PROBE_INTERVAL_SECONDS.
+func (h *Handler) StartProbes() {
+	ticker := time.NewTicker(time.Duration(h.cfg.ProbeInterval) * time.Second)
+	defer ticker.Stop()
+	for {
+		h.runProbes()
+		<-ticker.C
+	}
+}
+
+// LivenessHandler responde GET /livez: o processo está de pé e atendendo requisições. Não consulta
+// dependências, para que uma falha externa não faça o orquestrador reiniciar o serviço.
+func (h *Handler) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSON(w, http.StatusOK, utils.JSONResponse{Code: 200, Message: "ok", Data: map[string]interface{}{
+		"uptime": time.Since(h.startTime).Truncate(time.Second).String(),
+	}})
+}
+
+// readiness avalia, a partir do cache, se o serviço deve receber uploads.
+func (h *Handler) readiness() (bool, map[string]interface{}) {
+	dependencies, checkedAt := h.probes.snapshot()
+	var reasons []string
+	if checkedAt.IsZero() {
+		reasons = append(reasons, "dependency checks pending")
+	} else if stale := 3 * time.Duration(h.cfg.ProbeInterval) * time.Second; time.Since(checkedAt) > stale {
+		reasons = append(reasons, "dependency checks stale")
+	}
+	for name, status := range dependencies {
+		if status.Status == dependencyError {
+			reasons = append(reasons, name+": "+status.Error)
+		}
+	}
+	if h.intake.Paused() {
+		reasons = append(reasons, "intake paused")
+	}
+	sort.Strings(reasons)
+
+	report := map[string]interface{}{
+		"ready":         len(reasons) == 0,
+		"intake_paused": h.intake.Paused(),
+		"dependencies":  dependencies,
+	}
+	if !checkedAt.IsZero() {
+		report["checked_at"] = checkedAt
+	}
+	if len(reasons) > 0 {
+		report["reasons"] = reasons
+	}
+	return len(reasons) == 0, report
+}
+
+// ReadinessHandler responde GET /readyz com 200 quando o serviço pode receber uploads e 503 caso
+// contrário (dependência com erro, disco sem escrita ou recebimento pausado).
+func (h *Handler) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	ready, report := h.readiness()
+	if !ready {
+		utils.WriteJSON(w, http.StatusServiceUnavailable, utils.JSONResponse{Code: 503, Message: "not ready", Data: report})
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.JSONResponse{Code: 200, Message: "ok", Data: report})
+}
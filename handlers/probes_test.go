@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"dvr-upload/config"
+	"dvr-upload/queue"
+)
+
+// newProbeHandler monta um handler com armazenamento local em um diretório temporário.
+func newProbeHandler(t *testing.T, cfg *config.Config) *Handler {
+	t.Helper()
+	cfg.VideoPath = filepath.Join(t.TempDir(), "videos")
+	cfg.EnableLocalStorage = true
+	cfg.ProbeInterval = 10
+	cfg.ProbeTimeout = 2
+	return NewHandler(cfg, nil, nil, queue.NewMultiSink(), nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+type readyReport struct {
+	Ready        bool                        `json:"ready"`
+	Reasons      []string                    `json:"reasons"`
+	Dependencies map[string]DependencyStatus `json:"dependencies"`
+}
+
+func readyz(t *testing.T, h *Handler) (int, readyReport) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ReadinessHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var resp struct {
+		Data readyReport `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, resp.Data
+}
+
+func TestReadiness(t *testing.T) {
+	h := newProbeHandler(t, &config.Config{})
+	if code, report := readyz(t, h); code != http.StatusServiceUnavailable || strings.Join(report.Reasons, ",") != "dependency checks pending" {
+		t.Fatalf("before the first check: %d %+v", code, report)
+	}
+
+	h.runProbes()
+	code, report := readyz(t, h)
+	if code != http.StatusOK || !report.Ready {
+		t.Fatalf("after the first check: %d %+v", code, report)
+	}
+	// Dependências desativadas não são erro
+	for name, want := range map[string]string{"s3_storage": dependencyDisabled, "rabbitmq": dependencyDisabled, "disk": dependencyOK} {
+		if got := report.Dependencies[name]; got.Status != want || got.CheckedAt.IsZero() {
+			t.Fatalf("%s = %+v, want %s", name, got, want)
+		}
+	}
+	if report.Dependencies["disk"].Latency == "" || report.Dependencies["rabbitmq"].Latency != "" {
+		t.Fatalf("latency reported for %+v", report.Dependencies)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(h.cfg.VideoPath, ".readyz-*")); len(leftovers) != 0 {
+		t.Fatalf("disk check left %v", leftovers)
+	}
+
+	h.intake.Pause()
+	if code, report := readyz(t, h); code != http.StatusServiceUnavailable || strings.Join(report.Reasons, ",") != "intake paused" {
+		t.Fatalf("intake paused: %d %+v", code, report)
+	}
+	h.intake.Resume()
+
+	h.probes.mu.Lock()
+	h.probes.checkedAt = time.Now().Add(-time.Hour)
+	h.probes.mu.Unlock()
+	if code, report := readyz(t, h); code != http.StatusServiceUnavailable || strings.Join(report.Reasons, ",") != "dependency checks stale" {
+		t.Fatalf("stale checks: %d %+v", code, report)
+	}
+}
+
+func TestReadinessDependencyErrors(t *testing.T) {
+	h := newProbeHandler(t, &config.Config{EnableRabbitMQ: true, EnableNATS: true})
+	// Um arquivo no lugar do diretório de vídeos impede a escrita
+	if err := os.WriteFile(h.cfg.VideoPath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	h.runProbes()
+
+	code, report := readyz(t, h)
+	if code != http.StatusServiceUnavailable || report.Ready {
+		t.Fatalf("status %d, %+v", code, report)
+	}
+	if len(report.Reasons) != 3 || report.Reasons[0] != "disk: mkdir "+h.cfg.VideoPath+": not a directory" ||
+		report.Reasons[1] != "nats: not connected" || report.Reasons[2] != "rabbitmq: not connected" {
+		t.Fatalf("reasons = %q", report.Reasons)
+	}
+
+	// O /health usa o mesmo cache, sem verificar as dependências a cada requisição
+	rec := httptest.NewRecorder()
+	h.HealthHandler(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var health struct {
+		Data struct {
+			Dependencies map[string]string `json:"dependencies"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &health); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusServiceUnavailable || health.Data.Dependencies["rabbitmq"] != "error: not connected" || health.Data.Dependencies["s3_storage"] != dependencyDisabled {
+		t.Fatalf("health: %d %v", rec.Code, health.Data.Dependencies)
+	}
+}
+
+func TestLiveness(t *testing.T) {
+	h := newProbeHandler(t, &config.Config{EnableRabbitMQ: true})
+	h.runProbes()
+	h.intake.Pause()
+	// Falhas de dependências e pausas não afetam a liveness
+	rec := httptest.NewRecorder()
+	h.LivenessHandler(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"uptime"`) {
+		t.Fatalf("livez: %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"dvr-upload/rawblock"
+	"dvr-upload/utils"
+)
+
+// decodeRaw decodifica o bloco raw (campo do formulário ou extraído do nome do arquivo) com o
+// decodificador registrado para o padrão do upload e a família de firmware informada (ou a padrão).
+// O padrão vem do nome padronizado do arquivo e, quando ele não é reconhecido, do campo pattern do
+// formulário. Sem bloco, sem decodificador registrado ou com a decodificação desativada retorna nil sem erro.
+func (h *Handler) decodeRaw(rawHex, pattern, filename, firmware string) (json.RawMessage, error) {
+	if h.rawDecoders == nil {
+		return nil, nil
+	}
+	rawHex = strings.TrimSpace(rawHex)
+	info, ok := utils.ParseStandardFilename(filename)
+	if ok {
+		pattern = info.Pattern
+	}
+	if rawHex == "" {
+		// Só o nome de snapshot carrega o bloco raw
+		if !ok || info.Pattern != "snapshot" {
+			return nil, nil
+		}
+		rawHex = info.Raw
+	}
+	if rawHex == "" {
+		return nil, nil
+	}
+
+	family := strings.TrimSpace(firmware)
+	if family == "" {
+		family = h.cfg.RawDecoderFamily
+	}
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if pattern == "" {
+		// Mesma heurística do BuildStandardFilename: raw sem padrão declarado é de snapshot
+		pattern = "snapshot"
+	}
+	result, err := h.rawDecoders.Decode(pattern, family, rawHex)
+	if errors.Is(err, rawblock.ErrNoDecoder) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(result)
+}
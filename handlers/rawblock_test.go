@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"dvr-upload/config"
+	"dvr-upload/rawblock"
+)
+
+func TestDecodeRawPattern(t *testing.T) {
+	decoders := rawblock.NewRegistry()
+	for _, key := range [][2]string{{"snapshot", "jimi"}, {"event", "jimi"}, {"snapshot", "other"}} {
+		decoder := key[0] + "/" + key[1]
+		decoders.Register(key[0], key[1], rawblock.DecoderFunc(func(data []byte) (map[string]any, error) {
+			return map[string]any{"by": decoder}, nil
+		}))
+	}
+	h := &Handler{cfg: &config.Config{RawDecoderFamily: "jimi"}, rawDecoders: decoders}
+
+	const event = "EVENT_123456789012_00000000_2024_05_01_08_30_15_I_1.mp4"
+	tests := []struct {
+		name     string
+		rawHex   string
+		pattern  string // campo pattern do formulário
+		filename string
+		firmware string
+		want     string // decodificador usado; vazio quando nada é decodificado
+	}{
+		{name: "raw from the snapshot name", filename: "123456789012_0A0B_1_01.jpg", want: "snapshot/jimi"},
+		{name: "firmware family", filename: "123456789012_0A0B_1_01.jpg", firmware: "other", want: "snapshot/other"},
+		{name: "raw field on an event upload", rawHex: "0A0B", filename: event, want: "event/jimi"},
+		{name: "filename pattern wins over the form", rawHex: "0A0B", pattern: "snapshot", filename: event, want: "event/jimi"},
+		{name: "form pattern for a non-standard name", rawHex: "0A0B", pattern: "event", filename: "video.mp4", want: "event/jimi"},
+		{name: "raw without any pattern", rawHex: "0A0B", filename: "video.mp4", want: "snapshot/jimi"},
+		{name: "no decoder for the family", rawHex: "0A0B", filename: event, firmware: "other"},
+		{name: "event name carries no raw", filename: event},
+		{name: "snapshot name without raw", filename: "123456789012__1_01.jpg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := h.decodeRaw(tt.rawHex, tt.pattern, tt.filename, tt.firmware)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			if data != nil {
+				var result rawblock.Result
+				if err := json.Unmarshal(data, &result); err != nil {
+					t.Fatal(err)
+				}
+				got, _ = result.Fields["by"].(string)
+			}
+			if got != tt.want {
+				t.Fatalf("decoded by %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
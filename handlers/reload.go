@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"dvr-upload/config"
+	"dvr-upload/queue"
+)
+
+// reloadableFields são os campos da Config aplicados sem reinício quando a configuração é
+// recarregada (SIGHUP). Os demais só valem a partir do próximo start.
+var reloadableFields = map[string]bool{
+	"SecretKey":                 true,
+	"EnableSecret":              true,
+	"DeviceSecrets":             true,
+	"AdminTokens":               true,
+	"URLSigningKey":             true,
+	"S3AccessKey":               true,
+	"S3SecretKey":               true,
+	"WebhookEndpoints":          true,
+	"RateLimitUploadsPerMinute": true,
+	"RateLimitBytesPerHour":     true,
+	"LogLevel":                  true,
+}
+
+// settings retorna a configuração carregada mais recentemente. Use apenas para os campos de
+// reloadableFields; para os demais h.cfg (a configuração do start) é a referência.
+func (h *Handler) settings() *config.Config {
+	return h.live.Load()
+}
+
+// effectiveConfig retorna a configuração em uso: a do start com os campos recarregáveis da última
+// carga, e os campos alterados na última carga que só valem após reinício.
+func (h *Handler) effectiveConfig() (effective *config.Config, pendingRestart []string) {
+	live := h.settings()
+	pendingRestart = []string{}
+	for _, field := range h.cfg.Changed(live) {
+		if !reloadableFields[field] {
+			pendingRestart = append(pendingRestart, field)
+		}
+	}
+	return h.cfg.Overlay(live, reloadableFields), pendingRestart
+}
+
+// Reload aplica os campos recarregáveis de cfg: secrets (incluindo credenciais do object storage e
+// dos webhooks, para rotação), registro de dispositivos, limites por dispositivo e nível de log.
+// Credenciais de conexões abertas no start (RabbitMQ, NATS, Kafka) e a chave de custódia não são
+// recarregadas. Retorna os campos aplicados e os alterados que exigem reinício.
+func (h *Handler) Reload(cfg *config.Config) (applied, restartRequired []string) {
+	for _, field := range h.settings().Changed(cfg) {
+		if reloadableFields[field] {
+			applied = append(applied, field)
+		} else {
+			restartRequired = append(restartRequired, field)
+		}
+	}
+
+	h.live.Store(cfg)
+	h.storage.SetCredentials(cfg.S3AccessKey, cfg.S3SecretKey)
+	for _, sink := range h.events.Sinks() {
+		if s, ok := sink.(*queue.WebhookSink); ok {
+			s.SetEndpoints(cfg.WebhookEndpoints)
+		}
+	}
+	h.devices.Replace(cfg.DeviceSecrets)
+	h.limiter.SetLimits(cfg.RateLimitUploadsPerMinute, cfg.RateLimitBytesPerHour)
+	if h.logLevel != nil {
+		h.logLevel.UnmarshalText([]byte(cfg.LogLevel))
+	}
+	return applied, restartRequired
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"dvr-upload/config"
+	"dvr-upload/queue"
+	"dvr-upload/storage"
+)
+
+func TestReloadSecrets(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := &config.Config{
+		SecretKey:         "old",
+		AdminTokens:       []string{"old"},
+		RabbitMQURL:       "amqp://dvr:old@mq:5672/",
+		NATSCredsFile:     "/run/secrets/old.creds",
+		CustodySigningKey: "old",
+	}
+	h := NewHandler(cfg, storage.NewStorageService(cfg, logger), nil, queue.NewMultiSink(), nil, logger)
+
+	next := *cfg
+	next.SecretKey = "new"
+	next.AdminTokens = []string{"new"}
+	next.RabbitMQURL = "amqp://dvr:new@mq:5672/"
+	next.NATSCredsFile = "/run/secrets/new.creds"
+	next.CustodySigningKey = "new"
+	applied, restartRequired := h.Reload(&next)
+
+	slices.Sort(applied)
+	slices.Sort(restartRequired)
+	if want := []string{"AdminTokens", "SecretKey"}; !slices.Equal(applied, want) {
+		t.Fatalf("applied = %v, want %v", applied, want)
+	}
+	if want := []string{"CustodySigningKey", "NATSCredsFile", "RabbitMQURL"}; !slices.Equal(restartRequired, want) {
+		t.Fatalf("restart required = %v, want %v", restartRequired, want)
+	}
+
+	effective, pending := h.effectiveConfig()
+	if effective.SecretKey != "new" || effective.CustodySigningKey != "old" {
+		t.Fatalf("effective SecretKey = %q, CustodySigningKey = %q", effective.SecretKey, effective.CustodySigningKey)
+	}
+	slices.Sort(pending)
+	if !slices.Equal(pending, restartRequired) {
+		t.Fatalf("pending restart = %v, want %v", pending, restartRequired)
+	}
+}
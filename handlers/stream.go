@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dvr-upload/queue"
+	"dvr-upload/utils"
+)
+
+const (
+	streamBufferSize        = 256
+	streamHeartbeatInterval = 15 * time.Second
+)
+
+// streamLimiter limita as conexões SSE simultâneas, no total e por cliente, para que o streaming
+// não dispute recursos com os uploads.
+type streamLimiter struct {
+	mu           sync.Mutex
+	total        int
+	perClient    map[string]int
+	max          int
+	maxPerClient int
+}
+
+func newStreamLimiter(max, maxPerClient int) *streamLimiter {
+	return &streamLimiter{perClient: make(map[string]int), max: max, maxPerClient: maxPerClient}
+}
+
+func (l *streamLimiter) acquire(client string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.max > 0 && l.total >= l.max {
+		return false
+	}
+	if l.maxPerClient > 0 && l.perClient[client] >= l.maxPerClient {
+		return false
+	}
+	l.total++
+	l.perClient[client]++
+	return true
+}
+
+func (l *streamLimiter) release(client string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.total--
+	if l.perClient[client]--; l.perClient[client] <= 0 {
+		delete(l.perClient, client)
+	}
+}
+
+func (l *streamLimiter) active() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.total
+}
+
+// streamEvent é o corpo (data) de cada mensagem SSE.
+type streamEvent struct {
+	Event string      `json:"event"`
+	ID    string      `json:"id"`
+	IMEI  string      `json:"imei,omitempty"`
+	Type  string      `json:"type,omitempty"`
+	Time  time.Time   `json:"time"`
+	Data  interface{} `json:"data"`
+}
+
+// streamFilter monta o filtro do barramento a partir de ?imei=&type=&event= (listas separadas por vírgula).
+func streamFilter(r *http.Request) func(queue.Message) bool {
+	params := r.URL.Query()
+	set := func(key string, upper bool) map[string]bool {
+		values := make(map[string]bool)
+		for _, v := range strings.Split(params.Get(key), ",") {
+			v = strings.TrimSpace(v)
+			if upper {
+				v = strings.ToUpper(v)
+			}
+			if v != "" {
+				values[v] = true
+			}
+		}
+		return values
+	}
+	imeis, types, events := set("imei", false), set("type", true), set("event", false)
+	return func(msg queue.Message) bool {
+		if len(imeis) > 0 && !imeis[msg.IMEI] {
+			return false
+		}
+		if len(types) > 0 && !types[strings.ToUpper(msg.Type)] {
+			return false
+		}
+		if len(events) > 0 && !events[msg.Kind] {
+			return false
+		}
+		return true
+	}
+}
+
+// EventStreamHandler responde GET /events/stream?imei=&type=&event= com Server-Sent Events para cada
+// passo dos uploads (recebido, assinatura, conversão, upload, publicação, rejeições e falhas).
+// Clientes lentos perdem mensagens (avisados com o evento "dropped") em vez de atrasar os uploads.
+func (h *Handler) EventStreamHandler(w http.ResponseWriter, r *http.Request) {
+	client, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		client = r.RemoteAddr
+	}
+	if !h.streams.acquire(client) {
+		w.Header().Set("Retry-After", "30")
+		utils.WriteJSON(w, http.StatusTooManyRequests, utils.JSONResponse{Code: 429, Message: "Too many stream connections"})
+		return
+	}
+	defer h.streams.release(client)
+
+	sub := h.bus.Subscribe(streamBufferSize, streamFilter(r))
+	defer h.bus.Unsubscribe(sub)
+
+	// O servidor de admin tem WriteTimeout; a conexão de streaming não expira
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // desativa buffer de proxies (nginx)
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 5000\n\n")
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	logger := h.log.With("client", client, "query", r.URL.RawQuery)
+	logger.Info("Event stream connected", "active_streams", h.streams.active())
+	defer logger.Info("Event stream disconnected")
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	var seq, reportedDrops int64
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case msg, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if dropped := sub.Dropped(); dropped > reportedDrops {
+				reportedDrops = dropped
+				fmt.Fprintf(w, "event: dropped\ndata: {\"dropped\":%d}\n\n", dropped)
+			}
+			body, err := json.Marshal(streamEvent{Event: msg.Kind, ID: msg.ID, IMEI: msg.IMEI, Type: msg.Type, Time: msg.Time, Data: msg.Payload})
+			if err != nil {
+				continue
+			}
+			seq++
+			if _, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", strconv.FormatInt(seq, 10), msg.Kind, body); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
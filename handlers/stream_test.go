@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"dvr-upload/config"
+	"dvr-upload/queue"
+)
+
+func TestStreamLimiter(t *testing.T) {
+	l := newStreamLimiter(3, 2)
+	if !l.acquire("a") || !l.acquire("a") {
+		t.Fatal("first connections refused")
+	}
+	if l.acquire("a") {
+		t.Fatal("per-client limit not enforced")
+	}
+	if !l.acquire("b") {
+		t.Fatal("other client refused")
+	}
+	if l.acquire("c") {
+		t.Fatal("total limit not enforced")
+	}
+	l.release("a")
+	if !l.acquire("c") || l.active() != 3 {
+		t.Fatalf("after release: active = %d", l.active())
+	}
+
+	unlimited := newStreamLimiter(0, 0)
+	for i := 0; i < 100; i++ {
+		if !unlimited.acquire("a") {
+			t.Fatal("zero limits refused a connection")
+		}
+	}
+}
+
+func TestStreamFilter(t *testing.T) {
+	msg := queue.Message{Kind: queue.KindMediaReceived, IMEI: "111", Type: "I"}
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"", true},
+		{"imei=111", true},
+		{"imei=222,%20111", true},
+		{"imei=222", false},
+		{"type=i", true},
+		{"type=F", false},
+		{"event=media.received,media.published", true},
+		{"event=media.published", false},
+		{"imei=111&type=F", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/events/stream?"+tt.query, nil)
+		if got := streamFilter(r)(msg); got != tt.want {
+			t.Errorf("?%s: filter = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+// sseEvent é uma mensagem SSE lida do stream.
+type sseEvent struct {
+	id, event, data string
+}
+
+// readSSE lê a próxima mensagem com evento do stream, ignorando comentários e o retry inicial.
+func readSSE(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+	var ev sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			if ev.event != "" {
+				return ev
+			}
+		case strings.HasPrefix(line, "id: "):
+			ev.id = line[len("id: "):]
+		case strings.HasPrefix(line, "event: "):
+			ev.event = line[len("event: "):]
+		case strings.HasPrefix(line, "data: "):
+			ev.data = line[len("data: "):]
+		}
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	h, srv := newAdminServer(t, &config.Config{StreamMaxConnections: 5, StreamMaxConnectionsPerClient: 1})
+	subscribers := h.bus.Subscribers() // o log de atividade já assina o barramento
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events/stream?imei=111", nil)
+	req.Header.Set("X-Admin-Token", testAdminToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for h.bus.Subscribers() == subscribers {
+		if time.Now().After(deadline) {
+			t.Fatal("stream did not subscribe to the bus")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Segunda conexão do mesmo cliente excede SSE_MAX_CONNECTIONS_PER_CLIENT
+	if code := adminRequest(t, srv, http.MethodGet, "/events/stream", "", nil); code != http.StatusTooManyRequests {
+		t.Fatalf("second stream: status %d", code)
+	}
+
+	h.bus.Publish(queue.NewLifecycleMessage(queue.KindMediaReceived, queue.LifecycleEvent{RequestID: "req-other", IMEI: "222"}))
+	h.bus.Publish(queue.NewLifecycleMessage(queue.KindMediaReceived, queue.LifecycleEvent{RequestID: "req-1", IMEI: "111", Filename: "a.mp4"}))
+	h.bus.Publish(queue.NewLifecycleMessage(queue.KindSignatureVerified, queue.LifecycleEvent{RequestID: "req-1", IMEI: "111"}))
+
+	reader := bufio.NewReader(resp.Body)
+	for i, want := range []string{queue.KindMediaReceived, queue.KindSignatureVerified} {
+		ev := readSSE(t, reader)
+		var body streamEvent
+		if err := json.Unmarshal([]byte(ev.data), &body); err != nil {
+			t.Fatal(err)
+		}
+		if ev.event != want || ev.id != strconv.Itoa(i+1) || body.Event != want || body.ID != "req-1" || body.IMEI != "111" {
+			t.Fatalf("event %d = %+v (%+v), want %s", i, ev, body, want)
+		}
+	}
+
+	// Ao desconectar, a assinatura e a vaga de conexão são liberadas
+	cancel()
+	deadline = time.Now().Add(5 * time.Second)
+	for h.streams.active() != 0 || h.bus.Subscribers() != subscribers {
+		if time.Now().After(deadline) {
+			t.Fatalf("stream not released: %d active, %d subscribers", h.streams.active(), h.bus.Subscribers())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dvr-upload/config"
+	"dvr-upload/queue"
+	"dvr-upload/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestUploadTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+
+	videos := filepath.Join(t.TempDir(), "videos")
+	if err := os.MkdirAll(videos, 0755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{EnableLocalStorage: true, VideoPath: videos, MaxConcurrentWorkers: 1}
+	sink := &recordingSink{name: "ok"}
+	h := NewHandler(cfg, nil, nil, queue.NewMultiSink(sink), nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("filename", "video.mp4")
+	mw.WriteField("imei", "123456789012")
+	fw, _ := mw.CreateFormFile("file", "video.mp4")
+	fw.Write([]byte("video"))
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	rec := httptest.NewRecorder()
+	h.UploadHandler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("upload: status %d: %s", rec.Code, rec.Body)
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	deadline := time.Now().Add(5 * time.Second)
+	for spans["process"] == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("process span not ended: %v", spans)
+		}
+		time.Sleep(10 * time.Millisecond)
+		for _, s := range recorder.Ended() {
+			spans[s.Name()] = s
+		}
+	}
+
+	receive, process := spans["receive"], spans["process"]
+	if receive == nil || receive.SpanKind() != trace.SpanKindServer {
+		t.Fatalf("receive span = %v", receive)
+	}
+	// O trace do cliente continua no recebimento e o processamento assíncrono é filho do recebimento
+	if receive.SpanContext().TraceID().String() != "0af7651916cd43dd8448eb211c80319c" || receive.Parent().SpanID().String() != "b7ad6b7169203331" {
+		t.Fatalf("receive span parent = %v", receive.Parent())
+	}
+	if process.Parent().SpanID() != receive.SpanContext().SpanID() || process.SpanContext().TraceID() != receive.SpanContext().TraceID() {
+		t.Fatalf("process span parent = %v", process.Parent())
+	}
+	attrs := attribute.NewSet(process.Attributes()...)
+	for key, want := range map[attribute.Key]attribute.Value{
+		tracing.AttrIMEI: attribute.StringValue("123456789012"),
+		tracing.AttrSize: attribute.Int64Value(5),
+		tracing.AttrExt:  attribute.StringValue(".mp4"),
+	} {
+		if got, _ := attrs.Value(key); got != want {
+			t.Fatalf("process span %s = %v, want %v", key, got.Emit(), want.Emit())
+		}
+	}
+
+	for _, stage := range []string{stageQueued, queue.StageS3Upload, queue.StageLocalMove, queue.StagePublish} {
+		s := spans[stage]
+		if s == nil || s.Parent().SpanID() != process.SpanContext().SpanID() {
+			t.Fatalf("stage span %s = %v", stage, s)
+		}
+	}
+
+	// O evento publicado carrega o contexto do estágio de publicação para o consumidor continuar o trace
+	sink.mu.Lock()
+	uploaded := sink.msgs[0]
+	sink.mu.Unlock()
+	publish := spans[queue.StagePublish].SpanContext()
+	want := "00-" + publish.TraceID().String() + "-" + publish.SpanID().String() + "-01"
+	if uploaded.Kind != queue.KindMediaUploaded || uploaded.Trace["traceparent"] != want {
+		t.Fatalf("uploaded %s traceparent = %q, want %q", uploaded.Kind, uploaded.Trace["traceparent"], want)
+	}
+}
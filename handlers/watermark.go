@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"dvr-upload/catalog"
+	"dvr-upload/processor"
+	"dvr-upload/queue"
+)
+
+var errWatermarkNeedsMP4 = errors.New("watermark is only applied to MP4, TS to MP4 conversion did not produce one")
+
+// watermarkFor monta o watermark do job a partir do template configurado. Placeholders:
+// {imei}, {channel}, {type}, {datetime} e {filename}.
+func (h *Handler) watermarkFor(job *processJob) *processor.Watermark {
+	if !h.cfg.EnableWatermark || job.skipWatermark {
+		return nil
+	}
+
+	captured := job.captureTime
+	if captured.IsZero() {
+		captured = job.startTime
+	}
+	// WATERMARK_TIMEZONE é validado no start; o UTC só cobre configs montadas sem Validate (testes)
+	loc, err := time.LoadLocation(h.cfg.WatermarkTimezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	text := strings.NewReplacer(
+		"{imei}", job.imei,
+		"{channel}", job.channel,
+		"{type}", job.uploadType,
+		"{datetime}", captured.In(loc).Format(h.cfg.WatermarkTimeFormat),
+		"{filename}", job.filename,
+	).Replace(h.cfg.WatermarkTemplate)
+
+	return &processor.Watermark{
+		Text:         text,
+		Position:     h.cfg.WatermarkPosition,
+		FontFile:     h.cfg.WatermarkFontFile,
+		FontSize:     h.cfg.WatermarkFontSize,
+		FontColor:    h.cfg.WatermarkFontColor,
+		BoxColor:     h.cfg.WatermarkBoxColor,
+		LogoPath:     h.cfg.WatermarkLogoPath,
+		LogoPosition: h.cfg.WatermarkLogoPosition,
+	}
+}
+
+// watermarkNotApplied trata a mídia que sairia sem o watermark configurado. Com WATERMARK_REQUIRED o
+// job falha e o erro final é retornado; sem ele o aviso é registrado e o original segue (retorna nil).
+func (h *Handler) watermarkNotApplied(job *processJob, filename string, size int64, err error, logger *slog.Logger) error {
+	if !h.cfg.WatermarkRequired {
+		logger.Warn("Watermark could not be applied, uploading original without it", "error", err)
+		return nil
+	}
+	failure := fmt.Errorf("watermark not applied: %w", err)
+	logger.Error("Watermark could not be applied, media not stored", "error", err)
+	h.processingFailed(job, queue.StageCompress, true, err, logger)
+	atomic.AddInt64(&h.failedUploads, 1)
+	h.recordMedia(job, catalog.Record{
+		Filename: filename,
+		Size:     size,
+		Status:   catalog.StatusFailed,
+		Error:    failure.Error(),
+	}, "")
+	return failure
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata" // WATERMARK_TIMEZONE sem depender do zoneinfo do sistema
+
+	"dvr-upload/catalog"
+	"dvr-upload/config"
+)
+
+// runTestJob processa path com um handler mínimo (sem S3 nem sinks) e retorna o registro gravado
+// no catálogo e o erro final do job.
+func runTestJob(t *testing.T, cfg *config.Config, path string) (catalog.Record, error) {
+	t.Helper()
+	media, err := catalog.Open(filepath.Join(t.TempDir(), "catalog.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { media.Close() })
+
+	cfg.MaxConcurrentWorkers = 1
+	h := NewHandler(cfg, nil, nil, nil, media, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	jobErr := runJob(t, h, path)
+	rec, _, err := media.FindByKey(filepath.Base(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rec, jobErr
+}
+
+// runJob processa path no handler h e espera o fim do job, retornando seu erro final.
+func runJob(t *testing.T, h *Handler, path string) error {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	job := &processJob{
+		requestID:   "req-1",
+		path:        path,
+		filename:    filepath.Base(path),
+		logger:      logger,
+		startTime:   time.Now(),
+		initialSize: info.Size(),
+		imei:        "123456789012",
+		onDone:      func(err error) { done <- err },
+	}
+	go h.processFile(job)
+
+	select {
+	case err = <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("processing did not finish")
+	}
+	return err
+}
+
+func TestWatermarkOnUnconvertedTS(t *testing.T) {
+	tests := []struct {
+		name       string
+		tsToMp4    bool
+		required   bool
+		wantErr    string
+		wantStatus string
+	}{
+		{name: "remux fails, watermark required", tsToMp4: true, required: true, wantErr: "watermark not applied", wantStatus: catalog.StatusFailed},
+		{name: "remux disabled, watermark required", tsToMp4: false, required: true, wantErr: "watermark not applied", wantStatus: catalog.StatusFailed},
+		{name: "remux fails, watermark optional", tsToMp4: true, required: false, wantStatus: catalog.StatusStored},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Bytes que não são MPEG-TS: o remux falha com ou sem ffmpeg instalado
+			path := filepath.Join(t.TempDir(), "EVENT_123456789012_00000000_2024_05_01_08_30_15_I_1.ts")
+			if err := os.WriteFile(path, []byte("not a transport stream"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			cfg := &config.Config{
+				EnableTsToMp4:     tt.tsToMp4,
+				EnableWatermark:   true,
+				WatermarkRequired: tt.required,
+				WatermarkTemplate: "IMEI {imei}",
+			}
+
+			rec, err := runTestJob(t, cfg, path)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("job failed: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("job error = %v, want one containing %q", err, tt.wantErr)
+			}
+			if rec.Status != tt.wantStatus {
+				t.Fatalf("catalog status = %q, want %q", rec.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestWatermarkFor(t *testing.T) {
+	captured := time.Date(2024, 5, 1, 11, 30, 15, 0, time.UTC)
+	job := &processJob{
+		imei:        "123456789012",
+		channel:     "2",
+		uploadType:  "I",
+		captureTime: captured,
+		startTime:   captured.Add(time.Minute),
+		filename:    "EVENT_123456789012_00000000_2024_05_01_11_30_15_I_2.mp4",
+	}
+	tests := []struct {
+		name string
+		cfg  config.Config
+		job  processJob
+		want string // texto renderizado; vazio = sem watermark
+	}{
+		{
+			name: "disabled",
+			cfg:  config.Config{WatermarkTemplate: "{imei}"},
+			job:  *job,
+		},
+		{
+			name: "placeholders",
+			cfg:  config.Config{EnableWatermark: true, WatermarkTemplate: "{imei} CH{channel} {type} {datetime} {filename}", WatermarkTimeFormat: "2006-01-02 15:04:05", WatermarkTimezone: "UTC"},
+			job:  *job,
+			want: "123456789012 CH2 I 2024-05-01 11:30:15 EVENT_123456789012_00000000_2024_05_01_11_30_15_I_2.mp4",
+		},
+		{
+			name: "timezone",
+			cfg:  config.Config{EnableWatermark: true, WatermarkTemplate: "{datetime}", WatermarkTimeFormat: "15:04 MST", WatermarkTimezone: "Etc/GMT+3"},
+			job:  *job,
+			want: "08:30 -03",
+		},
+		{
+			name: "receive time without capture time",
+			cfg:  config.Config{EnableWatermark: true, WatermarkTemplate: "{datetime}", WatermarkTimeFormat: "15:04:05", WatermarkTimezone: "UTC"},
+			job:  processJob{startTime: captured.Add(time.Minute)},
+			want: "11:31:15",
+		},
+		{
+			name: "reprocessing skips the watermark",
+			cfg:  config.Config{EnableWatermark: true, WatermarkTemplate: "{imei}"},
+			job:  processJob{imei: "123456789012", skipWatermark: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{cfg: &tt.cfg}
+			wm := h.watermarkFor(&tt.job)
+			if tt.want == "" {
+				if wm != nil {
+					t.Fatalf("watermark = %+v, want none", wm)
+				}
+				return
+			}
+			if wm == nil {
+				t.Fatal("no watermark")
+			}
+			if wm.Text != tt.want {
+				t.Fatalf("text = %q, want %q", wm.Text, tt.want)
+			}
+		})
+	}
+}
@@ -1,17 +1,24 @@
 package main
 
 import (
+	"context"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	"time"
+	_ "time/tzdata" // Fusos horários do watermark na imagem Alpine
 
+	"dvr-upload/catalog"
 	"dvr-upload/config"
 	"dvr-upload/handlers"
 	"dvr-upload/queue"
 	"dvr-upload/storage"
+	"dvr-upload/tracing"
 	"dvr-upload/utils"
 )
 
@@ -20,7 +27,23 @@ var (
 )
 
 func main() {
-	cfg := config.LoadConfig()
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		os.Exit(runVerify(os.Args[2:]))
+	}
+	os.Exit(run())
+}
+
+// run sobe o serviço e bloqueia até SIGINT/SIGTERM ou falha do servidor HTTP. Retorna o código de
+// saída só depois dos defers (flush de eventos, traces e logs), que os.Exit não executaria.
+func run() int {
+
+	// Arquivo de configuração opcional (YAML ou TOML); variáveis de ambiente têm precedência
+	configFile := os.Getenv("CONFIG_FILE")
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		slog.Error("Invalid configuration", "file", configFile, "error", err)
+		return 1
+	}
 
 	// setup logging
 	var mw io.Writer = os.Stdout
@@ -32,14 +55,21 @@ func main() {
 		slog.Error("Failed to initialize log rotator", "error", err, "dir", logDir)
 	}
 
+	// Nível de log ajustável em tempo de execução pela API de admin
+	logLevel := new(slog.LevelVar)
+	if err := logLevel.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		slog.Error("Invalid LOG_LEVEL, using info", "level", cfg.LogLevel)
+	}
 	handler := slog.NewJSONHandler(mw, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
+		Level:       logLevel,
+		ReplaceAttr: config.RedactLogAttr,
 	})
 	logger = slog.New(handler)
 	slog.SetDefault(logger)
 
 	logger.Info("[UploadServer] Starting server",
-		"listen_addr", ":23010",
+		"listen_addr", cfg.ListenAddr,
+		"config_file", configFile,
 		"video_path", cfg.VideoPath,
 		"backup_path", cfg.BackupPath,
 		"dr_mode", cfg.DisasterRecoveryMode)
@@ -48,34 +78,201 @@ func main() {
 		os.MkdirAll(cfg.VideoPath, 0755)
 	}
 
+	// Tracing: spans do recebimento, de cada estágio do processamento e da publicação
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:     cfg.TracingExporter,
+		OTLPEndpoint: cfg.TracingOTLPEndpoint,
+		ServiceName:  cfg.TracingServiceName,
+		SampleRatio:  cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		logger.Warn("Failed to initialize tracing, spans will not be exported", "error", err)
+	} else {
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				logger.Warn("Failed to flush pending spans", "error", err)
+			}
+		}()
+		if cfg.TracingExporter != tracing.ExporterNone {
+			logger.Info("Tracing enabled", "exporter", cfg.TracingExporter, "sample_ratio", cfg.TracingSampleRatio)
+		}
+	}
+
 	// Inicia tarefa de limpeza de arquivos temporários órfãos
 	utils.StartCleanupTask(cfg.VideoPath, cfg.BackupPath, logger)
 
 	storageService := storage.NewStorageService(cfg, logger)
 
+	if cfg.EnableWatermark && cfg.WatermarkLogoPath != "" {
+		// O logo é lido a cada encode; aqui só avisa cedo que os encodes com watermark vão falhar
+		if _, err := os.Stat(cfg.WatermarkLogoPath); err != nil {
+			logger.Warn("Watermark logo is not readable, watermarked encodes will fail", "error", err, "path", cfg.WatermarkLogoPath)
+		}
+	}
+
+	var cloudEvents *queue.CloudEvents
+	switch cfg.CloudEventsMode {
+	case "":
+	case queue.CloudEventsBinary, queue.CloudEventsStructured:
+		cloudEvents = &queue.CloudEvents{Mode: cfg.CloudEventsMode, Source: cfg.CloudEventsSource, TypePrefix: cfg.CloudEventsTypePrefix}
+	default:
+		logger.Warn("Unknown CLOUDEVENTS_MODE, publishing plain JSON events", "mode", cfg.CloudEventsMode)
+	}
+
 	var rabbitMQ *queue.RabbitMQClient
 	if cfg.EnableRabbitMQ {
 		var err error
-		rabbitMQ, err = queue.NewRabbitMQClient(cfg.RabbitMQURL, cfg.RabbitMQQueue, cfg.RabbitMQExchange, cfg.RabbitMQTtl, logger)
+		bundleQueue := ""
+		if cfg.EnableBundles {
+			bundleQueue = cfg.RabbitMQBundleQueue
+		}
+		lifecycleQueue := ""
+		if cfg.EnableLifecycleEvents {
+			lifecycleQueue = cfg.RabbitMQLifecycleRoutingKey
+		}
+		rabbitMQ, err = queue.NewRabbitMQClient(cfg.RabbitMQURL, cfg.RabbitMQQueue, bundleQueue, lifecycleQueue, cfg.RabbitMQExchange, queue.QueueOptions{
+			TTL:                  cfg.RabbitMQTtl,
+			Type:                 cfg.RabbitMQQueueType,
+			DeadLetterExchange:   cfg.RabbitMQDeadLetterExchange,
+			DeadLetterRoutingKey: cfg.RabbitMQDeadLetterRoutingKey,
+			DeadLetterQueue:      cfg.RabbitMQDeadLetterQueue,
+			MaxLength:            cfg.RabbitMQMaxLength,
+			Overflow:             cfg.RabbitMQOverflow,
+			Strict:               cfg.RabbitMQStrictDeclare,
+		}, cloudEvents, logger)
 		if err != nil {
 			logger.Warn("Failed to initialize RabbitMQ client", "error", err)
+		}
+	}
+
+	// Sinks de eventos: RabbitMQ, webhooks HTTP, NATS JetStream e Kafka
+	var sinks []queue.EventSink
+	if rabbitMQ != nil {
+		sinks = append(sinks, rabbitMQ)
+	}
+	if cfg.EnableWebhooks {
+		if len(cfg.WebhookEndpoints) == 0 {
+			logger.Warn("Webhooks enabled but no endpoint configured (WEBHOOK_URL or WEBHOOK_ENDPOINTS)")
 		} else {
-			defer rabbitMQ.Close()
+			sinks = append(sinks, queue.NewWebhookSink(cfg.WebhookEndpoints, queue.WebhookOptions{
+				MaxAttempts: cfg.WebhookMaxAttempts,
+				Backoff:     time.Duration(cfg.WebhookBackoff) * time.Second,
+				Timeout:     time.Duration(cfg.WebhookTimeout) * time.Second,
+				Workers:     cfg.WebhookWorkers,
+				QueueSize:   cfg.WebhookQueueSize,
+				LogSize:     cfg.WebhookDeliveryLogs,
+				CloudEvents: cloudEvents,
+			}, logger))
 		}
 	}
+	if cfg.EnableNATS {
+		natsSink, err := queue.NewNATSSink(queue.NATSOptions{
+			URL:             cfg.NATSURL,
+			CredsFile:       cfg.NATSCredsFile,
+			SubjectTemplate: cfg.NATSSubjectTemplate,
+			Stream:          cfg.NATSStream,
+			StreamSubjects:  cfg.NATSStreamSubjects,
+			CloudEvents:     cloudEvents,
+		}, logger)
+		if err != nil {
+			logger.Warn("Failed to initialize NATS sink", "error", err)
+		} else {
+			sinks = append(sinks, natsSink)
+		}
+	}
+	if cfg.EnableKafka {
+		kafkaSink, err := queue.NewKafkaSink(queue.KafkaOptions{
+			Brokers:       cfg.KafkaBrokers,
+			TopicTemplate: cfg.KafkaTopicTemplate,
+			CloudEvents:   cloudEvents,
+		}, logger)
+		if err != nil {
+			logger.Warn("Failed to initialize Kafka sink", "error", err)
+		} else {
+			sinks = append(sinks, kafkaSink)
+		}
+	}
+	events := queue.NewMultiSink(sinks...)
+	defer events.Close()
 
-	h := handlers.NewHandler(cfg, storageService, rabbitMQ, logger)
+	var mediaCatalog *catalog.Catalog
+	if cfg.EnableCatalog {
+		var err error
+		mediaCatalog, err = catalog.Open(cfg.CatalogPath)
+		if err != nil {
+			logger.Warn("Failed to open media catalog, catalog disabled", "error", err, "path", cfg.CatalogPath)
+		} else {
+			defer mediaCatalog.Close()
+		}
+	}
+
+	h := handlers.NewHandler(cfg, storageService, rabbitMQ, events, mediaCatalog, logger)
+	h.UseLogLevel(logLevel)
+
+	if cfg.EnableCommands {
+		if rabbitMQ == nil {
+			logger.Warn("Command queue enabled but RabbitMQ is not available, commands disabled")
+		} else if err := rabbitMQ.ConsumeCommands(cfg.RabbitMQCommandQueue, cfg.RabbitMQCommandResultQueue, cfg.CommandWorkers, h.HandleCommand); err != nil {
+			logger.Warn("Failed to start command consumer", "error", err)
+		} else {
+			logger.Info("Command consumer started", "queue", cfg.RabbitMQCommandQueue, "result_queue", cfg.RabbitMQCommandResultQueue)
+		}
+	}
+
+	// SIGHUP recarrega a configuração e aplica os campos recarregáveis sem reinício
+	go reloadOnSignal(configFile, h, logLevel)
 
 	// Inicia recuperação de arquivos pendentes de crash anterior
 	go h.StartRecoveryTask()
 
+	// Verifica as dependências em segundo plano; /health e /readyz leem o cache
+	go h.StartProbes()
+
 	mux := http.NewServeMux()
 	mux.HandleFunc("/upload", h.UploadHandler)
 	mux.HandleFunc("/health", h.HealthHandler)
-	mux.HandleFunc("/test", h.TestPageHandler)
+	mux.HandleFunc("GET /livez", h.LivenessHandler)
+	mux.HandleFunc("GET /readyz", h.ReadinessHandler)
+	mux.HandleFunc("/media", h.RequireAdmin(h.MediaHandler))
+	mux.HandleFunc("GET /media/{key}/url", h.RequireAdmin(h.MediaURLHandler))
+	mux.HandleFunc("GET /files/{key...}", h.FilesHandler)
+	mux.HandleFunc("POST /verify", h.RequireAdmin(h.VerifyHandler))
+
+	// SIGINT/SIGTERM (docker stop, deploy) inicia o desligamento gracioso
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	serverErr := make(chan error, 1)
+
+	// API de admin em endereço separado, fora do alcance das câmeras
+	var adminSrv *http.Server
+	if cfg.AdminListenAddr != "" {
+		if len(cfg.AdminTokens) == 0 {
+			logger.Warn("Admin API enabled but ADMIN_TOKENS is empty, every admin request will be rejected")
+		}
+		adminMux := http.NewServeMux()
+		h.AdminRoutes(adminMux)
+		h.DashboardRoutes(adminMux)
+		adminSrv = &http.Server{
+			Addr:    cfg.AdminListenAddr,
+			Handler: adminMux,
+			// Requisições longas (/events/stream) terminam assim que o desligamento começa
+			BaseContext:       func(net.Listener) context.Context { return ctx },
+			ReadTimeout:       5 * time.Minute, // upload de teste do dashboard
+			WriteTimeout:      5 * time.Minute,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			logger.Info("Starting admin HTTP server", "addr", adminSrv.Addr)
+			if err := adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Error("Admin server failed", "error", err)
+			}
+		}()
+	}
 
 	srv := &http.Server{
-		Addr:              ":23010",
+		Addr:              cfg.ListenAddr,
 		Handler:           mux,
 		ReadTimeout:       5 * time.Minute, // Limite máximo para upload da câmera
 		WriteTimeout:      0,               // Permite processamento longo
@@ -84,9 +281,59 @@ func main() {
 		MaxHeaderBytes:    1 << 20,          // 1MB
 	}
 
-	logger.Info("Starting HTTP server", "addr", srv.Addr)
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	go func() {
+		logger.Info("Starting HTTP server", "addr", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			serverErr <- err
+		}
+	}()
+
+	exitCode := 0
+	select {
+	case <-ctx.Done():
+		logger.Info("Shutdown signal received, finishing in-flight requests", "timeout_seconds", cfg.ShutdownTimeout)
+	case err := <-serverErr:
 		logger.Error("Server failed", "error", err)
-		os.Exit(1)
+		exitCode = 1
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout)*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Warn("HTTP server did not shut down cleanly", "error", err)
+	}
+	if adminSrv != nil {
+		if err := adminSrv.Shutdown(shutdownCtx); err != nil {
+			logger.Warn("Admin server did not shut down cleanly", "error", err)
+		}
+	}
+	// Bundles pendentes saem antes de os sinks de eventos serem fechados (defer events.Close)
+	h.CloseBundles(shutdownCtx)
+	// Webhooks ainda na fila são entregues dentro do mesmo prazo
+	for _, sink := range events.Sinks() {
+		if d, ok := sink.(interface{ Drain(context.Context) }); ok {
+			d.Drain(shutdownCtx)
+		}
+	}
+	logger.Info("Server stopped")
+	return exitCode
+}
+
+// reloadOnSignal relê a configuração a cada SIGHUP. Uma configuração inválida é descartada e a
+// atual continua valendo.
+func reloadOnSignal(configFile string, h *handlers.Handler, logLevel *slog.LevelVar) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		cfg, err := config.Load(configFile)
+		if err != nil {
+			logger.Error("Configuration reload failed, keeping current settings", "file", configFile, "error", err)
+			continue
+		}
+		applied, restartRequired := h.Reload(cfg)
+		logger.Info("Configuration reloaded", "file", configFile, "applied", applied, "log_level", logLevel.Level().String())
+		if len(restartRequired) > 0 {
+			logger.Warn("Changed settings take effect only after a restart", "settings", restartRequired)
+		}
 	}
 }
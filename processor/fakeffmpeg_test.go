@@ -0,0 +1,45 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeFFmpeg coloca no PATH scripts ffmpeg/ffprobe falsos. O ffprobe imprime probeOutput; o ffmpeg
+// registra os argumentos de cada chamada e cria o arquivo de saída (último argumento). Retorna uma
+// função com os argumentos das chamadas ao ffmpeg, uma linha por chamada.
+func fakeFFmpeg(t *testing.T, probeOutput string) func() []string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg scripts need a POSIX shell")
+	}
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls.log")
+
+	scripts := map[string]string{
+		"ffprobe": "#!/bin/sh\nprintf '%s\\n' \"$FAKE_FFPROBE_OUTPUT\"\n",
+		"ffmpeg":  "#!/bin/sh\nprintf '%s\\n' \"$*\" >> \"$FAKE_FFMPEG_CALLS\"\nfor last; do :; done\n: > \"$last\"\n",
+	}
+	for name, script := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_FFPROBE_OUTPUT", probeOutput)
+	t.Setenv("FAKE_FFMPEG_CALLS", calls)
+
+	return func() []string {
+		data, err := os.ReadFile(calls)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+}
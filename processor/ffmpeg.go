@@ -1,17 +1,44 @@
 package processor
 
 import (
+	"fmt"
 	"log/slog"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
 
-func CompressWithFFmpeg(inputPath string, logger *slog.Logger) (string, error) {
+// ProbeDuration retorna a duração da mídia em segundos, lida pelo ffprobe.
+func ProbeDuration(inputPath string) (float64, error) {
+	cmd := exec.Command("ffprobe", "-v", "error", "-show_entries", "format=duration", "-of", "csv=p=0", inputPath)
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe failed: %w", err)
+	}
+	value := strings.TrimSpace(string(output))
+	if value == "" || value == "N/A" {
+		return 0, fmt.Errorf("duration not available")
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// CompressWithFFmpeg re-encoda o MP4 com o perfil informado (nil usa DefaultProfile) e, se wm
+// for informado, aplica o watermark no mesmo encode.
+func CompressWithFFmpeg(inputPath string, profile *TranscodeProfile, wm *Watermark, logger *slog.Logger) (string, error) {
+	if profile == nil {
+		profile = &DefaultProfile
+	}
+
 	// Verificar se o arquivo tem stream de vídeo antes de tentar comprimir
 	probeCmd := exec.Command("ffprobe", "-v", "error", "-select_streams", "v:0", "-show_entries", "stream=codec_type", "-of", "csv=p=0", inputPath)
 	probeOutput, err := probeCmd.Output()
+	if err != nil && wm != nil {
+		// Com watermark não dá para seguir com o original sem saber se há vídeo a marcar
+		return "", fmt.Errorf("ffprobe failed: %w", err)
+	}
 	if err != nil || strings.TrimSpace(string(probeOutput)) == "" {
 		// Se não tem vídeo ou erro no ffprobe, ignora compressão e não retorna erro (prosseguirá com original)
 		logger.Info("File does not contain a video stream or ffprobe failed, skipping compression", "path", inputPath)
@@ -22,18 +49,46 @@ func CompressWithFFmpeg(inputPath string, logger *slog.Logger) (string, error) {
 	// Usar extensão .mp4 para que o ffmpeg consiga detectar o formato do muxer corretamente
 	outputPath := inputPath + ".compressed.mp4"
 
-	// Setup otimizado para máxima eficiência de compressão (tamanho vs qualidade):
+	args := []string{"-i", inputPath}
+	if wm != nil {
+		textFile := inputPath + ".watermark.txt"
+		if err := os.WriteFile(textFile, []byte(wm.Text), 0644); err != nil {
+			return "", fmt.Errorf("failed to write watermark text: %w", err)
+		}
+		defer os.Remove(textFile)
+
+		filter, extraInputs, err := wm.buildFilter(textFile)
+		if err != nil {
+			return "", err
+		}
+		if scale := profile.scaleFilter(); scale != "" {
+			filter = "[0:v]" + scale + "[scaled];" + strings.Replace(filter, "[0:v]", "[scaled]", 1)
+		}
+		args = append(args, extraInputs...)
+		args = append(args,
+			"-filter_complex", filter,
+			"-map", "[vout]",
+			"-map", "0:a?",
+			"-c:a", "copy",
+		)
+	} else if scale := profile.scaleFilter(); scale != "" {
+		args = append(args, "-vf", scale)
+	}
+
+	// Setup padrão otimizado para máxima eficiência de compressão (tamanho vs qualidade):
 	// Preset 'ultrafast' para reduzir tempo de CPU ao máximo em troca de arquivos um pouco maiores
 	// CRF 30 oferece uma compressão excelente (arquivos bem pequenos) com qualidade aceitável para DVR.
+	// Outros perfis (ex: reprocessamento para arquivo) trocam CRF/preset.
 	// -movflags +faststart permite que o vídeo comece a tocar antes de baixar todo o arquivo.
-	cmd := exec.Command("ffmpeg", "-i", inputPath,
+	args = append(args,
 		"-c:v", "libx264",
-		"-crf", "30",
-		"-preset", "ultrafast",
+		"-crf", strconv.Itoa(profile.CRF),
+		"-preset", profile.Preset,
 		"-threads", "1",
 		"-movflags", "+faststart",
 		"-pix_fmt", "yuv420p", // Garante compatibilidade máxima com browsers/players
 		"-y", outputPath)
+	cmd := exec.Command("ffmpeg", args...)
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -0,0 +1,105 @@
+package processor
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompressWithFFmpegWatermark(t *testing.T) {
+	dir := t.TempDir()
+	logo := filepath.Join(dir, "logo.png")
+	if err := os.WriteFile(logo, []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		probe     string // saída do ffprobe (stream de vídeo)
+		profile   *TranscodeProfile
+		wm        *Watermark
+		wantCall  []string // trechos esperados na chamada ao ffmpeg
+		wantNoOut bool     // sem compressão: o original segue
+		wantErr   string
+	}{
+		{
+			name:     "no watermark",
+			probe:    "video",
+			wantCall: []string{"-c:v libx264 -crf 30 -preset ultrafast"},
+		},
+		{
+			name:     "text watermark in the same encode",
+			probe:    "video",
+			wm:       &Watermark{Text: "IMEI 123", Position: "top-left"},
+			wantCall: []string{"-filter_complex [0:v]drawtext=textfile=", ":expansion=none:x=10:y=10[vout] -map [vout] -map 0:a? -c:a copy"},
+		},
+		{
+			name:     "scaled profile with watermark and logo",
+			probe:    "video",
+			profile:  &TranscodeProfile{Name: "low", CRF: 32, Preset: "veryfast", MaxHeight: 480},
+			wm:       &Watermark{Text: "IMEI 123", Position: "top-left", LogoPath: logo, LogoPosition: "bottom-right"},
+			wantCall: []string{"-i " + logo, "[0:v]scale=", "[scaled];[scaled]drawtext=", "[txt][1:v]overlay=x=main_w-overlay_w-10:y=main_h-overlay_h-10[vout]", "-crf 32 -preset veryfast"},
+		},
+		{
+			name:      "no video stream without watermark keeps the original",
+			probe:     "",
+			wantNoOut: true,
+		},
+		{
+			name:    "missing logo fails the encode",
+			probe:   "video",
+			wm:      &Watermark{Text: "IMEI 123", LogoPath: filepath.Join(dir, "nope.png")},
+			wantErr: "watermark logo",
+		},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := fakeFFmpeg(t, tt.probe)
+			input := filepath.Join(t.TempDir(), "video.mp4")
+			if err := os.WriteFile(input, []byte("video"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			out, err := CompressWithFFmpeg(input, tt.profile, tt.wm, logger)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				if got := calls(); len(got) != 0 {
+					t.Fatalf("ffmpeg called after the error: %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantNoOut {
+				if out != "" || len(calls()) != 0 {
+					t.Fatalf("compressed to %q, want the original kept", out)
+				}
+				return
+			}
+
+			if out != input+".compressed.mp4" {
+				t.Fatalf("output = %q", out)
+			}
+			got := calls()
+			if len(got) != 1 {
+				t.Fatalf("ffmpeg calls = %q", got)
+			}
+			for _, want := range tt.wantCall {
+				if !strings.Contains(got[0], want) {
+					t.Fatalf("ffmpeg call %q does not contain %q", got[0], want)
+				}
+			}
+			// O arquivo com o texto do watermark é temporário
+			if _, err := os.Stat(input + ".watermark.txt"); !os.IsNotExist(err) {
+				t.Fatalf("watermark text file left behind: %v", err)
+			}
+		})
+	}
+}
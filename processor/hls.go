@@ -0,0 +1,153 @@
+package processor
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HLSOptions configura o empacotamento HLS.
+type HLSOptions struct {
+	SegmentDuration int      // segundos
+	SegmentFormat   string   // "mpegts" ou "fmp4"
+	Renditions      []string // renditions extras além da original: "low", "high"
+}
+
+// HLSResult descreve a saída gerada por PackageHLS. Os caminhos são relativos ao outputDir.
+type HLSResult struct {
+	Playlist   string
+	Renditions []string
+}
+
+type hlsRendition struct {
+	height       int
+	videoBitrate int // kbps
+	audioBitrate int // kbps
+}
+
+// Presets das renditions transcodificadas. A rendition "source" é sempre um remux sem re-encode.
+var hlsRenditions = map[string]hlsRendition{
+	"low":  {height: 360, videoBitrate: 600, audioBitrate: 64},
+	"high": {height: 720, videoBitrate: 2000, audioBitrate: 128},
+}
+
+// PackageHLS segmenta o vídeo em HLS dentro de outputDir: uma master playlist index.m3u8
+// apontando para a rendition "source" (sem re-encode) e para as renditions extras pedidas.
+func PackageHLS(inputPath, outputDir string, opts HLSOptions, logger *slog.Logger) (*HLSResult, error) {
+	start := time.Now()
+	if opts.SegmentDuration <= 0 {
+		opts.SegmentDuration = 6
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create HLS directory: %w", err)
+	}
+
+	duration, err := ProbeDuration(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe duration: %w", err)
+	}
+
+	var master strings.Builder
+	master.WriteString("#EXTM3U\n#EXT-X-VERSION:7\n")
+	result := &HLSResult{Playlist: "index.m3u8"}
+
+	// Rendition original: bitrate estimado pelo tamanho do arquivo
+	sourceBandwidth := 0
+	if stat, err := os.Stat(inputPath); err == nil && duration > 0 {
+		sourceBandwidth = int(float64(stat.Size()*8) / duration)
+	}
+	if err := segmentHLS(inputPath, filepath.Join(outputDir, "source"), opts, nil); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(&master, "#EXT-X-STREAM-INF:BANDWIDTH=%d\nsource/index.m3u8\n", sourceBandwidth)
+	result.Renditions = append(result.Renditions, "source")
+
+	for _, name := range opts.Renditions {
+		preset, ok := hlsRenditions[name]
+		if !ok {
+			logger.Warn("Unknown HLS rendition, skipping", "rendition", name)
+			continue
+		}
+		if err := segmentHLS(inputPath, filepath.Join(outputDir, name), opts, &preset); err != nil {
+			return nil, err
+		}
+		bandwidth := (preset.videoBitrate + preset.audioBitrate) * 1000
+		fmt.Fprintf(&master, "#EXT-X-STREAM-INF:BANDWIDTH=%d\n%s/index.m3u8\n", bandwidth, name)
+		result.Renditions = append(result.Renditions, name)
+	}
+
+	if err := os.WriteFile(filepath.Join(outputDir, result.Playlist), []byte(master.String()), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write master playlist: %w", err)
+	}
+
+	logger.Info("HLS packaging finished",
+		"renditions", result.Renditions,
+		"segment_duration", opts.SegmentDuration,
+		"segment_format", opts.SegmentFormat,
+		"duration", time.Since(start).String())
+	return result, nil
+}
+
+func segmentHLS(inputPath, dir string, opts HLSOptions, preset *hlsRendition) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create rendition directory: %w", err)
+	}
+
+	args := []string{"-y", "-i", inputPath, "-map", "0:v:0", "-map", "0:a:0?"}
+	if preset == nil {
+		args = append(args, "-c", "copy")
+	} else {
+		args = append(args,
+			"-vf", fmt.Sprintf("scale=-2:%d", preset.height),
+			"-c:v", "libx264",
+			"-preset", "veryfast",
+			"-b:v", strconv.Itoa(preset.videoBitrate)+"k",
+			"-maxrate", strconv.Itoa(preset.videoBitrate*3/2)+"k",
+			"-bufsize", strconv.Itoa(preset.videoBitrate*2)+"k",
+			"-pix_fmt", "yuv420p",
+			// Keyframes alinhados à duração do segmento
+			"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", opts.SegmentDuration),
+			"-c:a", "aac",
+			"-b:a", strconv.Itoa(preset.audioBitrate)+"k",
+		)
+	}
+
+	args = append(args,
+		"-f", "hls",
+		"-hls_time", strconv.Itoa(opts.SegmentDuration),
+		"-hls_playlist_type", "vod",
+	)
+	if opts.SegmentFormat == "fmp4" {
+		args = append(args,
+			"-hls_segment_type", "fmp4",
+			"-hls_fmp4_init_filename", "init.mp4",
+			"-hls_segment_filename", filepath.Join(dir, "seg_%05d.m4s"),
+		)
+	} else {
+		args = append(args,
+			"-hls_segment_type", "mpegts",
+			"-hls_segment_filename", filepath.Join(dir, "seg_%05d.ts"),
+		)
+	}
+	args = append(args, filepath.Join(dir, "index.m3u8"))
+
+	output, err := exec.Command("ffmpeg", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ffmpeg HLS segmentation failed (%s): %w: %s", filepath.Base(dir), err, lastLines(string(output), 5))
+	}
+	return nil
+}
+
+// lastLines mantém apenas o final da saída do ffmpeg, onde fica a mensagem de erro.
+func lastLines(output string, n int) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, " | ")
+}
@@ -0,0 +1,101 @@
+package processor
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPackageHLS(t *testing.T) {
+	tests := []struct {
+		name           string
+		opts           HLSOptions
+		wantRenditions []string
+		wantMaster     string
+		wantArgs       []string // trechos esperados em cada chamada, na ordem
+	}{
+		{
+			name:           "source only, mpegts",
+			opts:           HLSOptions{SegmentFormat: "mpegts"},
+			wantRenditions: []string{"source"},
+			wantMaster:     "#EXTM3U\n#EXT-X-VERSION:7\n#EXT-X-STREAM-INF:BANDWIDTH=8000\nsource/index.m3u8\n",
+			wantArgs:       []string{"-c copy -f hls -hls_time 6 -hls_playlist_type vod -hls_segment_type mpegts"},
+		},
+		{
+			name:           "extra renditions, fmp4",
+			opts:           HLSOptions{SegmentDuration: 4, SegmentFormat: "fmp4", Renditions: []string{"low", "unknown", "high"}},
+			wantRenditions: []string{"source", "low", "high"},
+			wantMaster: "#EXTM3U\n#EXT-X-VERSION:7\n" +
+				"#EXT-X-STREAM-INF:BANDWIDTH=8000\nsource/index.m3u8\n" +
+				"#EXT-X-STREAM-INF:BANDWIDTH=664000\nlow/index.m3u8\n" +
+				"#EXT-X-STREAM-INF:BANDWIDTH=2128000\nhigh/index.m3u8\n",
+			wantArgs: []string{
+				"-c copy -f hls -hls_time 4 -hls_playlist_type vod -hls_segment_type fmp4 -hls_fmp4_init_filename init.mp4",
+				"-vf scale=-2:360 -c:v libx264 -preset veryfast -b:v 600k -maxrate 900k -bufsize 1200k -pix_fmt yuv420p -force_key_frames expr:gte(t,n_forced*4)",
+				"-vf scale=-2:720 -c:v libx264 -preset veryfast -b:v 2000k",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := fakeFFmpeg(t, "10.0")
+			dir := t.TempDir()
+			input := filepath.Join(dir, "video.mp4")
+			// 10 KB em 10 s: bitrate estimado da rendition source = 8000 bps
+			if err := os.WriteFile(input, make([]byte, 10000), 0644); err != nil {
+				t.Fatal(err)
+			}
+			out := filepath.Join(dir, "video.mp4.hls")
+
+			result, err := PackageHLS(input, out, tt.opts, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Playlist != "index.m3u8" || !reflect.DeepEqual(result.Renditions, tt.wantRenditions) {
+				t.Fatalf("result = %+v, want renditions %v", result, tt.wantRenditions)
+			}
+			master, err := os.ReadFile(filepath.Join(out, "index.m3u8"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(master) != tt.wantMaster {
+				t.Fatalf("master playlist =\n%s\nwant\n%s", master, tt.wantMaster)
+			}
+
+			got := calls()
+			if len(got) != len(tt.wantArgs) {
+				t.Fatalf("ffmpeg called %d times, want %d: %q", len(got), len(tt.wantArgs), got)
+			}
+			for i, want := range tt.wantArgs {
+				if !strings.Contains(got[i], want) {
+					t.Fatalf("ffmpeg call %d = %q, want it to contain %q", i, got[i], want)
+				}
+				rendition := tt.wantRenditions[i]
+				if !strings.HasSuffix(got[i], filepath.Join(out, rendition, "index.m3u8")) {
+					t.Fatalf("ffmpeg call %d does not write %s/index.m3u8: %q", i, rendition, got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPackageHLSWithoutDuration(t *testing.T) {
+	calls := fakeFFmpeg(t, "N/A")
+	dir := t.TempDir()
+	input := filepath.Join(dir, "video.mp4")
+	if err := os.WriteFile(input, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := PackageHLS(input, filepath.Join(dir, "out"), HLSOptions{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err == nil || !strings.Contains(err.Error(), "failed to probe duration") {
+		t.Fatalf("error = %v", err)
+	}
+	if got := calls(); len(got) != 0 {
+		t.Fatalf("ffmpeg called without a duration: %q", got)
+	}
+}
@@ -0,0 +1,316 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	EXIFKeep      = "keep"
+	EXIFStrip     = "strip"
+	EXIFNormalize = "normalize" // aplica a orientação nos pixels e remove os metadados
+)
+
+// ImageVariantSpec define uma variante redimensionada: o maior lado fica com até MaxSize pixels.
+type ImageVariantSpec struct {
+	Name    string
+	MaxSize int
+}
+
+// ImageOptions configura o pipeline de snapshots.
+type ImageOptions struct {
+	EXIFMode     string
+	Variants     []ImageVariantSpec
+	Quality      int
+	ExtraFormats []string // "webp" e/ou "avif", gerados via ffmpeg para cada variante
+	ExtraQuality int
+}
+
+// ImageVariant é um arquivo gerado pelo pipeline.
+type ImageVariant struct {
+	Name   string
+	Format string
+	Path   string
+	Width  int
+	Height int
+	Size   int64
+}
+
+// ProcessImage valida o JPEG, trata o EXIF do original (reescrevendo o arquivo no lugar) e gera as
+// variantes ao lado de inputPath (<inputPath>.<nome>.<formato>).
+func ProcessImage(inputPath string, opts ImageOptions, logger *slog.Logger) ([]ImageVariant, error) {
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JPEG: %w", err)
+	}
+	orientation := jpegOrientation(data)
+	oriented := applyOrientation(img, orientation)
+
+	quality := opts.Quality
+	if quality <= 0 || quality > 100 {
+		quality = 85
+	}
+
+	switch opts.EXIFMode {
+	case EXIFStrip:
+		if err := os.WriteFile(inputPath, stripJPEGMetadata(data), 0644); err != nil {
+			return nil, err
+		}
+	case EXIFNormalize:
+		if orientation > 1 {
+			// Rotação exige re-encode; o re-encode já descarta os metadados
+			if err := writeJPEG(inputPath, oriented, quality); err != nil {
+				return nil, err
+			}
+		} else if err := os.WriteFile(inputPath, stripJPEGMetadata(data), 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	var variants []ImageVariant
+	for _, spec := range opts.Variants {
+		resized := resizeToFit(oriented, spec.MaxSize)
+		path := inputPath + "." + spec.Name + ".jpg"
+		if err := writeJPEG(path, resized, quality); err != nil {
+			return variants, fmt.Errorf("variant %s: %w", spec.Name, err)
+		}
+		b := resized.Bounds()
+		variants = append(variants, newImageVariant(spec.Name, "jpg", path, b.Dx(), b.Dy()))
+
+		for _, format := range opts.ExtraFormats {
+			extraPath := inputPath + "." + spec.Name + "." + format
+			if err := encodeWithFFmpeg(path, extraPath, format, opts.ExtraQuality); err != nil {
+				logger.Warn("Failed to encode image variant", "variant", spec.Name, "format", format, "error", err)
+				continue
+			}
+			variants = append(variants, newImageVariant(spec.Name, format, extraPath, b.Dx(), b.Dy()))
+		}
+	}
+	return variants, nil
+}
+
+func newImageVariant(name, format, path string, w, h int) ImageVariant {
+	v := ImageVariant{Name: name, Format: format, Path: path, Width: w, Height: h}
+	if stat, err := os.Stat(path); err == nil {
+		v.Size = stat.Size()
+	}
+	return v
+}
+
+func writeJPEG(path string, img image.Image, quality int) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := jpeg.Encode(f, img, &jpeg.Options{Quality: quality}); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+func encodeWithFFmpeg(inputPath, outputPath, format string, quality int) error {
+	if quality <= 0 || quality > 100 {
+		quality = 75
+	}
+	var codecArgs []string
+	switch format {
+	case "webp":
+		codecArgs = []string{"-c:v", "libwebp", "-quality", strconv.Itoa(quality)}
+	case "avif":
+		// CRF do AV1 vai de 0 (melhor) a 63; convertemos a escala de qualidade 0-100
+		crf := 63 - quality*63/100
+		codecArgs = []string{"-c:v", "libaom-av1", "-still-picture", "1", "-crf", strconv.Itoa(crf)}
+	default:
+		return fmt.Errorf("unsupported image format %q", format)
+	}
+
+	args := append([]string{"-y", "-i", inputPath, "-map_metadata", "-1"}, codecArgs...)
+	args = append(args, outputPath)
+	output, err := exec.Command("ffmpeg", args...).CombinedOutput()
+	if err != nil {
+		os.Remove(outputPath)
+		return fmt.Errorf("%w: %s", err, lastLines(string(output), 3))
+	}
+	return nil
+}
+
+// resizeToFit reduz a imagem para que o maior lado tenha até maxSize pixels (nunca amplia).
+func resizeToFit(img image.Image, maxSize int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if maxSize <= 0 || (w <= maxSize && h <= maxSize) {
+		return img
+	}
+	if w >= h {
+		h = h * maxSize / w
+		w = maxSize
+	} else {
+		w = w * maxSize / h
+		h = maxSize
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// applyOrientation aplica a orientação EXIF (1-8) nos pixels.
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // espelhado na horizontal
+				dx, dy = w-1-x, y
+			case 3: // 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // espelhado na vertical
+				dx, dy = x, h-1-y
+			case 5: // transposta
+				dx, dy = y, x
+			case 6: // 90° horário
+				dx, dy = h-1-y, x
+			case 7: // transversa
+				dx, dy = h-1-y, w-1-x
+			case 8: // 90° anti-horário
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// jpegSegments percorre os segmentos do cabeçalho JPEG (até o SOS) chamando fn para cada um.
+// offset/length delimitam o segmento completo, incluindo marcador e tamanho.
+func jpegSegments(data []byte, fn func(marker byte, offset, length int) bool) int {
+	pos := 2 // após SOI
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return pos
+		}
+		marker := data[pos+1]
+		if marker == 0xD8 || (marker >= 0xD0 && marker <= 0xD7) || marker == 0x01 {
+			pos += 2
+			continue
+		}
+		if marker == 0xDA { // SOS: a partir daqui vêm os dados comprimidos
+			return pos
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		if length < 2 || pos+2+length > len(data) {
+			return pos
+		}
+		if !fn(marker, pos, 2+length) {
+			return pos
+		}
+		pos += 2 + length
+	}
+	return pos
+}
+
+// stripJPEGMetadata remove EXIF/XMP (APP1) e IPTC (APP13) sem re-encode, mantendo JFIF, ICC e Adobe.
+func stripJPEGMetadata(data []byte) []byte {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return data
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, 0xD8)
+	end := jpegSegments(data, func(marker byte, offset, length int) bool {
+		if marker != 0xE1 && marker != 0xED {
+			out = append(out, data[offset:offset+length]...)
+		}
+		return true
+	})
+	return append(out, data[end:]...)
+}
+
+// jpegOrientation lê a tag Orientation (0x0112) do IFD0 do EXIF. Retorna 1 se ausente.
+func jpegOrientation(data []byte) int {
+	orientation := 1
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return orientation
+	}
+	jpegSegments(data, func(marker byte, offset, length int) bool {
+		if marker != 0xE1 {
+			return true
+		}
+		seg := data[offset+4 : offset+length]
+		if len(seg) < 14 || string(seg[:6]) != "Exif\x00\x00" {
+			return true
+		}
+		tiff := seg[6:]
+		var order binary.ByteOrder
+		switch string(tiff[:2]) {
+		case "II":
+			order = binary.LittleEndian
+		case "MM":
+			order = binary.BigEndian
+		default:
+			return false
+		}
+		ifd := int(order.Uint32(tiff[4:8]))
+		if ifd+2 > len(tiff) {
+			return false
+		}
+		entries := int(order.Uint16(tiff[ifd : ifd+2]))
+		for i := 0; i < entries; i++ {
+			entry := ifd + 2 + i*12
+			if entry+12 > len(tiff) {
+				break
+			}
+			if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+				orientation = int(order.Uint16(tiff[entry+8 : entry+10]))
+				break
+			}
+		}
+		return false
+	})
+	return orientation
+}
+
+// ExtractFrame grava em outputPath (JPEG) um quadro representativo do início do vídeo.
+func ExtractFrame(videoPath, outputPath string) error {
+	output, err := exec.Command("ffmpeg", "-y",
+		"-i", videoPath,
+		"-vf", "thumbnail",
+		"-frames:v", "1",
+		"-q:v", "2",
+		outputPath).CombinedOutput()
+	if err != nil {
+		os.Remove(outputPath)
+		return fmt.Errorf("ffmpeg frame extraction failed: %w: %s", err, lastLines(string(output), 3))
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testJPEG codifica uma imagem w x h e, com orientation > 0, insere logo após o SOI um APP1 EXIF
+// com a tag Orientation (na ordem de bytes pedida) e um APP13 (IPTC).
+func testJPEG(t *testing.T, w, h, orientation int, order binary.ByteOrder) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if orientation == 0 {
+		return data
+	}
+
+	tiff := make([]byte, 26)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8) // IFD0 logo após o cabeçalho
+	order.PutUint16(tiff[8:], 1) // uma entrada
+	order.PutUint16(tiff[10:], 0x0112)
+	order.PutUint16(tiff[12:], 3) // SHORT
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], uint16(orientation))
+
+	segment := func(marker byte, payload []byte) []byte {
+		seg := []byte{0xFF, marker, 0, 0}
+		binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+		return append(seg, payload...)
+	}
+	out := []byte{0xFF, 0xD8}
+	out = append(out, segment(0xE1, append([]byte("Exif\x00\x00"), tiff...))...)
+	out = append(out, segment(0xED, []byte("Photoshop 3.0\x00iptc"))...)
+	return append(out, data[2:]...)
+}
+
+// hasSegment indica se o cabeçalho JPEG tem um segmento com o marcador.
+func hasSegment(data []byte, marker byte) bool {
+	found := false
+	jpegSegments(data, func(m byte, _, _ int) bool {
+		found = found || m == marker
+		return true
+	})
+	return found
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{name: "no EXIF", data: testJPEG(t, 4, 4, 0, nil), want: 1},
+		{name: "little endian", data: testJPEG(t, 4, 4, 6, binary.LittleEndian), want: 6},
+		{name: "big endian", data: testJPEG(t, 4, 4, 8, binary.BigEndian), want: 8},
+		{name: "not a JPEG", data: []byte("not a jpeg"), want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jpegOrientation(tt.data); got != tt.want {
+				t.Fatalf("jpegOrientation() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	// 2x1: vermelho à esquerda, azul à direita
+	red, blue := color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.Set(0, 0, red)
+	src.Set(1, 0, blue)
+
+	tests := []struct {
+		orientation int
+		wantSize    image.Point
+		wantRed     image.Point
+		wantBlue    image.Point
+	}{
+		{orientation: 1, wantSize: image.Pt(2, 1), wantRed: image.Pt(0, 0), wantBlue: image.Pt(1, 0)},
+		{orientation: 2, wantSize: image.Pt(2, 1), wantRed: image.Pt(1, 0), wantBlue: image.Pt(0, 0)},
+		{orientation: 3, wantSize: image.Pt(2, 1), wantRed: image.Pt(1, 0), wantBlue: image.Pt(0, 0)},
+		{orientation: 6, wantSize: image.Pt(1, 2), wantRed: image.Pt(0, 0), wantBlue: image.Pt(0, 1)},
+		{orientation: 8, wantSize: image.Pt(1, 2), wantRed: image.Pt(0, 1), wantBlue: image.Pt(0, 0)},
+	}
+	for _, tt := range tests {
+		got := applyOrientation(src, tt.orientation)
+		if size := got.Bounds().Size(); size != tt.wantSize {
+			t.Fatalf("orientation %d: size = %v, want %v", tt.orientation, size, tt.wantSize)
+		}
+		if c := color.RGBAModel.Convert(got.At(tt.wantRed.X, tt.wantRed.Y)); c != red {
+			t.Fatalf("orientation %d: pixel %v = %v, want red", tt.orientation, tt.wantRed, c)
+		}
+		if c := color.RGBAModel.Convert(got.At(tt.wantBlue.X, tt.wantBlue.Y)); c != blue {
+			t.Fatalf("orientation %d: pixel %v = %v, want blue", tt.orientation, tt.wantBlue, c)
+		}
+	}
+}
+
+func TestProcessImage(t *testing.T) {
+	variants := []ImageVariantSpec{{Name: "thumb", MaxSize: 50}, {Name: "large", MaxSize: 400}}
+	tests := []struct {
+		name         string
+		exifMode     string
+		orientation  int
+		wantMetadata bool        // APP1/APP13 continuam no original
+		wantOriginal image.Point // dimensões do original depois do pipeline
+		wantVariants []image.Point
+	}{
+		{
+			name:         "keep",
+			exifMode:     EXIFKeep,
+			orientation:  6,
+			wantMetadata: true,
+			wantOriginal: image.Pt(200, 100),
+			wantVariants: []image.Point{image.Pt(25, 50), image.Pt(100, 200)},
+		},
+		{
+			name:         "strip",
+			exifMode:     EXIFStrip,
+			orientation:  6,
+			wantOriginal: image.Pt(200, 100),
+			wantVariants: []image.Point{image.Pt(25, 50), image.Pt(100, 200)},
+		},
+		{
+			name:         "normalize rotates the original",
+			exifMode:     EXIFNormalize,
+			orientation:  6,
+			wantOriginal: image.Pt(100, 200),
+			wantVariants: []image.Point{image.Pt(25, 50), image.Pt(100, 200)},
+		},
+		{
+			name:         "normalize without rotation",
+			exifMode:     EXIFNormalize,
+			orientation:  1,
+			wantOriginal: image.Pt(200, 100),
+			wantVariants: []image.Point{image.Pt(50, 25), image.Pt(200, 100)},
+		},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "snapshot.jpg")
+			original := testJPEG(t, 200, 100, tt.orientation, binary.LittleEndian)
+			if err := os.WriteFile(path, original, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ProcessImage(path, ImageOptions{EXIFMode: tt.exifMode, Variants: variants}, logger)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.exifMode == EXIFKeep && !bytes.Equal(data, original) {
+				t.Fatal("original changed with EXIF_MODE=keep")
+			}
+			if hasSegment(data, 0xE1) != tt.wantMetadata || hasSegment(data, 0xED) != tt.wantMetadata {
+				t.Fatalf("metadata kept = %v, want %v", hasSegment(data, 0xE1), tt.wantMetadata)
+			}
+			cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size := image.Pt(cfg.Width, cfg.Height); size != tt.wantOriginal {
+				t.Fatalf("original size = %v, want %v", size, tt.wantOriginal)
+			}
+
+			if len(got) != len(tt.wantVariants) {
+				t.Fatalf("variants = %+v", got)
+			}
+			for i, v := range got {
+				if v.Name != variants[i].Name || v.Format != "jpg" || v.Path != path+"."+v.Name+".jpg" {
+					t.Fatalf("variant %d = %+v", i, v)
+				}
+				if size := image.Pt(v.Width, v.Height); size != tt.wantVariants[i] {
+					t.Fatalf("variant %s size = %v, want %v", v.Name, size, tt.wantVariants[i])
+				}
+				variant, err := os.ReadFile(v.Path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if int64(len(variant)) != v.Size || hasSegment(variant, 0xE1) {
+					t.Fatalf("variant %s: size %d (reported %d), EXIF %v", v.Name, len(variant), v.Size, hasSegment(variant, 0xE1))
+				}
+			}
+		})
+	}
+}
+
+func TestProcessImageExtraFormats(t *testing.T) {
+	calls := fakeFFmpeg(t, "")
+	path := filepath.Join(t.TempDir(), "snapshot.jpg")
+	if err := os.WriteFile(path, testJPEG(t, 64, 32, 0, nil), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ProcessImage(path, ImageOptions{
+		Variants:     []ImageVariantSpec{{Name: "thumb", MaxSize: 32}},
+		ExtraFormats: []string{"webp", "avif", "gif"},
+		ExtraQuality: 80,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var formats []string
+	for _, v := range got {
+		formats = append(formats, v.Format)
+		if v.Width != 32 || v.Height != 16 {
+			t.Fatalf("variant %s.%s size = %dx%d", v.Name, v.Format, v.Width, v.Height)
+		}
+	}
+	// Formato sem suporte é ignorado com aviso
+	if strings.Join(formats, ",") != "jpg,webp,avif" {
+		t.Fatalf("formats = %v", formats)
+	}
+	ffmpeg := calls()
+	if len(ffmpeg) != 2 ||
+		!strings.Contains(ffmpeg[0], "-map_metadata -1 -c:v libwebp -quality 80 "+path+".thumb.webp") ||
+		!strings.Contains(ffmpeg[1], "-c:v libaom-av1 -still-picture 1 -crf 13 "+path+".thumb.avif") {
+		t.Fatalf("ffmpeg calls = %q", ffmpeg)
+	}
+}
+
+func TestProcessImageInvalidJPEG(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.jpg")
+	if err := os.WriteFile(path, []byte("not a jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ProcessImage(path, ImageOptions{EXIFMode: EXIFStrip}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err == nil || !strings.Contains(err.Error(), "invalid JPEG") {
+		t.Fatalf("error = %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package processor
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// RenderMosaic combina os vídeos de entrada lado a lado (em grade) em um único MP4 sem áudio.
+// Cada canal é redimensionado para o mesmo tamanho antes do xstack.
+func RenderMosaic(inputs []string, outputPath string, logger *slog.Logger) error {
+	if len(inputs) < 2 {
+		return fmt.Errorf("mosaic needs at least two inputs, got %d", len(inputs))
+	}
+	start := time.Now()
+
+	cols := int(math.Ceil(math.Sqrt(float64(len(inputs)))))
+	args := []string{"-y"}
+	for _, in := range inputs {
+		args = append(args, "-i", in)
+	}
+
+	var filter strings.Builder
+	var layout []string
+	for i := range inputs {
+		fmt.Fprintf(&filter, "[%d:v:0]scale=640:360:force_original_aspect_ratio=decrease,pad=640:360:(ow-iw)/2:(oh-ih)/2,setsar=1[v%d];", i, i)
+		layout = append(layout, fmt.Sprintf("%s_%s", gridOffset("w0", i%cols), gridOffset("h0", i/cols)))
+	}
+	for i := range inputs {
+		fmt.Fprintf(&filter, "[v%d]", i)
+	}
+	fmt.Fprintf(&filter, "xstack=inputs=%d:layout=%s:fill=black[out]", len(inputs), strings.Join(layout, "|"))
+
+	args = append(args,
+		"-filter_complex", filter.String(),
+		"-map", "[out]",
+		"-an",
+		"-c:v", "libx264",
+		"-crf", "30",
+		"-preset", "ultrafast",
+		"-threads", "1",
+		"-pix_fmt", "yuv420p",
+		"-movflags", "+faststart",
+		outputPath,
+	)
+
+	output, err := exec.Command("ffmpeg", args...).CombinedOutput()
+	if err != nil {
+		logger.Error("FFmpeg mosaic rendering failed",
+			"error", err,
+			"ffmpeg_output", lastLines(string(output), 5),
+			"duration", time.Since(start).String())
+		return err
+	}
+	return nil
+}
+
+// gridOffset monta a posição no layout do xstack: "0", "w0", "w0+w0"...
+func gridOffset(unit string, n int) string {
+	if n == 0 {
+		return "0"
+	}
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = unit
+	}
+	return strings.Join(parts, "+")
+}
@@ -0,0 +1,59 @@
+package processor
+
+import (
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderMosaic(t *testing.T) {
+	tests := []struct {
+		name       string
+		inputs     []string
+		wantLayout string
+		wantErr    string
+	}{
+		{name: "single input", inputs: []string{"1.mp4"}, wantErr: "at least two inputs"},
+		{name: "two channels side by side", inputs: []string{"1.mp4", "2.mp4"}, wantLayout: "xstack=inputs=2:layout=0_0|w0_0:fill=black[out]"},
+		{name: "three channels in a 2x2 grid", inputs: []string{"1.mp4", "2.mp4", "3.mp4"}, wantLayout: "xstack=inputs=3:layout=0_0|w0_0|0_h0:fill=black[out]"},
+		{
+			name:       "five channels in a 3x2 grid",
+			inputs:     []string{"1.mp4", "2.mp4", "3.mp4", "4.mp4", "5.mp4"},
+			wantLayout: "xstack=inputs=5:layout=0_0|w0_0|w0+w0_0|0_h0|w0_h0:fill=black[out]",
+		},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := fakeFFmpeg(t, "")
+			output := filepath.Join(t.TempDir(), "mosaic.mp4")
+			err := RenderMosaic(tt.inputs, output, logger)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := calls()
+			if len(got) != 1 {
+				t.Fatalf("ffmpeg calls = %q", got)
+			}
+			for _, in := range tt.inputs {
+				if !strings.Contains(got[0], "-i "+in) {
+					t.Fatalf("input %s missing from %q", in, got[0])
+				}
+			}
+			if !strings.Contains(got[0], tt.wantLayout) {
+				t.Fatalf("ffmpeg call %q does not contain %q", got[0], tt.wantLayout)
+			}
+			if !strings.HasSuffix(got[0], "-an -c:v libx264 -crf 30 -preset ultrafast -threads 1 -pix_fmt yuv420p -movflags +faststart "+output) {
+				t.Fatalf("ffmpeg call %q does not encode to %s without audio", got[0], output)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package processor
+
+import "fmt"
+
+// TranscodeProfile define os parâmetros do encode H.264 feito na compressão.
+type TranscodeProfile struct {
+	Name      string `json:"name"`
+	CRF       int    `json:"crf"`
+	Preset    string `json:"preset"`
+	MaxHeight int    `json:"max_height,omitempty"` // reduz a resolução mantendo a proporção (0 mantém)
+}
+
+// DefaultProfile é o encode usado no fluxo normal de upload: o mais rápido possível com
+// compressão agressiva, suficiente para DVR.
+var DefaultProfile = TranscodeProfile{Name: "default", CRF: 30, Preset: "ultrafast"}
+
+// BuiltinProfiles são os perfis disponíveis sem configuração adicional.
+var BuiltinProfiles = []TranscodeProfile{
+	DefaultProfile,
+	{Name: "archive", CRF: 23, Preset: "medium"},
+	{Name: "low", CRF: 32, Preset: "veryfast", MaxHeight: 480},
+}
+
+func (p TranscodeProfile) scaleFilter() string {
+	if p.MaxHeight <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("scale=-2:'min(ih,%d)'", p.MaxHeight)
+}
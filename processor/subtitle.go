@@ -0,0 +1,51 @@
+package processor
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// EmbedSubtitle adiciona ao MP4 uma faixa de legenda (mov_text) com o texto informado cobrindo
+// toda a duração do vídeo. Os streams de áudio e vídeo são copiados sem re-encode.
+func EmbedSubtitle(inputPath, text string, logger *slog.Logger) (string, error) {
+	end := 24 * time.Hour
+	if d, err := ProbeDuration(inputPath); err == nil && d > 0 {
+		end = time.Duration(d * float64(time.Second))
+	}
+
+	srtPath := inputPath + ".telemetry.srt"
+	srt := fmt.Sprintf("1\n00:00:00,000 --> %s\n%s\n", srtTimestamp(end), text)
+	if err := os.WriteFile(srtPath, []byte(srt), 0644); err != nil {
+		return "", fmt.Errorf("failed to write subtitle file: %w", err)
+	}
+	defer os.Remove(srtPath)
+
+	outputPath := inputPath + ".subtitled.mp4"
+	start := time.Now()
+	cmd := exec.Command("ffmpeg", "-y",
+		"-i", inputPath,
+		"-i", srtPath,
+		"-map", "0",
+		"-map", "1",
+		"-c", "copy",
+		"-c:s", "mov_text",
+		"-metadata:s:s:0", "title=telemetry",
+		"-movflags", "+faststart",
+		outputPath)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		os.Remove(outputPath)
+		return "", fmt.Errorf("ffmpeg subtitle mux failed: %w: %s", err, lastLines(string(output), 3))
+	}
+
+	logger.Info("Telemetry subtitle track embedded", "duration", time.Since(start).String())
+	return outputPath, nil
+}
+
+func srtTimestamp(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
@@ -0,0 +1,81 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Watermark descreve a sobreposição (texto e logo opcional) aplicada durante a compressão.
+type Watermark struct {
+	Text         string // texto já renderizado a partir do template
+	Position     string // top-left, top-right, bottom-left, bottom-right
+	FontFile     string
+	FontSize     int
+	FontColor    string
+	BoxColor     string // vazio desativa a caixa de fundo
+	LogoPath     string
+	LogoPosition string
+}
+
+const watermarkMargin = 10
+
+// overlayPosition retorna as expressões x/y para a posição pedida. w/h são as dimensões
+// do vídeo e ow/oh as do elemento sobreposto.
+func overlayPosition(position, w, h, ow, oh string) (string, string) {
+	x := fmt.Sprintf("%d", watermarkMargin)
+	y := fmt.Sprintf("%d", watermarkMargin)
+	if strings.Contains(position, "right") {
+		x = fmt.Sprintf("%s-%s-%d", w, ow, watermarkMargin)
+	}
+	if strings.HasPrefix(position, "bottom") {
+		y = fmt.Sprintf("%s-%s-%d", h, oh, watermarkMargin)
+	}
+	return x, y
+}
+
+// buildFilter monta o filtergraph do watermark. O texto é lido de um arquivo (textfile)
+// para evitar problemas de escape de aspas e dois-pontos no drawtext; o chamador remove o arquivo.
+// Um logo configurado mas ilegível é erro: a mídia não pode sair como marcada sem ele.
+func (wm *Watermark) buildFilter(textFile string) (filter string, extraInputs []string, err error) {
+	x, y := overlayPosition(wm.Position, "w", "h", "tw", "th")
+
+	opts := []string{
+		"textfile=" + escapeFilterValue(textFile),
+		"expansion=none",
+		fmt.Sprintf("x=%s", x),
+		fmt.Sprintf("y=%s", y),
+	}
+	if wm.FontFile != "" {
+		opts = append(opts, "fontfile="+escapeFilterValue(wm.FontFile))
+	}
+	if wm.FontSize > 0 {
+		opts = append(opts, fmt.Sprintf("fontsize=%d", wm.FontSize))
+	}
+	if wm.FontColor != "" {
+		opts = append(opts, "fontcolor="+escapeFilterValue(wm.FontColor))
+	}
+	if wm.BoxColor != "" {
+		opts = append(opts, "box=1", "boxborderw=6", "boxcolor="+escapeFilterValue(wm.BoxColor))
+	}
+	drawtext := "drawtext=" + strings.Join(opts, ":")
+
+	if wm.LogoPath == "" {
+		return "[0:v]" + drawtext + "[vout]", nil, nil
+	}
+	logo, err := os.Open(wm.LogoPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("watermark logo: %w", err)
+	}
+	logo.Close()
+
+	lx, ly := overlayPosition(wm.LogoPosition, "main_w", "main_h", "overlay_w", "overlay_h")
+	filter = fmt.Sprintf("[0:v]%s[txt];[txt][1:v]overlay=x=%s:y=%s[vout]", drawtext, lx, ly)
+	return filter, []string{"-i", wm.LogoPath}, nil
+}
+
+// escapeFilterValue escapa um valor para uso dentro de uma opção de filtro do ffmpeg.
+func escapeFilterValue(v string) string {
+	r := strings.NewReplacer(`\`, `\\`, `:`, `\:`, `'`, `\'`, `,`, `\,`, `[`, `\[`, `]`, `\]`, `;`, `\;`)
+	return r.Replace(v)
+}
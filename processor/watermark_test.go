@@ -0,0 +1,78 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildFilter(t *testing.T) {
+	dir := t.TempDir()
+	logo := filepath.Join(dir, "logo.png")
+	if err := os.WriteFile(logo, []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		wm         Watermark
+		textFile   string
+		want       string
+		wantInputs []string
+		wantErr    string
+	}{
+		{
+			name:     "text only, top-left",
+			wm:       Watermark{Position: "top-left"},
+			textFile: "/tmp/a.txt",
+			want:     "[0:v]drawtext=textfile=/tmp/a.txt:expansion=none:x=10:y=10[vout]",
+		},
+		{
+			name:     "bottom-right with font and box",
+			wm:       Watermark{Position: "bottom-right", FontFile: "/fonts/a.ttf", FontSize: 18, FontColor: "white", BoxColor: "black@0.5"},
+			textFile: "/tmp/a.txt",
+			want:     "[0:v]drawtext=textfile=/tmp/a.txt:expansion=none:x=w-tw-10:y=h-th-10:fontfile=/fonts/a.ttf:fontsize=18:fontcolor=white:box=1:boxborderw=6:boxcolor=black@0.5[vout]",
+		},
+		{
+			name:     "text file path is escaped",
+			wm:       Watermark{Position: "top-left"},
+			textFile: `C:\tmp\a:b.txt`,
+			want:     `[0:v]drawtext=textfile=C\:\\tmp\\a\:b.txt:expansion=none:x=10:y=10[vout]`,
+		},
+		{
+			name:       "logo overlay",
+			wm:         Watermark{Position: "top-left", LogoPath: logo, LogoPosition: "top-right"},
+			textFile:   "/tmp/a.txt",
+			want:       "[0:v]drawtext=textfile=/tmp/a.txt:expansion=none:x=10:y=10[txt];[txt][1:v]overlay=x=main_w-overlay_w-10:y=10[vout]",
+			wantInputs: []string{"-i", logo},
+		},
+		{
+			name:     "missing logo",
+			wm:       Watermark{Position: "top-left", LogoPath: filepath.Join(dir, "nope.png")},
+			textFile: "/tmp/a.txt",
+			wantErr:  "watermark logo",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, inputs, err := tt.wm.buildFilter(tt.textFile)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if filter != tt.want {
+				t.Fatalf("filter =\n%s\nwant\n%s", filter, tt.want)
+			}
+			if !reflect.DeepEqual(inputs, tt.wantInputs) {
+				t.Fatalf("extra inputs = %v, want %v", inputs, tt.wantInputs)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package queue
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Bus distribui as mensagens dentro do processo (ex: streaming para o dashboard). Assinantes lentos
+// não bloqueiam a publicação: mensagens que não cabem no buffer do assinante são descartadas.
+type Bus struct {
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// Subscription recebe as mensagens aceitas pelo filtro em C.
+type Subscription struct {
+	C       <-chan Message
+	ch      chan Message
+	filter  func(Message) bool
+	dropped int64
+}
+
+// Dropped retorna quantas mensagens foram descartadas por buffer cheio.
+func (s *Subscription) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+func (b *Bus) Name() string {
+	return "bus"
+}
+
+// Publish entrega a mensagem aos assinantes sem bloquear.
+func (b *Bus) Publish(msg Message) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for s := range b.subs {
+		if s.filter != nil && !s.filter(msg) {
+			continue
+		}
+		select {
+		case s.ch <- msg:
+		default:
+			atomic.AddInt64(&s.dropped, 1)
+		}
+	}
+	return nil
+}
+
+// Subscribe registra um assinante. filter nil recebe tudo.
+func (b *Bus) Subscribe(buffer int, filter func(Message) bool) *Subscription {
+	if buffer <= 0 {
+		buffer = 1
+	}
+	ch := make(chan Message, buffer)
+	s := &Subscription{C: ch, ch: ch, filter: filter}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return s
+	}
+	b.subs[s] = struct{}{}
+	return s
+}
+
+// Unsubscribe remove o assinante e fecha seu canal.
+func (b *Bus) Unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[s]; ok {
+		delete(b.subs, s)
+		close(s.ch)
+	}
+}
+
+// Subscribers retorna o número de assinantes ativos.
+func (b *Bus) Subscribers() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs)
+}
+
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs {
+		close(s.ch)
+	}
+	b.subs = make(map[*Subscription]struct{})
+	b.closed = true
+}
@@ -0,0 +1,76 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Modos de emissão CloudEvents 1.0. Sem modo os eventos saem como JSON puro.
+const (
+	CloudEventsBinary     = "binary"     // atributos nos headers/propriedades, corpo é o payload
+	CloudEventsStructured = "structured" // envelope JSON completo no corpo
+)
+
+const (
+	contentTypeJSON        = "application/json"
+	contentTypeCloudEvents = "application/cloudevents+json"
+)
+
+// CloudEvents define como os eventos são convertidos para CloudEvents 1.0.
+type CloudEvents struct {
+	Mode       string
+	Source     string // identifica a instância do serviço
+	TypePrefix string // ex: com.jimi.dvr -> com.jimi.dvr.media.uploaded
+}
+
+// Attributes retorna os atributos de contexto do evento (sem datacontenttype, que vai como content type).
+func (c *CloudEvents) Attributes(msg Message) map[string]string {
+	attrs := map[string]string{
+		"specversion": "1.0",
+		"id":          msg.ID,
+		"source":      c.Source,
+		"type":        c.TypePrefix + "." + msg.Kind,
+		"time":        msg.Time.UTC().Format(time.RFC3339Nano),
+	}
+	if msg.IMEI != "" {
+		attrs["subject"] = msg.IMEI
+	}
+	return attrs
+}
+
+// encodedMessage é o corpo pronto para envio e os atributos CloudEvents a mapear nos headers
+// do protocolo (apenas no modo binário).
+type encodedMessage struct {
+	Body        []byte
+	ContentType string
+	Attributes  map[string]string
+}
+
+// encodeMessage serializa a mensagem conforme o modo CloudEvents (ce pode ser nil: JSON puro).
+func encodeMessage(ce *CloudEvents, msg Message) (encodedMessage, error) {
+	data, err := json.Marshal(msg.Payload)
+	if err != nil {
+		return encodedMessage{}, fmt.Errorf("failed to marshal event: %w", err)
+	}
+	if ce == nil || ce.Mode == "" {
+		return encodedMessage{Body: data, ContentType: contentTypeJSON}, nil
+	}
+
+	attrs := ce.Attributes(msg)
+	if ce.Mode == CloudEventsBinary {
+		return encodedMessage{Body: data, ContentType: contentTypeJSON, Attributes: attrs}, nil
+	}
+
+	envelope := make(map[string]interface{}, len(attrs)+2)
+	for k, v := range attrs {
+		envelope[k] = v
+	}
+	envelope["datacontenttype"] = contentTypeJSON
+	envelope["data"] = json.RawMessage(data)
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return encodedMessage{}, fmt.Errorf("failed to marshal cloudevent: %w", err)
+	}
+	return encodedMessage{Body: body, ContentType: contentTypeCloudEvents}, nil
+}
@@ -0,0 +1,122 @@
+package queue
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEncodeMessage(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 8, 30, 15, 500, time.FixedZone("BRT", -3*3600))
+	msg := Message{
+		Kind:    KindMediaUploaded,
+		ID:      "req-1",
+		IMEI:    "123456789012",
+		Time:    ts,
+		Payload: map[string]any{"filename": "a.mp4", "size": 10},
+	}
+	noIMEI := msg
+	noIMEI.IMEI = ""
+
+	ce := func(mode string) *CloudEvents {
+		return &CloudEvents{Mode: mode, Source: "/dvr-upload/node-1", TypePrefix: "com.jimi.dvr"}
+	}
+	attrs := map[string]string{
+		"specversion": "1.0",
+		"id":          "req-1",
+		"source":      "/dvr-upload/node-1",
+		"type":        "com.jimi.dvr." + KindMediaUploaded,
+		"time":        "2024-05-01T11:30:15.0000005Z",
+		"subject":     "123456789012",
+	}
+	payload := map[string]any{"filename": "a.mp4", "size": float64(10)}
+
+	tests := []struct {
+		name            string
+		ce              *CloudEvents
+		msg             Message
+		wantContentType string
+		wantAttributes  map[string]string
+		wantBody        map[string]any
+	}{
+		{
+			name:            "plain JSON without CloudEvents",
+			msg:             msg,
+			wantContentType: contentTypeJSON,
+			wantBody:        payload,
+		},
+		{
+			name:            "empty mode is plain JSON",
+			ce:              ce(""),
+			msg:             msg,
+			wantContentType: contentTypeJSON,
+			wantBody:        payload,
+		},
+		{
+			name:            "binary mode keeps the payload as body",
+			ce:              ce(CloudEventsBinary),
+			msg:             msg,
+			wantContentType: contentTypeJSON,
+			wantAttributes:  attrs,
+			wantBody:        payload,
+		},
+		{
+			name:            "structured mode wraps the payload",
+			ce:              ce(CloudEventsStructured),
+			msg:             msg,
+			wantContentType: contentTypeCloudEvents,
+			wantBody: map[string]any{
+				"specversion":     "1.0",
+				"id":              "req-1",
+				"source":          "/dvr-upload/node-1",
+				"type":            "com.jimi.dvr." + KindMediaUploaded,
+				"time":            "2024-05-01T11:30:15.0000005Z",
+				"subject":         "123456789012",
+				"datacontenttype": contentTypeJSON,
+				"data":            payload,
+			},
+		},
+		{
+			name:            "no subject without IMEI",
+			ce:              ce(CloudEventsBinary),
+			msg:             noIMEI,
+			wantContentType: contentTypeJSON,
+			wantAttributes: map[string]string{
+				"specversion": "1.0",
+				"id":          "req-1",
+				"source":      "/dvr-upload/node-1",
+				"type":        "com.jimi.dvr." + KindMediaUploaded,
+				"time":        "2024-05-01T11:30:15.0000005Z",
+			},
+			wantBody: payload,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := encodeMessage(tt.ce, tt.msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ContentType != tt.wantContentType {
+				t.Fatalf("content type = %q, want %q", got.ContentType, tt.wantContentType)
+			}
+			if !reflect.DeepEqual(got.Attributes, tt.wantAttributes) {
+				t.Fatalf("attributes = %v, want %v", got.Attributes, tt.wantAttributes)
+			}
+			var body map[string]any
+			if err := json.Unmarshal(got.Body, &body); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(body, tt.wantBody) {
+				t.Fatalf("body = %v, want %v", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestEncodeMessageUnsupportedPayload(t *testing.T) {
+	if _, err := encodeMessage(nil, Message{Payload: make(chan int)}); err == nil {
+		t.Fatal("expected an error for a payload that cannot be marshalled")
+	}
+}
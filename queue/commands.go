@@ -0,0 +1,134 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Comandos aceitos na fila de comandos.
+const (
+	CommandReprocess            = "reprocess"
+	CommandRepublish            = "republish"
+	CommandDelete               = "delete"
+	CommandRegenerateThumbnails = "regenerate_thumbnails"
+)
+
+// Status de um CommandResult.
+const (
+	CommandStatusOK    = "ok"
+	CommandStatusError = "error"
+)
+
+// Command é uma mensagem recebida na fila de comandos. O correlation ID vem da propriedade AMQP
+// correlation_id ou, na falta dela, do campo do corpo.
+type Command struct {
+	Command       string    `json:"command"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	Key           string    `json:"key,omitempty"`     // reprocess, republish, regenerate_thumbnails
+	Profile       string    `json:"profile,omitempty"` // reprocess
+	IMEI          string    `json:"imei,omitempty"`    // delete
+	From          time.Time `json:"from,omitempty"`    // delete
+	To            time.Time `json:"to,omitempty"`      // delete
+	DryRun        bool      `json:"dry_run,omitempty"` // delete
+}
+
+// CommandResult é publicado na fila de resultados (ou no reply_to do comando) com o mesmo correlation ID.
+type CommandResult struct {
+	CorrelationID string      `json:"correlation_id,omitempty"`
+	Command       string      `json:"command"`
+	Status        string      `json:"status"`
+	Error         string      `json:"error,omitempty"`
+	Data          interface{} `json:"data,omitempty"`
+	FinishedAt    time.Time   `json:"finished_at"`
+}
+
+// CommandHandler executa um comando e devolve o resultado.
+type CommandHandler func(cmd Command) CommandResult
+
+// ConsumeCommands declara as filas de comandos e de resultados e consome os comandos com workers
+// concorrentes. Cada comando recebe ack depois que o resultado é publicado; mensagens inválidas
+// também geram um resultado de erro e não voltam para a fila.
+func (c *RabbitMQClient) ConsumeCommands(commandQueue, resultQueue string, workers int, handler CommandHandler) error {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	ch, err := c.conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open command channel: %w", err)
+	}
+	for _, name := range []string{commandQueue, resultQueue} {
+		if _, err := ch.QueueDeclare(name, true, false, false, false, nil); err != nil {
+			ch.Close()
+			return fmt.Errorf("failed to declare queue %q: %w", name, err)
+		}
+	}
+	if err := ch.Qos(workers, 0, false); err != nil {
+		ch.Close()
+		return fmt.Errorf("failed to set command prefetch: %w", err)
+	}
+
+	deliveries, err := ch.Consume(commandQueue, "dvr-upload-commands", false, false, false, false, nil)
+	if err != nil {
+		ch.Close()
+		return fmt.Errorf("failed to consume command queue: %w", err)
+	}
+	c.commandChannel = ch
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for d := range deliveries {
+				c.handleCommand(ch, d, resultQueue, handler)
+			}
+		}()
+	}
+	return nil
+}
+
+func (c *RabbitMQClient) handleCommand(ch *amqp.Channel, d amqp.Delivery, resultQueue string, handler CommandHandler) {
+	var cmd Command
+	var result CommandResult
+	if err := json.Unmarshal(d.Body, &cmd); err != nil {
+		result = CommandResult{Status: CommandStatusError, Error: "invalid command payload: " + err.Error()}
+	} else {
+		if d.CorrelationId != "" {
+			cmd.CorrelationID = d.CorrelationId
+		}
+		logger := c.logger.With("command", cmd.Command, "correlation_id", cmd.CorrelationID)
+		logger.Info("Command received")
+		result = handler(cmd)
+		logger.Info("Command finished", "status", result.Status, "error", result.Error)
+	}
+	result.CorrelationID = cmd.CorrelationID
+	if result.CorrelationID == "" {
+		result.CorrelationID = d.CorrelationId
+	}
+	result.Command = cmd.Command
+	result.FinishedAt = time.Now().UTC()
+
+	// Resultado vai para o reply_to do comando (padrão RPC do RabbitMQ) ou para a fila de resultados
+	replyTo := resultQueue
+	if d.ReplyTo != "" {
+		replyTo = d.ReplyTo
+	}
+	body, err := json.Marshal(result)
+	if err == nil {
+		err = ch.Publish("", replyTo, false, false, amqp.Publishing{
+			ContentType:   "application/json",
+			CorrelationId: result.CorrelationID,
+			DeliveryMode:  amqp.Persistent,
+			Timestamp:     time.Now(),
+			Body:          body,
+		})
+	}
+	if err != nil {
+		c.logger.Error("Failed to publish command result", "error", err, "correlation_id", result.CorrelationID)
+		// Sem resultado publicado o comando volta para a fila
+		d.Nack(false, true)
+		return
+	}
+	d.Ack(false)
+}
@@ -0,0 +1,142 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Tipos de fila e políticas de overflow aceitos pelo RabbitMQ.
+const (
+	QueueTypeClassic = "classic"
+	QueueTypeQuorum  = "quorum"
+
+	OverflowDropHead         = "drop-head"
+	OverflowRejectPublish    = "reject-publish"
+	OverflowRejectPublishDLX = "reject-publish-dlx"
+)
+
+// QueueOptions define os argumentos de declaração das filas de eventos.
+type QueueOptions struct {
+	TTL                  int    // x-message-ttl em ms
+	Type                 string // classic (padrão) ou quorum
+	DeadLetterExchange   string // exchange que recebe mensagens expiradas, rejeitadas ou descartadas
+	DeadLetterRoutingKey string // routing key usada no dead-letter (vazio mantém a original)
+	DeadLetterQueue      string // fila ligada ao dead-letter exchange (opcional)
+	MaxLength            int    // limite de mensagens na fila (0 desativa)
+	Overflow             string // política ao atingir MaxLength
+	Strict               bool   // divergência com uma fila existente impede a inicialização
+}
+
+// Validate verifica combinações que o RabbitMQ recusaria na declaração.
+func (o QueueOptions) Validate() error {
+	switch o.Type {
+	case "", QueueTypeClassic, QueueTypeQuorum:
+	default:
+		return fmt.Errorf("invalid queue type %q (expected classic or quorum)", o.Type)
+	}
+	switch o.Overflow {
+	case "", OverflowDropHead, OverflowRejectPublish:
+	case OverflowRejectPublishDLX:
+		if o.Type == QueueTypeQuorum {
+			return fmt.Errorf("overflow %q is not supported by quorum queues", o.Overflow)
+		}
+		if o.DeadLetterExchange == "" {
+			return fmt.Errorf("overflow %q requires a dead-letter exchange", o.Overflow)
+		}
+	default:
+		return fmt.Errorf("invalid overflow policy %q (expected drop-head, reject-publish or reject-publish-dlx)", o.Overflow)
+	}
+	if o.MaxLength < 0 {
+		return fmt.Errorf("invalid max length %d", o.MaxLength)
+	}
+	if o.DeadLetterQueue != "" && o.DeadLetterExchange == "" {
+		return fmt.Errorf("dead-letter queue %q requires a dead-letter exchange", o.DeadLetterQueue)
+	}
+	return nil
+}
+
+// arguments monta a tabela x-* da declaração das filas de eventos.
+func (o QueueOptions) arguments() amqp.Table {
+	args := amqp.Table{
+		"x-message-ttl": int32(o.TTL),
+	}
+	if o.Type == QueueTypeQuorum {
+		args["x-queue-type"] = QueueTypeQuorum
+	}
+	if o.DeadLetterExchange != "" {
+		args["x-dead-letter-exchange"] = o.DeadLetterExchange
+		if o.DeadLetterRoutingKey != "" {
+			args["x-dead-letter-routing-key"] = o.DeadLetterRoutingKey
+		}
+	}
+	if o.MaxLength > 0 {
+		args["x-max-length"] = int32(o.MaxLength)
+		if o.Overflow != "" {
+			args["x-overflow"] = o.Overflow
+		}
+	}
+	return args
+}
+
+// DeclarationMismatchError indica que a fila (ou exchange) já existe no broker com argumentos diferentes
+// dos configurados. O RabbitMQ responde 406 PRECONDITION_FAILED e fecha o canal.
+type DeclarationMismatchError struct {
+	Kind      string // queue ou exchange
+	Name      string
+	Argument  string // argumento divergente, quando informado pelo broker
+	Requested amqp.Table
+	Reason    string // mensagem original do broker
+}
+
+var inequivalentArg = regexp.MustCompile(`inequivalent arg '([^']+)'`)
+
+func (e *DeclarationMismatchError) Error() string {
+	what := "different arguments"
+	if e.Argument != "" {
+		what = fmt.Sprintf("a different %q", e.Argument)
+	}
+	return fmt.Sprintf("%s %q already exists with %s (broker: %s); delete it or align the RABBITMQ_* settings", e.Kind, e.Name, what, e.Reason)
+}
+
+// newMismatchError extrai do motivo informado pelo broker o argumento divergente.
+func newMismatchError(kind, name string, args amqp.Table, reason string) *DeclarationMismatchError {
+	mismatch := &DeclarationMismatchError{Kind: kind, Name: name, Requested: args, Reason: reason}
+	if m := inequivalentArg.FindStringSubmatch(reason); m != nil {
+		mismatch.Argument = m[1]
+	}
+	return mismatch
+}
+
+// redeclare trata a falha de uma declaração: divergências viram DeclarationMismatchError e, como o broker
+// fecha o canal nesse caso, um novo canal é aberto para que a inicialização possa continuar.
+func redeclare(conn *amqp.Connection, ch *amqp.Channel, kind, name string, args amqp.Table, err error) (*amqp.Channel, error) {
+	var amqpErr *amqp.Error
+	if !errors.As(err, &amqpErr) || amqpErr.Code != amqp.PreconditionFailed {
+		return ch, fmt.Errorf("failed to declare %s %q: %w", kind, name, err)
+	}
+	mismatch := newMismatchError(kind, name, args, amqpErr.Reason)
+	newCh, chErr := conn.Channel()
+	if chErr != nil {
+		return nil, fmt.Errorf("%w (failed to reopen channel: %v)", mismatch, chErr)
+	}
+	return newCh, mismatch
+}
+
+// declareQueue declara uma fila durável com os argumentos informados.
+func declareQueue(conn *amqp.Connection, ch *amqp.Channel, name string, args amqp.Table) (*amqp.Channel, error) {
+	if _, err := ch.QueueDeclare(name, true, false, false, false, args); err != nil {
+		return redeclare(conn, ch, "queue", name, args, err)
+	}
+	return ch, nil
+}
+
+// declareExchange declara um exchange direct durável (usado como dead-letter exchange).
+func declareExchange(conn *amqp.Connection, ch *amqp.Channel, name string) (*amqp.Channel, error) {
+	if err := ch.ExchangeDeclare(name, amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+		return redeclare(conn, ch, "exchange", name, nil, err)
+	}
+	return ch, nil
+}
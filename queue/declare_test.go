@@ -0,0 +1,110 @@
+package queue
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestQueueOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    QueueOptions
+		wantErr string
+	}{
+		{name: "defaults", opts: QueueOptions{TTL: 300000}},
+		{name: "quorum with dead letter", opts: QueueOptions{Type: QueueTypeQuorum, DeadLetterExchange: "dlx", DeadLetterQueue: "dead", MaxLength: 10, Overflow: OverflowRejectPublish}},
+		{name: "classic reject-publish-dlx", opts: QueueOptions{Type: QueueTypeClassic, DeadLetterExchange: "dlx", MaxLength: 10, Overflow: OverflowRejectPublishDLX}},
+		{name: "unknown type", opts: QueueOptions{Type: "stream"}, wantErr: `invalid queue type "stream"`},
+		{name: "unknown overflow", opts: QueueOptions{Overflow: "drop-tail"}, wantErr: `invalid overflow policy "drop-tail"`},
+		{name: "quorum reject-publish-dlx", opts: QueueOptions{Type: QueueTypeQuorum, DeadLetterExchange: "dlx", Overflow: OverflowRejectPublishDLX}, wantErr: "not supported by quorum queues"},
+		{name: "reject-publish-dlx without exchange", opts: QueueOptions{Overflow: OverflowRejectPublishDLX}, wantErr: "requires a dead-letter exchange"},
+		{name: "negative max length", opts: QueueOptions{MaxLength: -1}, wantErr: "invalid max length -1"},
+		{name: "dead-letter queue without exchange", opts: QueueOptions{DeadLetterQueue: "dead"}, wantErr: `dead-letter queue "dead" requires a dead-letter exchange`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestQueueOptionsArguments(t *testing.T) {
+	tests := []struct {
+		name string
+		opts QueueOptions
+		want amqp.Table
+	}{
+		{
+			name: "TTL only",
+			opts: QueueOptions{TTL: 300000},
+			want: amqp.Table{"x-message-ttl": int32(300000)},
+		},
+		{
+			name: "quorum with dead letter and max length",
+			opts: QueueOptions{TTL: 1000, Type: QueueTypeQuorum, DeadLetterExchange: "dlx", DeadLetterRoutingKey: "dead", MaxLength: 50, Overflow: OverflowRejectPublish},
+			want: amqp.Table{
+				"x-message-ttl":             int32(1000),
+				"x-queue-type":              QueueTypeQuorum,
+				"x-dead-letter-exchange":    "dlx",
+				"x-dead-letter-routing-key": "dead",
+				"x-max-length":              int32(50),
+				"x-overflow":                OverflowRejectPublish,
+			},
+		},
+		{
+			// Classic é o padrão do broker e não é declarado
+			name: "classic keeps the broker default",
+			opts: QueueOptions{TTL: 1000, Type: QueueTypeClassic},
+			want: amqp.Table{"x-message-ttl": int32(1000)},
+		},
+		{
+			name: "routing key and overflow need their base argument",
+			opts: QueueOptions{TTL: 1000, DeadLetterRoutingKey: "dead", Overflow: OverflowDropHead},
+			want: amqp.Table{"x-message-ttl": int32(1000)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.arguments(); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("arguments() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeclarationMismatchError(t *testing.T) {
+	args := amqp.Table{"x-message-ttl": int32(1000)}
+	reason := "PRECONDITION_FAILED - inequivalent arg 'x-message-ttl' for queue 'dvr_upload' in vhost '/': received the value '1000' of type 'signedint' but current is none"
+	err := newMismatchError("queue", "dvr_upload", args, reason)
+	if err.Argument != "x-message-ttl" || !reflect.DeepEqual(err.Requested, args) {
+		t.Fatalf("mismatch = %+v", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, `queue "dvr_upload" already exists with a different "x-message-ttl"`) || !strings.Contains(msg, "align the RABBITMQ_* settings") {
+		t.Fatalf("message = %q", msg)
+	}
+
+	if err := newMismatchError("exchange", "dlx", nil, "PRECONDITION_FAILED - inequivalent durable"); err.Argument != "" || !strings.Contains(err.Error(), "different arguments") {
+		t.Fatalf("mismatch without argument = %v", err)
+	}
+
+	// Outros erros do broker não são divergência e mantêm o canal
+	ch := &amqp.Channel{}
+	got, err2 := redeclare(nil, ch, "queue", "dvr_upload", args, &amqp.Error{Code: amqp.AccessRefused, Reason: "ACCESS_REFUSED"})
+	var mismatch *DeclarationMismatchError
+	if got != ch || errors.As(err2, &mismatch) || !strings.Contains(err2.Error(), `failed to declare queue "dvr_upload"`) {
+		t.Fatalf("redeclare = %v, %v", got, err2)
+	}
+}
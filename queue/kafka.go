@@ -0,0 +1,95 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaOptions configura o sink Kafka.
+type KafkaOptions struct {
+	Brokers       []string
+	TopicTemplate string
+	Timeout       time.Duration
+	CloudEvents   *CloudEvents
+}
+
+// KafkaSink publica os eventos no Kafka com a chave igual ao IMEI (mesma partição, ordem por
+// dispositivo) e aguarda a confirmação de todas as réplicas.
+type KafkaSink struct {
+	writer *kafka.Writer
+	opts   KafkaOptions
+	logger *slog.Logger
+}
+
+func NewKafkaSink(opts KafkaOptions, logger *slog.Logger) (*KafkaSink, error) {
+	if len(opts.Brokers) == 0 {
+		return nil, fmt.Errorf("no Kafka brokers configured")
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	writer := &kafka.Writer{
+		Addr:                   kafka.TCP(opts.Brokers...),
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireAll,
+		AllowAutoTopicCreation: true,
+		BatchTimeout:           10 * time.Millisecond,
+		WriteTimeout:           opts.Timeout,
+	}
+	return &KafkaSink{writer: writer, opts: opts, logger: logger}, nil
+}
+
+func (s *KafkaSink) Name() string {
+	return "kafka"
+}
+
+func (s *KafkaSink) Publish(msg Message) error {
+	m, err := s.message(msg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.Timeout)
+	defer cancel()
+	if err := s.writer.WriteMessages(ctx, m); err != nil {
+		return fmt.Errorf("failed to publish to Kafka topic %q: %w", m.Topic, err)
+	}
+	return nil
+}
+
+// message monta a mensagem Kafka do evento: tópico do template, chave = IMEI e headers com o tipo
+// do evento, os atributos CloudEvents (ce_*) e o contexto de trace.
+func (s *KafkaSink) message(msg Message) (kafka.Message, error) {
+	encoded, err := encodeMessage(s.opts.CloudEvents, msg)
+	if err != nil {
+		return kafka.Message{}, err
+	}
+	headers := []kafka.Header{
+		{Key: "content-type", Value: []byte(encoded.ContentType)},
+		{Key: "dvr-event", Value: []byte(msg.Kind)},
+		{Key: "dvr-event-id", Value: []byte(msg.ID)},
+	}
+	for k, v := range encoded.Attributes {
+		headers = append(headers, kafka.Header{Key: "ce_" + k, Value: []byte(v)})
+	}
+	for k, v := range msg.Trace {
+		headers = append(headers, kafka.Header{Key: k, Value: []byte(v)})
+	}
+
+	return kafka.Message{
+		Topic:   RenderTopic(s.opts.TopicTemplate, msg),
+		Key:     []byte(msg.IMEI),
+		Value:   encoded.Body,
+		Time:    msg.Time,
+		Headers: headers,
+	}, nil
+}
+
+func (s *KafkaSink) Close() {
+	if err := s.writer.Close(); err != nil {
+		s.logger.Warn("Failed to close Kafka writer", "error", err)
+	}
+}
@@ -0,0 +1,105 @@
+package queue
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestKafkaMessage(t *testing.T) {
+	tests := []struct {
+		name        string
+		ce          *CloudEvents
+		wantHeaders map[string]string
+	}{
+		{
+			name: "plain JSON",
+			wantHeaders: map[string]string{
+				"content-type": contentTypeJSON,
+				"dvr-event":    KindMediaUploaded,
+				"dvr-event-id": "req-1",
+				"traceparent":  testMessage().Trace["traceparent"],
+			},
+		},
+		{
+			name: "CloudEvents binary mode",
+			ce:   &CloudEvents{Mode: CloudEventsBinary, Source: "/dvr-upload/test", TypePrefix: "com.jimi.dvr"},
+			wantHeaders: map[string]string{
+				"content-type":   contentTypeJSON,
+				"dvr-event":      KindMediaUploaded,
+				"dvr-event-id":   "req-1",
+				"ce_specversion": "1.0",
+				"ce_type":        "com.jimi.dvr." + KindMediaUploaded,
+				"ce_source":      "/dvr-upload/test",
+				"ce_subject":     "123456789012",
+				"traceparent":    testMessage().Trace["traceparent"],
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink, err := NewKafkaSink(KafkaOptions{Brokers: []string{"localhost:9092"}, TopicTemplate: "dvr.{kind}.{type}", CloudEvents: tt.ce}, discardLogger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sink.Close()
+
+			msg := testMessage()
+			m, err := sink.message(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Topic != "dvr.media.uploaded.I" || string(m.Key) != msg.IMEI || !m.Time.Equal(msg.Time) {
+				t.Fatalf("topic %q, key %q, time %v", m.Topic, m.Key, m.Time)
+			}
+			if string(m.Value) != `{"filename":"a.mp4"}` {
+				t.Fatalf("value = %s", m.Value)
+			}
+			headers := map[string]string{}
+			for _, h := range m.Headers {
+				headers[h.Key] = string(h.Value)
+			}
+			for k, want := range tt.wantHeaders {
+				if headers[k] != want {
+					t.Fatalf("header %s = %q, want %q (headers %v)", k, headers[k], want, headers)
+				}
+			}
+		})
+	}
+}
+
+func TestKafkaSinkOptions(t *testing.T) {
+	if _, err := NewKafkaSink(KafkaOptions{}, discardLogger); err == nil {
+		t.Fatal("NewKafkaSink succeeded without brokers")
+	}
+
+	sink, err := NewKafkaSink(KafkaOptions{Brokers: []string{"a:9092", "b:9092"}}, discardLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	// Chave = IMEI com balanceamento por hash mantém a ordem por dispositivo; acks de todas as réplicas
+	if _, ok := sink.writer.Balancer.(*kafka.Hash); !ok {
+		t.Fatalf("balancer = %T, want *kafka.Hash", sink.writer.Balancer)
+	}
+	if sink.writer.RequiredAcks != kafka.RequireAll {
+		t.Fatalf("required acks = %v, want RequireAll", sink.writer.RequiredAcks)
+	}
+	if sink.writer.Addr.String() != "a:9092,b:9092" {
+		t.Fatalf("brokers = %s", sink.writer.Addr)
+	}
+}
+
+func TestKafkaPublishWithoutBroker(t *testing.T) {
+	sink, err := NewKafkaSink(KafkaOptions{Brokers: []string{"127.0.0.1:1"}, TopicTemplate: "dvr.{kind}", Timeout: 200 * time.Millisecond}, discardLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	err = sink.Publish(testMessage())
+	if err == nil || !strings.Contains(err.Error(), `Kafka topic "dvr.media.uploaded"`) {
+		t.Fatalf("error = %v", err)
+	}
+}
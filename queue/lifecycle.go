@@ -0,0 +1,72 @@
+package queue
+
+import "time"
+
+// Eventos de ciclo de vida de um upload (além do media.uploaded de sucesso).
+const (
+	KindMediaReceived    = "media.received"
+	KindMediaRejected    = "media.rejected"
+	KindProcessingFailed = "media.processing_failed"
+	KindMediaPublished   = "media.published"
+)
+
+// Eventos de progresso publicados apenas no barramento interno (acompanhamento ao vivo).
+const (
+	KindSignatureVerified = "media.signature_verified"
+	KindMediaConverted    = "media.converted"
+)
+
+// Estágios do processamento informados em media.processing_failed.
+const (
+	StageConvert   = "convert"
+	StageCompress  = "compress"
+	StageSubtitle  = "subtitle"
+	StageImage     = "image"
+	StageS3Upload  = "s3_upload"
+	StageHLS       = "hls"
+	StageLocalMove = "local_store"
+	StagePublish   = "publish"
+	StagePanic     = "panic"
+)
+
+// LifecycleEvent é o payload dos eventos de ciclo de vida.
+type LifecycleEvent struct {
+	Event     string    `json:"event"`
+	RequestID string    `json:"request_id"`
+	IMEI      string    `json:"imei,omitempty"`
+	Type      string    `json:"type,omitempty"`
+	Channel   string    `json:"channel,omitempty"`
+	Filename  string    `json:"filename,omitempty"`
+	Size      int64     `json:"size,omitempty"`
+	Reason    string    `json:"reason,omitempty"` // media.rejected
+	Stage     string    `json:"stage,omitempty"`  // media.processing_failed
+	Fatal     bool      `json:"fatal,omitempty"`  // a falha interrompeu o processamento
+	Error     string    `json:"error,omitempty"`
+	Sinks     []string  `json:"sinks,omitempty"` // media.published
+	Time      time.Time `json:"time"`
+}
+
+// NewLifecycleMessage monta o envelope de um evento de ciclo de vida.
+func NewLifecycleMessage(kind string, event LifecycleEvent) Message {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	event.Event = kind
+	return Message{
+		Kind:    kind,
+		ID:      event.RequestID,
+		IMEI:    event.IMEI,
+		Type:    event.Type,
+		Time:    event.Time,
+		Payload: event,
+	}
+}
+
+// IsLifecycle indica se o tipo de evento é de ciclo de vida.
+func IsLifecycle(kind string) bool {
+	switch kind {
+	case KindMediaReceived, KindMediaRejected, KindProcessingFailed, KindMediaPublished:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package queue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewLifecycleMessage(t *testing.T) {
+	at := time.Date(2024, 5, 1, 8, 30, 15, 0, time.UTC)
+	msg := NewLifecycleMessage(KindMediaReceived, LifecycleEvent{RequestID: "req-1", IMEI: "123456789012", Type: "I", Time: at})
+	event, ok := msg.Payload.(LifecycleEvent)
+	if !ok || event.Event != KindMediaReceived {
+		t.Fatalf("payload = %+v", msg.Payload)
+	}
+	if msg.Kind != KindMediaReceived || msg.ID != "req-1" || msg.IMEI != "123456789012" || msg.Type != "I" || !msg.Time.Equal(at) {
+		t.Fatalf("message = %+v", msg)
+	}
+	if msg := NewLifecycleMessage(KindMediaRejected, LifecycleEvent{}); msg.Time.IsZero() {
+		t.Fatal("event without time")
+	}
+
+	for kind, want := range map[string]bool{
+		KindMediaReceived:     true,
+		KindMediaRejected:     true,
+		KindProcessingFailed:  true,
+		KindMediaPublished:    true,
+		KindMediaUploaded:     false,
+		KindMediaBundle:       false,
+		KindSignatureVerified: false,
+	} {
+		if got := IsLifecycle(kind); got != want {
+			t.Errorf("IsLifecycle(%q) = %v, want %v", kind, got, want)
+		}
+	}
+}
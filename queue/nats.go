@@ -0,0 +1,117 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// NATSOptions configura o sink NATS JetStream.
+type NATSOptions struct {
+	URL             string
+	CredsFile       string
+	SubjectTemplate string
+	Stream          string   // criado/atualizado na conexão quando informado
+	StreamSubjects  []string // subjects capturados pelo stream
+	Timeout         time.Duration
+	CloudEvents     *CloudEvents
+}
+
+// NATSSink publica os eventos no JetStream aguardando o ack do servidor (entrega persistida,
+// como o publish persistente do RabbitMQ). O ID do evento vai como Nats-Msg-Id para deduplicação.
+type NATSSink struct {
+	conn   *nats.Conn
+	js     jetstream.JetStream
+	opts   NATSOptions
+	logger *slog.Logger
+}
+
+func NewNATSSink(opts NATSOptions, logger *slog.Logger) (*NATSSink, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	connOpts := []nats.Option{
+		nats.Name("dvr-upload"),
+		nats.MaxReconnects(-1),
+	}
+	if opts.CredsFile != "" {
+		connOpts = append(connOpts, nats.UserCredentials(opts.CredsFile))
+	}
+
+	conn, err := nats.Connect(opts.URL, connOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create JetStream context: %w", err)
+	}
+
+	if opts.Stream != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+		defer cancel()
+		_, err := js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+			Name:     opts.Stream,
+			Subjects: opts.StreamSubjects,
+			Storage:  jetstream.FileStorage,
+		})
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to declare JetStream stream %q: %w", opts.Stream, err)
+		}
+	}
+
+	return &NATSSink{conn: conn, js: js, opts: opts, logger: logger}, nil
+}
+
+func (s *NATSSink) Name() string {
+	return "nats"
+}
+
+func (s *NATSSink) Publish(msg Message) error {
+	encoded, err := encodeMessage(s.opts.CloudEvents, msg)
+	if err != nil {
+		return err
+	}
+
+	m := nats.NewMsg(RenderTopic(s.opts.SubjectTemplate, msg))
+	m.Data = encoded.Body
+	m.Header.Set("Content-Type", encoded.ContentType)
+	for k, v := range encoded.Attributes {
+		m.Header.Set("ce-"+k, v)
+	}
+	for k, v := range msg.Trace {
+		m.Header.Set(k, v)
+	}
+	m.Header.Set("DVR-Event", msg.Kind)
+	m.Header.Set("DVR-IMEI", msg.IMEI)
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.Timeout)
+	defer cancel()
+	var pubOpts []jetstream.PublishOpt
+	if msg.ID != "" {
+		pubOpts = append(pubOpts, jetstream.WithMsgID(msg.Kind+":"+msg.ID))
+	}
+	if _, err := s.js.PublishMsg(ctx, m, pubOpts...); err != nil {
+		return fmt.Errorf("failed to publish to JetStream subject %q: %w", m.Subject, err)
+	}
+	return nil
+}
+
+func (s *NATSSink) Ping() error {
+	if s.conn == nil || !s.conn.IsConnected() {
+		return fmt.Errorf("NATS connection %s", s.conn.Status())
+	}
+	return nil
+}
+
+func (s *NATSSink) Close() {
+	if s.conn != nil {
+		s.conn.Drain()
+	}
+}
@@ -0,0 +1,136 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// runNATSServer sobe um servidor NATS com JetStream em memória de teste, numa porta livre.
+func runNATSServer(t *testing.T) *server.Server {
+	t.Helper()
+	s, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, JetStream: true, StoreDir: t.TempDir(), NoLog: true, NoSigs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	if !s.ReadyForConnections(10 * time.Second) {
+		t.Fatal("NATS server not ready")
+	}
+	t.Cleanup(s.Shutdown)
+	return s
+}
+
+func TestNATSSink(t *testing.T) {
+	srv := runNATSServer(t)
+	sink, err := NewNATSSink(NATSOptions{
+		URL:             srv.ClientURL(),
+		SubjectTemplate: "dvr.{kind}.{type}.{imei}",
+		Stream:          "DVR_EVENTS",
+		StreamSubjects:  []string{"dvr.>"},
+		CloudEvents:     &CloudEvents{Mode: CloudEventsBinary, Source: "/dvr-upload/test", TypePrefix: "com.jimi.dvr"},
+	}, discardLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	if err := sink.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := testMessage()
+	msg.IMEI = "12345.6789*"
+	// O mesmo evento publicado duas vezes (retentativa) é deduplicado pelo Nats-Msg-Id
+	for i := 0; i < 2; i++ {
+		if err := sink.Publish(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conn, err := nats.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	js, err := jetstream.New(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stream, err := js.Stream(ctx, "DVR_EVENTS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := stream.Info(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State.Msgs != 1 {
+		t.Fatalf("stream has %d messages, want 1", info.State.Msgs)
+	}
+
+	stored, err := stream.GetMsg(ctx, info.State.FirstSeq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "dvr.media.uploaded.I.12345_6789_"; stored.Subject != want {
+		t.Fatalf("subject = %q, want %q", stored.Subject, want)
+	}
+	if string(stored.Data) != `{"filename":"a.mp4"}` {
+		t.Fatalf("data = %s", stored.Data)
+	}
+	headers := map[string]string{
+		"Content-Type": contentTypeJSON,
+		"ce-type":      "com.jimi.dvr." + KindMediaUploaded,
+		"ce-id":        "req-1",
+		"DVR-Event":    KindMediaUploaded,
+		"DVR-IMEI":     msg.IMEI,
+		"traceparent":  msg.Trace["traceparent"],
+		"Nats-Msg-Id":  KindMediaUploaded + ":req-1",
+	}
+	for k, want := range headers {
+		if got := stored.Header.Get(k); got != want {
+			t.Fatalf("header %s = %q, want %q", k, got, want)
+		}
+	}
+
+	srv.Shutdown()
+	srv.WaitForShutdown()
+	deadline := time.Now().Add(5 * time.Second)
+	for sink.Ping() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Ping still succeeds with the server down")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNATSSinkWithoutServer(t *testing.T) {
+	if _, err := NewNATSSink(NATSOptions{URL: "nats://127.0.0.1:1"}, discardLogger); err == nil {
+		t.Fatal("NewNATSSink succeeded without a server")
+	}
+}
+
+func TestRenderTopic(t *testing.T) {
+	tests := []struct {
+		template string
+		msg      Message
+		want     string
+	}{
+		{"dvr.{kind}.{type}.{imei}", Message{Kind: KindMediaUploaded, Type: "I", IMEI: "123456789012"}, "dvr.media.uploaded.I.123456789012"},
+		{"dvr.{kind}", Message{Kind: KindMediaBundle}, "dvr.media.bundle"},
+		{"dvr.{type}.{imei}", Message{}, "dvr.unknown.unknown"},
+		{"dvr.{imei}", Message{IMEI: "a.b>c*d e/f"}, "dvr.a_b_c_d_e_f"},
+		{"dvr-{type}", Message{Type: "ALARM-1_x"}, "dvr-ALARM-1_x"},
+	}
+	for _, tt := range tests {
+		if got := RenderTopic(tt.template, tt.msg); got != tt.want {
+			t.Errorf("RenderTopic(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
@@ -1,29 +1,94 @@
 package queue
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"time"
 
+	"dvr-upload/telemetry"
+
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+// publishConfirmTimeout limita a espera pelo publisher confirm de cada mensagem.
+const publishConfirmTimeout = 10 * time.Second
+
 type RabbitMQClient struct {
-	conn         *amqp.Connection
-	channel      *amqp.Channel
-	queueName    string
-	exchangeName string
-	logger       *slog.Logger
+	conn            *amqp.Connection
+	channel         *amqp.Channel
+	queueName       string
+	bundleQueueName string
+	lifecycleQueue  string
+	exchangeName    string
+	cloudEvents     *CloudEvents
+	commandChannel  *amqp.Channel
+	logger          *slog.Logger
 }
 
 type UploadEvent struct {
+	RequestID string               `json:"request_id,omitempty"`
+	IMEI      string               `json:"imei,omitempty"`
+	Type      string               `json:"type,omitempty"`
+	Channel   string               `json:"channel,omitempty"`
+	Filename  string               `json:"filename"`
+	Size      int64                `json:"size"`
+	Path      string               `json:"path,omitempty"`
+	HLS       *HLSInfo             `json:"hls,omitempty"`
+	Variants  []ImageVariant       `json:"variants,omitempty"`
+	Raw       json.RawMessage      `json:"raw,omitempty"`
+	Telemetry *telemetry.Telemetry `json:"telemetry,omitempty"`
+}
+
+// ImageVariant descreve uma variante gerada pelo pipeline de snapshots.
+type ImageVariant struct {
+	Name     string `json:"name"`
+	Format   string `json:"format"`
 	Filename string `json:"filename"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
 	Size     int64  `json:"size"`
+	Key      string `json:"key,omitempty"`
 	Path     string `json:"path,omitempty"`
 }
 
-func NewRabbitMQClient(url, queueName, exchangeName string, ttl int, logger *slog.Logger) (*RabbitMQClient, error) {
+// BundleEvent agrupa os uploads dos vários canais de um mesmo alarme (IMEI, horário e tipo).
+type BundleEvent struct {
+	BundleID    string          `json:"bundle_id"`
+	IMEI        string          `json:"imei"`
+	Type        string          `json:"type"`
+	CaptureTime time.Time       `json:"capture_time"`
+	Complete    bool            `json:"complete"`
+	Reason      string          `json:"reason"`
+	Channels    []BundleChannel `json:"channels"`
+	Missing     []string        `json:"missing_channels,omitempty"`
+	Mosaic      *UploadEvent    `json:"mosaic,omitempty"`
+}
+
+// BundleChannel é o upload de um canal dentro de um BundleEvent.
+type BundleChannel struct {
+	Channel   string `json:"channel"`
+	Filename  string `json:"filename"`
+	Size      int64  `json:"size"`
+	Path      string `json:"path,omitempty"`
+	ObjectKey string `json:"object_key,omitempty"`
+}
+
+// HLSInfo anuncia o pacote HLS gerado para o upload.
+type HLSInfo struct {
+	Prefix     string   `json:"prefix"`
+	Playlist   string   `json:"playlist"`
+	Renditions []string `json:"renditions"`
+	LocalPath  string   `json:"local_path,omitempty"`
+}
+
+func NewRabbitMQClient(url, queueName, bundleQueueName, lifecycleQueue, exchangeName string, queueOpts QueueOptions, cloudEvents *CloudEvents, logger *slog.Logger) (*RabbitMQClient, error) {
+	if err := queueOpts.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid RabbitMQ queue options: %w", err)
+	}
+
 	conn, err := amqp.Dial(url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
@@ -35,35 +100,95 @@ func NewRabbitMQClient(url, queueName, exchangeName string, ttl int, logger *slo
 		return nil, fmt.Errorf("failed to open a channel: %w", err)
 	}
 
-	if queueName != "" {
-		args := amqp.Table{
-			"x-message-ttl": int32(ttl),
+	// Divergências com filas já existentes só impedem a inicialização no modo estrito; fora dele
+	// a declaração existente continua valendo e a divergência é reportada no log.
+	tolerate := func(err error) error {
+		var mismatch *DeclarationMismatchError
+		if errors.As(err, &mismatch) && !queueOpts.Strict && ch != nil {
+			logger.Warn("RabbitMQ declaration mismatch, keeping the existing declaration",
+				"kind", mismatch.Kind,
+				"name", mismatch.Name,
+				"argument", mismatch.Argument,
+				"requested", mismatch.Requested,
+				"broker", mismatch.Reason)
+			return nil
 		}
-		_, err = ch.QueueDeclare(
-			queueName, // name
-			true,      // durable
-			false,     // delete when unused
-			false,     // exclusive
-			false,     // no-wait
-			args,      // arguments
-		)
-		if err != nil {
+		return err
+	}
+	fail := func(err error) (*RabbitMQClient, error) {
+		if ch != nil {
 			ch.Close()
-			conn.Close()
-			return nil, fmt.Errorf("failed to declare a queue: %w", err)
+		}
+		conn.Close()
+		return nil, err
+	}
+
+	var queues []string
+	for _, name := range []string{queueName, bundleQueueName, lifecycleQueue} {
+		if name != "" {
+			queues = append(queues, name)
+		}
+	}
+
+	if queueOpts.DeadLetterExchange != "" {
+		ch, err = declareExchange(conn, ch, queueOpts.DeadLetterExchange)
+		if err = tolerate(err); err != nil {
+			return fail(err)
+		}
+		if queueOpts.DeadLetterQueue != "" {
+			var dlqArgs amqp.Table
+			if queueOpts.Type == QueueTypeQuorum {
+				dlqArgs = amqp.Table{"x-queue-type": QueueTypeQuorum}
+			}
+			ch, err = declareQueue(conn, ch, queueOpts.DeadLetterQueue, dlqArgs)
+			if err = tolerate(err); err != nil {
+				return fail(err)
+			}
+			// Sem routing key de dead-letter as mensagens mantêm a original (o nome da fila de destino)
+			bindKeys := queues
+			if queueOpts.DeadLetterRoutingKey != "" {
+				bindKeys = []string{queueOpts.DeadLetterRoutingKey}
+			}
+			for _, key := range bindKeys {
+				if err := ch.QueueBind(queueOpts.DeadLetterQueue, key, queueOpts.DeadLetterExchange, false, nil); err != nil {
+					return fail(fmt.Errorf("failed to bind dead-letter queue %q: %w", queueOpts.DeadLetterQueue, err))
+				}
+			}
 		}
 	}
 
+	args := queueOpts.arguments()
+	for _, name := range queues {
+		ch, err = declareQueue(conn, ch, name, args)
+		if err = tolerate(err); err != nil {
+			return fail(err)
+		}
+	}
+
+	// Publisher confirms: Publish só retorna depois que o broker assume a mensagem, como o ack do
+	// JetStream e o RequireAll do Kafka
+	if err := ch.Confirm(false); err != nil {
+		return fail(fmt.Errorf("failed to enable publisher confirms: %w", err))
+	}
+	// Com mandatory=true o broker devolve mensagens sem fila de destino em vez de descartá-las
+	go logReturns(ch.NotifyReturn(make(chan amqp.Return, 16)), logger)
+
 	return &RabbitMQClient{
-		conn:         conn,
-		channel:      ch,
-		queueName:    queueName,
-		exchangeName: exchangeName,
-		logger:       logger,
+		conn:            conn,
+		channel:         ch,
+		queueName:       queueName,
+		bundleQueueName: bundleQueueName,
+		lifecycleQueue:  lifecycleQueue,
+		exchangeName:    exchangeName,
+		cloudEvents:     cloudEvents,
+		logger:          logger,
 	}, nil
 }
 
 func (c *RabbitMQClient) Close() {
+	if c.commandChannel != nil {
+		c.commandChannel.Close()
+	}
 	if c.channel != nil {
 		c.channel.Close()
 	}
@@ -82,27 +207,104 @@ func (c *RabbitMQClient) Ping() error {
 	return nil
 }
 
+func (c *RabbitMQClient) Name() string {
+	return "rabbitmq"
+}
+
+// Publish implementa EventSink: uploads vão para a fila principal, bundles para a fila de bundles e
+// eventos de ciclo de vida para a routing key de ciclo de vida.
+func (c *RabbitMQClient) Publish(msg Message) error {
+	if IsLifecycle(msg.Kind) {
+		if c.lifecycleQueue == "" {
+			return fmt.Errorf("lifecycle routing key not configured")
+		}
+		return c.publish(c.lifecycleQueue, msg)
+	}
+	switch msg.Kind {
+	case KindMediaUploaded:
+		return c.publish(c.queueName, msg)
+	case KindMediaBundle:
+		if c.bundleQueueName == "" {
+			return fmt.Errorf("bundle queue not configured")
+		}
+		return c.publish(c.bundleQueueName, msg)
+	}
+	return fmt.Errorf("unsupported event kind %q", msg.Kind)
+}
+
 func (c *RabbitMQClient) PublishEvent(event UploadEvent) error {
-	body, err := json.Marshal(event)
+	return c.Publish(NewUploadMessage(event))
+}
+
+func (c *RabbitMQClient) publish(routingKey string, msg Message) error {
+	encoded, err := encodeMessage(c.cloudEvents, msg)
 	if err != nil {
-		return fmt.Errorf("failed to marshal event: %w", err)
+		return err
+	}
+
+	publishing := amqp.Publishing{
+		ContentType:  encoded.ContentType,
+		Body:         encoded.Body,
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    time.Now(),
+		MessageId:    msg.ID,
+	}
+	if len(encoded.Attributes) > 0 || len(msg.Trace) > 0 {
+		publishing.Headers = amqp.Table{}
+		// Binding AMQP do CloudEvents: atributos como application properties com prefixo "cloudEvents:"
+		for k, v := range encoded.Attributes {
+			publishing.Headers["cloudEvents:"+k] = v
+		}
+		// Contexto de trace W3C para o consumidor continuar o trace
+		for k, v := range msg.Trace {
+			publishing.Headers[k] = v
+		}
 	}
 
-	err = c.channel.Publish(
+	ctx, cancel := context.WithTimeout(context.Background(), publishConfirmTimeout)
+	defer cancel()
+	confirm, err := c.channel.PublishWithDeferredConfirmWithContext(ctx,
 		c.exchangeName, // exchange
-		c.queueName,    // routing key
-		false,          // mandatory
+		routingKey,     // routing key
+		true,           // mandatory
 		false,          // immediate
-		amqp.Publishing{
-			ContentType:  "application/json",
-			Body:         body,
-			DeliveryMode: amqp.Persistent,
-			Timestamp:    time.Now(),
-		})
-
+		publishing)
 	if err != nil {
 		return fmt.Errorf("failed to publish message: %w", err)
 	}
-
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("no publisher confirm from RabbitMQ: %w", err)
+	}
+	if !acked {
+		return fmt.Errorf("message rejected by RabbitMQ (nack)")
+	}
 	return nil
 }
+
+// logReturns registra as mensagens devolvidas pelo broker por não terem fila de destino (exchange
+// sem binding para a routing key). O confirm dessas mensagens é positivo, então o log é o alerta.
+func logReturns(returns <-chan amqp.Return, logger *slog.Logger) {
+	for r := range returns {
+		logger.Error("RabbitMQ returned an unroutable message",
+			"exchange", r.Exchange,
+			"routing_key", r.RoutingKey,
+			"message_id", r.MessageId,
+			"reply", r.ReplyText)
+	}
+}
+
+// QueueDepth retorna quantas mensagens aguardam na fila. Usa um canal próprio porque a declaração
+// passiva de uma fila inexistente fecha o canal.
+func (c *RabbitMQClient) QueueDepth(name string) (int, error) {
+	ch, err := c.conn.Channel()
+	if err != nil {
+		return 0, fmt.Errorf("failed to open channel: %w", err)
+	}
+	defer ch.Close()
+	q, err := ch.QueueDeclarePassive(name, true, false, false, false, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect queue %q: %w", name, err)
+	}
+	return q.Messages, nil
+}
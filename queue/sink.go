@@ -0,0 +1,96 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Tipos de evento publicados pelo serviço.
+const (
+	KindMediaUploaded = "media.uploaded"
+	KindMediaBundle   = "media.bundle"
+)
+
+// Message é o envelope entregue aos sinks: o payload (UploadEvent, BundleEvent...) acompanhado
+// dos dados usados para roteamento, assinatura e filtros por tenant.
+type Message struct {
+	Kind    string
+	ID      string // request ID do upload ou ID do bundle
+	IMEI    string
+	Type    string
+	Time    time.Time
+	Payload interface{}
+	Trace   map[string]string // contexto de trace W3C (traceparent/tracestate) repassado nos headers
+}
+
+// NewUploadMessage monta o envelope de um UploadEvent.
+func NewUploadMessage(event UploadEvent) Message {
+	return Message{
+		Kind:    KindMediaUploaded,
+		ID:      event.RequestID,
+		IMEI:    event.IMEI,
+		Type:    event.Type,
+		Time:    time.Now().UTC(),
+		Payload: event,
+	}
+}
+
+// NewBundleMessage monta o envelope de um BundleEvent.
+func NewBundleMessage(event BundleEvent) Message {
+	return Message{
+		Kind:    KindMediaBundle,
+		ID:      event.BundleID,
+		IMEI:    event.IMEI,
+		Type:    event.Type,
+		Time:    time.Now().UTC(),
+		Payload: event,
+	}
+}
+
+// EventSink é um destino de eventos (RabbitMQ, webhook, ...).
+type EventSink interface {
+	Name() string
+	Publish(msg Message) error
+	Close()
+}
+
+// MultiSink repassa cada mensagem para todos os sinks configurados.
+type MultiSink struct {
+	sinks []EventSink
+}
+
+func NewMultiSink(sinks ...EventSink) *MultiSink {
+	return &MultiSink{sinks: sinks}
+}
+
+// Publish entrega a mensagem a todos os sinks, mesmo que algum falhe, e retorna os erros agregados.
+func (m *MultiSink) Publish(msg Message) error {
+	_, err := m.Deliver(msg)
+	return err
+}
+
+// Deliver é como Publish, mas também retorna os nomes dos sinks que aceitaram a mensagem.
+func (m *MultiSink) Deliver(msg Message) ([]string, error) {
+	var delivered []string
+	var errs []error
+	for _, s := range m.sinks {
+		if err := s.Publish(msg); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+			continue
+		}
+		delivered = append(delivered, s.Name())
+	}
+	return delivered, errors.Join(errs...)
+}
+
+// Sinks retorna os sinks configurados.
+func (m *MultiSink) Sinks() []EventSink {
+	return m.sinks
+}
+
+func (m *MultiSink) Close() {
+	for _, s := range m.sinks {
+		s.Close()
+	}
+}
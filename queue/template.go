@@ -0,0 +1,29 @@
+package queue
+
+import (
+	"strings"
+)
+
+// RenderTopic substitui {kind}, {imei} e {type} no template de subject/tópico. Valores vazios viram
+// "unknown" e caracteres fora de [A-Za-z0-9_-] viram "_", para que um IMEI ou tipo malformado não
+// crie níveis extras de subject nem nomes de tópico inválidos.
+func RenderTopic(template string, msg Message) string {
+	return strings.NewReplacer(
+		"{kind}", msg.Kind,
+		"{imei}", topicToken(msg.IMEI),
+		"{type}", topicToken(msg.Type),
+	).Replace(template)
+}
+
+func topicToken(v string) string {
+	if v == "" {
+		return "unknown"
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		}
+		return '_'
+	}, v)
+}
@@ -0,0 +1,443 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dvr-upload/config"
+
+	"github.com/google/uuid"
+)
+
+// Headers enviados em cada entrega de webhook. A assinatura é o HMAC-SHA256 (hex) de
+// "<timestamp>.<body>" com o secret do endpoint.
+const (
+	WebhookEventHeader     = "X-DVR-Event"
+	WebhookDeliveryHeader  = "X-DVR-Delivery"
+	WebhookTimestampHeader = "X-DVR-Timestamp"
+	WebhookSignatureHeader = "X-DVR-Signature"
+)
+
+// Status de uma entrega de webhook.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+	DeliveryDropped   = "dropped"
+)
+
+// WebhookOptions configura retentativas, timeout e filas do WebhookSink.
+type WebhookOptions struct {
+	MaxAttempts int
+	Backoff     time.Duration // atraso da primeira retentativa, dobra a cada tentativa
+	MaxBackoff  time.Duration
+	Timeout     time.Duration
+	Workers     int
+	QueueSize   int
+	LogSize     int
+	CloudEvents *CloudEvents
+}
+
+// Delivery é uma entrada do log de entregas.
+type Delivery struct {
+	ID           string     `json:"id"`
+	Endpoint     string     `json:"endpoint"`
+	URL          string     `json:"url"`
+	Kind         string     `json:"kind"`
+	EventID      string     `json:"event_id,omitempty"`
+	IMEI         string     `json:"imei,omitempty"`
+	Status       string     `json:"status"`
+	Attempts     int        `json:"attempts"`
+	ResponseCode int        `json:"response_code,omitempty"`
+	Error        string     `json:"error,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	NextAttempt  *time.Time `json:"next_attempt,omitempty"`
+}
+
+// DeliveryQuery filtra o log de entregas. Campos vazios não filtram.
+type DeliveryQuery struct {
+	Endpoint string
+	Status   string
+	IMEI     string
+	Limit    int
+}
+
+// DeliveryLogger é implementado pelos sinks que mantêm log de entregas consultável.
+type DeliveryLogger interface {
+	Deliveries(q DeliveryQuery) []Delivery
+}
+
+type webhookJob struct {
+	endpoint config.WebhookEndpoint
+	kind     string
+	encoded  encodedMessage
+	trace    map[string]string
+	delivery *Delivery
+}
+
+// WebhookSink entrega os eventos via HTTP POST para os endpoints de cada tenant. As entregas são
+// assíncronas, com retentativa e backoff exponencial, e ficam registradas num log em memória.
+type WebhookSink struct {
+	endpointsMu sync.RWMutex
+	endpoints   []config.WebhookEndpoint
+
+	opts   WebhookOptions
+	client *http.Client
+	jobs   chan *webhookJob
+	logger *slog.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// closeMu protege o fechamento de jobs contra envios concorrentes de Publish
+	closeMu sync.RWMutex
+	closed  bool
+
+	mu   sync.Mutex
+	log  []*Delivery // buffer circular, next aponta para a posição mais antiga
+	next int
+}
+
+func NewWebhookSink(endpoints []config.WebhookEndpoint, opts WebhookOptions, logger *slog.Logger) *WebhookSink {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 1
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 5 * time.Minute
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 100
+	}
+	if opts.LogSize <= 0 {
+		opts.LogSize = 100
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &WebhookSink{
+		endpoints: endpoints,
+		opts:      opts,
+		client:    &http.Client{Timeout: opts.Timeout},
+		jobs:      make(chan *webhookJob, opts.QueueSize),
+		logger:    logger.With("sink", "webhook"),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	for i := 0; i < opts.Workers; i++ {
+		s.wg.Add(1)
+		go s.worker()
+	}
+	return s
+}
+
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+// SetEndpoints troca os endpoints (ex: secrets rotacionados ao recarregar a configuração). Entregas
+// já enfileiradas continuam assinadas com o secret anterior.
+func (s *WebhookSink) SetEndpoints(endpoints []config.WebhookEndpoint) {
+	s.endpointsMu.Lock()
+	s.endpoints = endpoints
+	s.endpointsMu.Unlock()
+}
+
+// Publish enfileira uma entrega para cada endpoint cujo filtro aceita a mensagem. Só retorna erro
+// se a fila estiver cheia; falhas de entrega ficam no log de entregas.
+func (s *WebhookSink) Publish(msg Message) error {
+	encoded, err := encodeMessage(s.opts.CloudEvents, msg)
+	if err != nil {
+		return err
+	}
+
+	s.endpointsMu.RLock()
+	endpoints := s.endpoints
+	s.endpointsMu.RUnlock()
+
+	s.closeMu.RLock()
+	defer s.closeMu.RUnlock()
+	if s.closed {
+		return errors.New("webhook sink is shutting down")
+	}
+
+	var dropped []string
+	for _, ep := range endpoints {
+		if !endpointAccepts(ep, msg) {
+			continue
+		}
+		now := time.Now().UTC()
+		d := &Delivery{
+			ID:        uuid.NewString(),
+			Endpoint:  ep.Name,
+			URL:       ep.URL,
+			Kind:      msg.Kind,
+			EventID:   msg.ID,
+			IMEI:      msg.IMEI,
+			Status:    DeliveryPending,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		s.record(d)
+
+		select {
+		case s.jobs <- &webhookJob{endpoint: ep, kind: msg.Kind, encoded: encoded, trace: msg.Trace, delivery: d}:
+		default:
+			s.update(d, func(d *Delivery) {
+				d.Status = DeliveryDropped
+				d.Error = "delivery queue full"
+			})
+			dropped = append(dropped, ep.Name)
+		}
+	}
+	if len(dropped) > 0 {
+		return fmt.Errorf("delivery queue full, dropped endpoints: %s", strings.Join(dropped, ", "))
+	}
+	return nil
+}
+
+// Drain para de aceitar eventos e entrega os que já estão na fila, com as retentativas, até o
+// prazo de ctx. O que não for entregue a tempo fica como falho no log de entregas.
+func (s *WebhookSink) Drain(ctx context.Context) {
+	s.stopAccepting()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.logger.Warn("Webhook queue not drained before the shutdown deadline", "pending", len(s.jobs))
+		s.cancel()
+		<-done
+	}
+}
+
+// Close interrompe as entregas e retentativas pendentes e aguarda os workers.
+func (s *WebhookSink) Close() {
+	s.stopAccepting()
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *WebhookSink) stopAccepting() {
+	s.closeMu.Lock()
+	defer s.closeMu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.jobs)
+	}
+}
+
+func (s *WebhookSink) worker() {
+	defer s.wg.Done()
+	for job := range s.jobs {
+		if s.ctx.Err() != nil {
+			s.update(job.delivery, func(d *Delivery) {
+				d.Status = DeliveryFailed
+				d.Error = "shutdown before delivery"
+			})
+			continue
+		}
+		s.deliver(job)
+	}
+}
+
+func (s *WebhookSink) deliver(job *webhookJob) {
+	logger := s.logger.With("endpoint", job.endpoint.Name, "delivery_id", job.delivery.ID, "kind", job.kind)
+	backoff := s.opts.Backoff
+
+	for attempt := 1; attempt <= s.opts.MaxAttempts; attempt++ {
+		code, err := s.post(job)
+		retryable := err != nil || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+		if err == nil && code >= 200 && code < 300 {
+			s.update(job.delivery, func(d *Delivery) {
+				d.Status = DeliveryDelivered
+				d.Attempts = attempt
+				d.ResponseCode = code
+				d.Error = ""
+				d.NextAttempt = nil
+			})
+			return
+		}
+
+		errMsg := fmt.Sprintf("unexpected status %d", code)
+		if err != nil {
+			errMsg = err.Error()
+		}
+		final := !retryable || attempt == s.opts.MaxAttempts
+		s.update(job.delivery, func(d *Delivery) {
+			d.Attempts = attempt
+			d.ResponseCode = code
+			d.Error = errMsg
+			if final {
+				d.Status = DeliveryFailed
+				d.NextAttempt = nil
+			} else {
+				next := time.Now().UTC().Add(backoff)
+				d.NextAttempt = &next
+			}
+		})
+		if final {
+			logger.Error("Webhook delivery failed", "attempts", attempt, "status_code", code, "error", errMsg)
+			return
+		}
+
+		logger.Warn("Webhook delivery attempt failed, retrying", "attempt", attempt, "status_code", code, "error", errMsg, "retry_in", backoff.String())
+		select {
+		case <-s.ctx.Done():
+			s.update(job.delivery, func(d *Delivery) {
+				d.Status = DeliveryFailed
+				d.Error = "shutdown before retry: " + errMsg
+				d.NextAttempt = nil
+			})
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > s.opts.MaxBackoff {
+			backoff = s.opts.MaxBackoff
+		}
+	}
+}
+
+func (s *WebhookSink) post(job *webhookJob) (int, error) {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, job.endpoint.URL, bytes.NewReader(job.encoded.Body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", job.encoded.ContentType)
+	for k, v := range job.encoded.Attributes {
+		req.Header.Set("ce-"+k, v)
+	}
+	for k, v := range job.trace {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("User-Agent", "dvr-upload-webhook")
+	req.Header.Set(WebhookEventHeader, job.kind)
+	req.Header.Set(WebhookDeliveryHeader, job.delivery.ID)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	if job.endpoint.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(job.endpoint.Secret, timestamp, job.encoded.Body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// SignWebhook calcula a assinatura de uma entrega, para uso também por quem recebe o webhook.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func endpointAccepts(ep config.WebhookEndpoint, msg Message) bool {
+	if len(ep.Events) > 0 && !containsFold(ep.Events, msg.Kind) {
+		return false
+	}
+	if len(ep.Types) > 0 && !containsFold(ep.Types, msg.Type) {
+		return false
+	}
+	if len(ep.IMEIs) > 0 {
+		matched := false
+		for _, pattern := range ep.IMEIs {
+			if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+				matched = strings.HasPrefix(msg.IMEI, prefix)
+			} else {
+				matched = pattern == msg.IMEI
+			}
+			if matched {
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func containsFold(values []string, v string) bool {
+	for _, item := range values {
+		if strings.EqualFold(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *WebhookSink) record(d *Delivery) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.log) < s.opts.LogSize {
+		s.log = append(s.log, d)
+		return
+	}
+	s.log[s.next] = d
+	s.next = (s.next + 1) % len(s.log)
+}
+
+func (s *WebhookSink) update(d *Delivery, fn func(d *Delivery)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(d)
+	d.UpdatedAt = time.Now().UTC()
+}
+
+// Deliveries consulta o log de entregas, mais recentes primeiro.
+func (s *WebhookSink) Deliveries(q DeliveryQuery) []Delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := []Delivery{}
+	n := len(s.log)
+	for i := 0; i < n; i++ {
+		// Percorre do mais novo para o mais antigo
+		d := s.log[(s.next-1-i+2*n)%n]
+		if q.Endpoint != "" && d.Endpoint != q.Endpoint {
+			continue
+		}
+		if q.Status != "" && d.Status != q.Status {
+			continue
+		}
+		if q.IMEI != "" && d.IMEI != q.IMEI {
+			continue
+		}
+		result = append(result, *d)
+		if q.Limit > 0 && len(result) >= q.Limit {
+			break
+		}
+	}
+	return result
+}
@@ -0,0 +1,230 @@
+package queue
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"dvr-upload/config"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func testMessage() Message {
+	return Message{
+		Kind:    KindMediaUploaded,
+		ID:      "req-1",
+		IMEI:    "123456789012",
+		Type:    "I",
+		Time:    time.Date(2024, 5, 1, 11, 30, 15, 0, time.UTC),
+		Payload: map[string]any{"filename": "a.mp4"},
+		Trace:   map[string]string{"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+	}
+}
+
+// drain espera as entregas pendentes do sink terminarem.
+func drain(t *testing.T, s *WebhookSink) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	s.Drain(ctx)
+}
+
+func TestWebhookSignature(t *testing.T) {
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	requests := make(chan received, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- received{r.Header.Clone(), body}
+	}))
+	defer server.Close()
+
+	s := NewWebhookSink([]config.WebhookEndpoint{{Name: "erp", URL: server.URL, Secret: "s3cr3t"}}, WebhookOptions{}, discardLogger)
+	if err := s.Publish(testMessage()); err != nil {
+		t.Fatal(err)
+	}
+	drain(t, s)
+
+	req := <-requests
+	if string(req.body) != `{"filename":"a.mp4"}` {
+		t.Fatalf("body = %s", req.body)
+	}
+	timestamp := req.header.Get(WebhookTimestampHeader)
+	// Conferência independente de SignWebhook, como faria quem recebe
+	mac := hmac.New(sha256.New, []byte("s3cr3t"))
+	mac.Write([]byte(timestamp + "." + string(req.body)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := req.header.Get(WebhookSignatureHeader); got != want {
+		t.Fatalf("signature = %q, want %q", got, want)
+	}
+	if got := req.header.Get(WebhookEventHeader); got != KindMediaUploaded {
+		t.Fatalf("%s = %q", WebhookEventHeader, got)
+	}
+	if got := req.header.Get("traceparent"); got != testMessage().Trace["traceparent"] {
+		t.Fatalf("traceparent = %q", got)
+	}
+	deliveries := s.Deliveries(DeliveryQuery{})
+	if len(deliveries) != 1 || req.header.Get(WebhookDeliveryHeader) != deliveries[0].ID {
+		t.Fatalf("delivery header %q does not match the log %+v", req.header.Get(WebhookDeliveryHeader), deliveries)
+	}
+}
+
+func TestWebhookWithoutSecretIsUnsigned(t *testing.T) {
+	signature := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature <- r.Header.Get(WebhookSignatureHeader)
+	}))
+	defer server.Close()
+
+	s := NewWebhookSink([]config.WebhookEndpoint{{Name: "erp", URL: server.URL}}, WebhookOptions{}, discardLogger)
+	if err := s.Publish(testMessage()); err != nil {
+		t.Fatal(err)
+	}
+	drain(t, s)
+	if got := <-signature; got != "" {
+		t.Fatalf("signature sent without a secret: %q", got)
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		responses    []int // status de cada tentativa; a última se repete
+		maxAttempts  int
+		wantStatus   string
+		wantAttempts int
+		wantCode     int
+	}{
+		{name: "first attempt", responses: []int{200}, maxAttempts: 3, wantStatus: DeliveryDelivered, wantAttempts: 1, wantCode: 200},
+		{name: "server errors then success", responses: []int{500, 503, 204}, maxAttempts: 3, wantStatus: DeliveryDelivered, wantAttempts: 3, wantCode: 204},
+		{name: "rate limited then success", responses: []int{429, 200}, maxAttempts: 3, wantStatus: DeliveryDelivered, wantAttempts: 2, wantCode: 200},
+		{name: "attempts exhausted", responses: []int{502}, maxAttempts: 2, wantStatus: DeliveryFailed, wantAttempts: 2, wantCode: 502},
+		{name: "client error is not retried", responses: []int{400, 200}, maxAttempts: 3, wantStatus: DeliveryFailed, wantAttempts: 1, wantCode: 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				code := tt.responses[min(calls, len(tt.responses)-1)]
+				calls++
+				mu.Unlock()
+				w.WriteHeader(code)
+			}))
+			defer server.Close()
+
+			s := NewWebhookSink([]config.WebhookEndpoint{{Name: "erp", URL: server.URL}}, WebhookOptions{
+				MaxAttempts: tt.maxAttempts,
+				Backoff:     time.Millisecond,
+				MaxBackoff:  2 * time.Millisecond,
+			}, discardLogger)
+			if err := s.Publish(testMessage()); err != nil {
+				t.Fatal(err)
+			}
+			drain(t, s)
+
+			d := s.Deliveries(DeliveryQuery{})
+			if len(d) != 1 {
+				t.Fatalf("deliveries = %+v", d)
+			}
+			if d[0].Status != tt.wantStatus || d[0].Attempts != tt.wantAttempts || d[0].ResponseCode != tt.wantCode || d[0].NextAttempt != nil {
+				t.Fatalf("delivery = %+v, want status %s after %d attempts with %d", d[0], tt.wantStatus, tt.wantAttempts, tt.wantCode)
+			}
+			if calls != tt.wantAttempts {
+				t.Fatalf("server called %d times, want %d", calls, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestWebhookShutdownBeforeRetry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	s := NewWebhookSink([]config.WebhookEndpoint{{Name: "erp", URL: server.URL}}, WebhookOptions{MaxAttempts: 5, Backoff: time.Hour}, discardLogger)
+	if err := s.Publish(testMessage()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	s.Drain(ctx)
+
+	d := s.Deliveries(DeliveryQuery{})
+	if len(d) != 1 || d[0].Status != DeliveryFailed || d[0].Attempts != 1 {
+		t.Fatalf("deliveries = %+v, want one failed after the first attempt", d)
+	}
+	if err := s.Publish(testMessage()); err == nil {
+		t.Fatal("Publish accepted after Drain")
+	}
+}
+
+func TestWebhookEndpointFilters(t *testing.T) {
+	msg := testMessage()
+	tests := []struct {
+		name string
+		ep   config.WebhookEndpoint
+		want bool
+	}{
+		{name: "no filters", want: true},
+		{name: "event", ep: config.WebhookEndpoint{Events: []string{"MEDIA.UPLOADED"}}, want: true},
+		{name: "other event", ep: config.WebhookEndpoint{Events: []string{KindMediaRejected}}},
+		{name: "type", ep: config.WebhookEndpoint{Types: []string{"F", "i"}}, want: true},
+		{name: "other type", ep: config.WebhookEndpoint{Types: []string{"F"}}},
+		{name: "exact imei", ep: config.WebhookEndpoint{IMEIs: []string{"123456789012"}}, want: true},
+		{name: "imei prefix", ep: config.WebhookEndpoint{IMEIs: []string{"999*", "1234*"}}, want: true},
+		{name: "other imei", ep: config.WebhookEndpoint{IMEIs: []string{"12345678901", "9*"}}},
+		{name: "all filters must match", ep: config.WebhookEndpoint{Types: []string{"I"}, IMEIs: []string{"9*"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := endpointAccepts(tt.ep, msg); got != tt.want {
+				t.Fatalf("endpointAccepts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookDeliveryLog(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	s := NewWebhookSink([]config.WebhookEndpoint{
+		{Name: "erp", URL: server.URL},
+		{Name: "alarms", URL: server.URL, Types: []string{"I"}},
+	}, WebhookOptions{LogSize: 3}, discardLogger)
+	for _, imei := range []string{"1", "2", "3"} {
+		msg := testMessage()
+		msg.IMEI = imei
+		msg.Type = map[string]string{"1": "I", "2": "F", "3": "I"}[imei]
+		if err := s.Publish(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	drain(t, s)
+
+	// 5 entregas (erp x3, alarms x2) num log de 3: ficam as mais recentes, da mais nova para a mais antiga
+	all := s.Deliveries(DeliveryQuery{})
+	if len(all) != 3 || all[0].IMEI != "3" || all[0].Endpoint != "alarms" || all[2].IMEI != "2" {
+		t.Fatalf("deliveries = %+v", all)
+	}
+	if got := s.Deliveries(DeliveryQuery{Endpoint: "alarms"}); len(got) != 1 || got[0].IMEI != "3" {
+		t.Fatalf("alarms deliveries = %+v", got)
+	}
+	if got := s.Deliveries(DeliveryQuery{IMEI: "3", Limit: 1}); len(got) != 1 || got[0].Endpoint != "alarms" {
+		t.Fatalf("limited deliveries = %+v", got)
+	}
+}
@@ -0,0 +1,120 @@
+package rawblock
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// Layout do bloco raw do firmware JIMI: informação básica de posição do JT/T 808 (mensagem 0x0200)
+// seguida de um byte de checksum (XOR de todos os bytes anteriores).
+//
+//	0  alarm flags  DWORD
+//	4  status       DWORD
+//	8  latitude     DWORD (graus * 1e6)
+//	12 longitude    DWORD (graus * 1e6)
+//	16 altitude     WORD  (metros)
+//	18 speed        WORD  (0,1 km/h)
+//	20 direction    WORD  (0-359)
+//	22 time         BCD[6] YYMMDDhhmmss (GMT+8)
+//	28 checksum     BYTE
+const jimiLocationLength = 29
+
+var jimiAlarmBits = []string{
+	0:  "emergency",
+	1:  "overspeed",
+	2:  "fatigue_driving",
+	3:  "danger_warning",
+	4:  "gnss_module_fault",
+	5:  "gnss_antenna_disconnected",
+	6:  "gnss_antenna_short",
+	7:  "low_voltage",
+	8:  "power_off",
+	9:  "lcd_fault",
+	10: "tts_fault",
+	11: "camera_fault",
+	18: "driving_timeout",
+	19: "parking_timeout",
+	20: "area_in_out",
+	21: "route_in_out",
+	22: "route_time_abnormal",
+	23: "route_deviation",
+	24: "vss_fault",
+	25: "fuel_abnormal",
+	26: "vehicle_stolen",
+	27: "illegal_ignition",
+	28: "illegal_displacement",
+	29: "collision_warning",
+	30: "rollover_warning",
+	31: "illegal_door_open",
+}
+
+var jimiTimezone = time.FixedZone("GMT+8", 8*3600)
+
+// DecodeJimiLocation decodifica o bloco raw dos snapshots JIMI.
+func DecodeJimiLocation(data []byte) (map[string]any, error) {
+	if len(data) != jimiLocationLength {
+		return nil, fmt.Errorf("%w: got %d bytes, want %d", ErrLength, len(data), jimiLocationLength)
+	}
+	var sum byte
+	for _, b := range data[:jimiLocationLength-1] {
+		sum ^= b
+	}
+	if sum != data[jimiLocationLength-1] {
+		return nil, fmt.Errorf("%w: got %02X, want %02X", ErrChecksum, data[jimiLocationLength-1], sum)
+	}
+
+	alarmFlags := binary.BigEndian.Uint32(data[0:4])
+	status := binary.BigEndian.Uint32(data[4:8])
+	lat := float64(binary.BigEndian.Uint32(data[8:12])) / 1e6
+	lon := float64(binary.BigEndian.Uint32(data[12:16])) / 1e6
+	if status&(1<<2) != 0 {
+		lat = -lat
+	}
+	if status&(1<<3) != 0 {
+		lon = -lon
+	}
+
+	alarms := []string{}
+	for bit, name := range jimiAlarmBits {
+		if name != "" && alarmFlags&(1<<uint(bit)) != 0 {
+			alarms = append(alarms, name)
+		}
+	}
+
+	fields := map[string]any{
+		"alarm_flags": alarmFlags,
+		"alarms":      alarms,
+		"status":      status,
+		"acc_on":      status&(1<<0) != 0,
+		"positioned":  status&(1<<1) != 0,
+		"latitude":    lat,
+		"longitude":   lon,
+		"altitude":    binary.BigEndian.Uint16(data[16:18]),
+		"speed_kmh":   float64(binary.BigEndian.Uint16(data[18:20])) / 10,
+		"heading":     binary.BigEndian.Uint16(data[20:22]),
+	}
+
+	t, err := parseBCDTime(data[22:28])
+	if err != nil {
+		return nil, err
+	}
+	fields["device_time"] = t
+	return fields, nil
+}
+
+func parseBCDTime(b []byte) (time.Time, error) {
+	var v [6]int
+	for i, x := range b {
+		hi, lo := x>>4, x&0x0F
+		if hi > 9 || lo > 9 {
+			return time.Time{}, fmt.Errorf("%w: invalid BCD time", ErrMalformed)
+		}
+		v[i] = int(hi)*10 + int(lo)
+	}
+	t := time.Date(2000+v[0], time.Month(v[1]), v[2], v[3], v[4], v[5], 0, jimiTimezone)
+	if t.Month() != time.Month(v[1]) || t.Day() != v[2] || v[3] > 23 || v[4] > 59 || v[5] > 59 {
+		return time.Time{}, fmt.Errorf("%w: invalid BCD time", ErrMalformed)
+	}
+	return t.UTC(), nil
+}
@@ -0,0 +1,86 @@
+package rawblock
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrNoDecoder = errors.New("no decoder registered for pattern/family")
+	ErrLength    = errors.New("invalid raw block length")
+	ErrChecksum  = errors.New("raw block checksum mismatch")
+	ErrMalformed = errors.New("malformed raw block")
+)
+
+// Decoder converte o bloco raw (já em bytes) em campos estruturados.
+type Decoder interface {
+	Decode(data []byte) (map[string]any, error)
+}
+
+// DecoderFunc adapta uma função comum para a interface Decoder.
+type DecoderFunc func(data []byte) (map[string]any, error)
+
+func (f DecoderFunc) Decode(data []byte) (map[string]any, error) { return f(data) }
+
+// Result é o bloco decodificado anexado ao evento e ao catálogo.
+type Result struct {
+	Decoder string         `json:"decoder"`
+	Hex     string         `json:"hex"`
+	Fields  map[string]any `json:"fields"`
+}
+
+// Registry associa decodificadores ao par (pattern do nome de arquivo, família de firmware).
+type Registry struct {
+	mu       sync.RWMutex
+	decoders map[string]Decoder
+}
+
+func NewRegistry() *Registry {
+	return &Registry{decoders: make(map[string]Decoder)}
+}
+
+func registryKey(pattern, family string) string {
+	return strings.ToLower(pattern) + "/" + strings.ToLower(family)
+}
+
+// Register adiciona (ou substitui) o decodificador de um pattern/família.
+func (r *Registry) Register(pattern, family string, d Decoder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.decoders[registryKey(pattern, family)] = d
+}
+
+// Lookup retorna o decodificador do pattern/família, se houver.
+func (r *Registry) Lookup(pattern, family string) (Decoder, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	d, ok := r.decoders[registryKey(pattern, family)]
+	return d, ok
+}
+
+// Decode converte o hex e aplica o decodificador registrado para o pattern/família.
+func (r *Registry) Decode(pattern, family, rawHex string) (*Result, error) {
+	d, ok := r.Lookup(pattern, family)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoDecoder, registryKey(pattern, family))
+	}
+	data, err := hex.DecodeString(rawHex)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	fields, err := d.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Decoder: registryKey(pattern, family), Hex: strings.ToUpper(rawHex), Fields: fields}, nil
+}
+
+// DefaultRegistry já vem com os decodificadores embutidos.
+func DefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.Register("snapshot", "jimi", DecoderFunc(DecodeJimiLocation))
+	return r
+}
@@ -0,0 +1,106 @@
+package rawblock
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// jimiBlock monta um bloco JIMI em hex com o checksum correto.
+func jimiBlock(alarms, status, lat, lon uint32, altitude, speed, heading uint16, bcdTime string) string {
+	data := make([]byte, 0, jimiLocationLength)
+	data = binary.BigEndian.AppendUint32(data, alarms)
+	data = binary.BigEndian.AppendUint32(data, status)
+	data = binary.BigEndian.AppendUint32(data, lat)
+	data = binary.BigEndian.AppendUint32(data, lon)
+	data = binary.BigEndian.AppendUint16(data, altitude)
+	data = binary.BigEndian.AppendUint16(data, speed)
+	data = binary.BigEndian.AppendUint16(data, heading)
+	t, _ := hex.DecodeString(bcdTime)
+	data = append(data, t...)
+	var sum byte
+	for _, b := range data {
+		sum ^= b
+	}
+	return hex.EncodeToString(append(data, sum))
+}
+
+func TestDecodeJimi(t *testing.T) {
+	valid := jimiBlock(1<<0|1<<7, 1<<0|1<<1|1<<2, 23550520, 46633308, 760, 625, 90, "240501083015")
+	corrupted := []byte(valid)
+	corrupted[len(corrupted)-1] ^= 1 // último dígito do checksum
+
+	tests := []struct {
+		name    string
+		pattern string
+		family  string
+		rawHex  string
+		want    map[string]any
+		wantErr error
+	}{
+		{
+			name:    "valid block",
+			pattern: "snapshot",
+			family:  "jimi",
+			rawHex:  valid,
+			want: map[string]any{
+				"alarm_flags": uint32(1<<0 | 1<<7),
+				"alarms":      []string{"emergency", "low_voltage"},
+				"status":      uint32(1<<0 | 1<<1 | 1<<2),
+				"acc_on":      true,
+				"positioned":  true,
+				"latitude":    -23.55052,
+				"longitude":   46.633308,
+				"altitude":    uint16(760),
+				"speed_kmh":   62.5,
+				"heading":     uint16(90),
+				"device_time": time.Date(2024, 5, 1, 0, 30, 15, 0, time.UTC),
+			},
+		},
+		{name: "pattern and family are case insensitive", pattern: "SNAPSHOT", family: "Jimi", rawHex: valid},
+		{name: "no decoder for the family", pattern: "snapshot", family: "other", rawHex: valid, wantErr: ErrNoDecoder},
+		{name: "no decoder for event uploads", pattern: "event", family: "jimi", rawHex: valid, wantErr: ErrNoDecoder},
+		{name: "not hex", pattern: "snapshot", family: "jimi", rawHex: "zz", wantErr: ErrMalformed},
+		{name: "too short", pattern: "snapshot", family: "jimi", rawHex: valid[:20], wantErr: ErrLength},
+		{name: "bad checksum", pattern: "snapshot", family: "jimi", rawHex: string(corrupted), wantErr: ErrChecksum},
+		{
+			name:    "invalid BCD time",
+			pattern: "snapshot",
+			family:  "jimi",
+			rawHex:  jimiBlock(0, 0, 0, 0, 0, 0, 0, "24130108301A"),
+			wantErr: ErrMalformed,
+		},
+		{
+			name:    "impossible date",
+			pattern: "snapshot",
+			family:  "jimi",
+			rawHex:  jimiBlock(0, 0, 0, 0, 0, 0, 0, "240231083015"),
+			wantErr: ErrMalformed,
+		},
+	}
+
+	r := DefaultRegistry()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := r.Decode(tt.pattern, tt.family, tt.rawHex)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Decoder != "snapshot/jimi" {
+				t.Fatalf("decoder = %q", result.Decoder)
+			}
+			if tt.want != nil && !reflect.DeepEqual(result.Fields, tt.want) {
+				t.Fatalf("fields = %#v\nwant %#v", result.Fields, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package scheduler
+
+import (
+	"sort"
+	"sync"
+)
+
+// FairQueue limita o número de jobs simultâneos e, quando não há vaga,
+// distribui as vagas liberadas em round-robin entre as chaves (IMEIs) com jobs
+// aguardando, em vez de atender por ordem de chegada. Dentro de uma mesma
+// chave a ordem é FIFO.
+type FairQueue struct {
+	mu       sync.Mutex
+	capacity int
+	running  int
+	waiting  int
+	queues   map[string][]chan struct{}
+	order    []string // chaves com jobs aguardando, na ordem de atendimento
+}
+
+func NewFairQueue(capacity int) *FairQueue {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &FairQueue{
+		capacity: capacity,
+		queues:   make(map[string][]chan struct{}),
+	}
+}
+
+// Acquire bloqueia até existir uma vaga para a chave informada e retorna a
+// função que devolve a vaga. A função de release deve ser chamada exatamente uma vez.
+func (q *FairQueue) Acquire(key string) (release func()) {
+	q.mu.Lock()
+	if q.running < q.capacity && q.waiting == 0 {
+		q.running++
+		q.mu.Unlock()
+		return q.release
+	}
+
+	ch := make(chan struct{})
+	if _, ok := q.queues[key]; !ok {
+		q.order = append(q.order, key)
+	}
+	q.queues[key] = append(q.queues[key], ch)
+	q.waiting++
+	q.mu.Unlock()
+
+	<-ch
+	return q.release
+}
+
+func (q *FairQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.waiting == 0 {
+		q.running--
+		return
+	}
+
+	// Entrega a vaga diretamente ao próximo job da próxima chave da fila
+	key := q.order[0]
+	q.order = q.order[1:]
+	pending := q.queues[key]
+	next := pending[0]
+	if len(pending) > 1 {
+		q.queues[key] = pending[1:]
+		q.order = append(q.order, key)
+	} else {
+		delete(q.queues, key)
+	}
+	q.waiting--
+	close(next)
+}
+
+// Running retorna o número de jobs em execução.
+func (q *FairQueue) Running() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.running
+}
+
+// Waiting retorna o número de jobs aguardando vaga.
+func (q *FairQueue) Waiting() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.waiting
+}
+
+// KeyBacklog é a quantidade de jobs aguardando de uma chave.
+type KeyBacklog struct {
+	Key     string `json:"key"`
+	Waiting int    `json:"waiting"`
+}
+
+// Backlog retorna as chaves com jobs aguardando, das mais carregadas para as menos.
+func (q *FairQueue) Backlog(limit int) []KeyBacklog {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	backlog := make([]KeyBacklog, 0, len(q.queues))
+	for key, pending := range q.queues {
+		backlog = append(backlog, KeyBacklog{Key: key, Waiting: len(pending)})
+	}
+	sort.Slice(backlog, func(i, j int) bool {
+		if backlog[i].Waiting != backlog[j].Waiting {
+			return backlog[i].Waiting > backlog[j].Waiting
+		}
+		return backlog[i].Key < backlog[j].Key
+	})
+	if limit > 0 && len(backlog) > limit {
+		backlog = backlog[:limit]
+	}
+	return backlog
+}
@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFairQueueRoundRobin(t *testing.T) {
+	tests := []struct {
+		name     string
+		arrivals []string // chaves que chegam, em ordem, com a fila cheia
+		want     []string // ordem em que recebem a vaga
+	}{
+		{
+			name:     "single key is FIFO",
+			arrivals: []string{"a", "a", "a"},
+			want:     []string{"a", "a", "a"},
+		},
+		{
+			name:     "keys alternate",
+			arrivals: []string{"a", "a", "a", "b", "c"},
+			want:     []string{"a", "b", "c", "a", "a"},
+		},
+		{
+			name:     "key that drained rejoins at the end",
+			arrivals: []string{"a", "b", "b", "c"},
+			want:     []string{"a", "b", "c", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewFairQueue(1)
+			release := q.Acquire("busy")
+
+			type grant struct {
+				key     string
+				release func()
+			}
+			granted := make(chan grant)
+			for i, key := range tt.arrivals {
+				go func(key string) {
+					granted <- grant{key, q.Acquire(key)}
+				}(key)
+				waitFor(t, func() bool { return q.Waiting() == i+1 })
+			}
+
+			var got []string
+			for range tt.arrivals {
+				release()
+				g := <-granted
+				got = append(got, g.key)
+				release = g.release
+			}
+			release()
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("grant order = %v, want %v", got, tt.want)
+			}
+			if q.Running() != 0 || q.Waiting() != 0 {
+				t.Fatalf("after releasing everything running=%d waiting=%d", q.Running(), q.Waiting())
+			}
+		})
+	}
+}
+
+func TestFairQueueCapacity(t *testing.T) {
+	tests := []struct {
+		capacity int
+		want     int
+	}{
+		{capacity: 3, want: 3},
+		{capacity: 0, want: 1},
+		{capacity: -2, want: 1},
+	}
+	for _, tt := range tests {
+		q := NewFairQueue(tt.capacity)
+		for i := 0; i < tt.want; i++ {
+			q.Acquire("k")
+		}
+		if q.Running() != tt.want || q.Waiting() != 0 {
+			t.Fatalf("capacity %d: running=%d waiting=%d, want %d running", tt.capacity, q.Running(), q.Waiting(), tt.want)
+		}
+	}
+}
+
+func TestFairQueueBacklog(t *testing.T) {
+	q := NewFairQueue(1)
+	q.Acquire("busy")
+	for i, key := range []string{"b", "a", "a", "c", "a", "b"} {
+		go q.Acquire(key)
+		waitFor(t, func() bool { return q.Waiting() == i+1 })
+	}
+
+	want := []KeyBacklog{{Key: "a", Waiting: 3}, {Key: "b", Waiting: 2}}
+	if got := q.Backlog(2); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Backlog(2) = %v, want %v", got, want)
+	}
+}
+
+// waitFor espera cond ficar verdadeira (goroutines chegando na fila).
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the queue")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
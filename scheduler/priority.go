@@ -0,0 +1,165 @@
+package scheduler
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"dvr-upload/config"
+)
+
+// DefaultClass é a classe usada quando nenhuma classe configurada atende o job.
+const DefaultClass = "default"
+
+// JobInfo contém os atributos de um job usados para classificá-lo.
+type JobInfo struct {
+	Type     string
+	Channel  string
+	Filename string
+}
+
+type lane struct {
+	class   config.PriorityClass
+	pattern *regexp.Regexp
+	queue   *FairQueue
+}
+
+func (l *lane) catchAll() bool {
+	return len(l.class.Types) == 0 && len(l.class.Channels) == 0 && l.pattern == nil
+}
+
+func (l *lane) matches(info JobInfo) bool {
+	if len(l.class.Types) > 0 && !containsFold(l.class.Types, info.Type) {
+		return false
+	}
+	if len(l.class.Channels) > 0 && !containsFold(l.class.Channels, info.Channel) {
+		return false
+	}
+	if l.pattern != nil && !l.pattern.MatchString(info.Filename) {
+		return false
+	}
+	return true
+}
+
+// PriorityScheduler separa os jobs em classes de prioridade, cada uma com sua
+// própria fatia de workers (e sua própria FairQueue), para que mídias de alta
+// prioridade não esperem atrás de gravações de rotina. As classes são avaliadas
+// na ordem configurada e o primeiro match vence.
+type PriorityScheduler struct {
+	lanes    []*lane
+	fallback *lane
+}
+
+// NewPriorityScheduler cria o escalonador. As classes dividem maxWorkers entre si: a soma
+// dos seus workers não pode passar do limite. Sem classes configuradas existe apenas a
+// classe "default" com maxWorkers. Jobs que não casam com nenhuma classe vão para a
+// primeira classe sem regras ou, se não houver, para uma classe "default" implícita com
+// os workers que sobraram.
+func NewPriorityScheduler(classes []config.PriorityClass, maxWorkers int) (*PriorityScheduler, error) {
+	s := &PriorityScheduler{}
+	assigned := 0
+	seen := make(map[string]bool)
+	for _, c := range classes {
+		if c.Name == "" {
+			return nil, fmt.Errorf("priority class without name")
+		}
+		if seen[c.Name] {
+			return nil, fmt.Errorf("duplicate priority class %q", c.Name)
+		}
+		seen[c.Name] = true
+		if c.Workers <= 0 {
+			return nil, fmt.Errorf("priority class %q must have at least one worker", c.Name)
+		}
+		assigned += c.Workers
+
+		l := &lane{class: c, queue: NewFairQueue(c.Workers)}
+		if c.Pattern != "" {
+			re, err := regexp.Compile(c.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("priority class %q: invalid pattern: %w", c.Name, err)
+			}
+			l.pattern = re
+		}
+		s.lanes = append(s.lanes, l)
+		if s.fallback == nil && l.catchAll() {
+			s.fallback = l
+		}
+	}
+
+	if assigned > maxWorkers {
+		return nil, fmt.Errorf("priority classes use %d workers, more than the limit of %d", assigned, maxWorkers)
+	}
+	if s.fallback == nil {
+		remaining := maxWorkers - assigned
+		if remaining <= 0 {
+			return nil, fmt.Errorf("priority classes use all %d workers, none left for the %q class (add a class without rules)", maxWorkers, DefaultClass)
+		}
+		s.fallback = &lane{
+			class: config.PriorityClass{Name: DefaultClass, Workers: remaining},
+			queue: NewFairQueue(remaining),
+		}
+		s.lanes = append(s.lanes, s.fallback)
+	}
+	return s, nil
+}
+
+// Classify retorna o nome da classe de prioridade do job.
+func (s *PriorityScheduler) Classify(info JobInfo) string {
+	return s.laneFor(info).class.Name
+}
+
+func (s *PriorityScheduler) laneFor(info JobInfo) *lane {
+	for _, l := range s.lanes {
+		if !l.catchAll() && l.matches(info) {
+			return l
+		}
+	}
+	return s.fallback
+}
+
+func (s *PriorityScheduler) lane(class string) *lane {
+	for _, l := range s.lanes {
+		if l.class.Name == class {
+			return l
+		}
+	}
+	return s.fallback
+}
+
+// Acquire bloqueia até existir uma vaga na classe informada para a chave (IMEI).
+func (s *PriorityScheduler) Acquire(class, key string) (release func()) {
+	return s.lane(class).queue.Acquire(key)
+}
+
+// ClassStats resume a ocupação de uma classe de prioridade.
+type ClassStats struct {
+	Name    string       `json:"name"`
+	Workers int          `json:"workers"`
+	Running int          `json:"running"`
+	Waiting int          `json:"waiting"`
+	Backlog []KeyBacklog `json:"backlog,omitempty"`
+}
+
+// Stats retorna a ocupação de cada classe, na ordem de prioridade.
+func (s *PriorityScheduler) Stats(backlogLimit int) []ClassStats {
+	stats := make([]ClassStats, 0, len(s.lanes))
+	for _, l := range s.lanes {
+		stats = append(stats, ClassStats{
+			Name:    l.class.Name,
+			Workers: l.class.Workers,
+			Running: l.queue.Running(),
+			Waiting: l.queue.Waiting(),
+			Backlog: l.queue.Backlog(backlogLimit),
+		})
+	}
+	return stats
+}
+
+func containsFold(values []string, v string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(strings.TrimSpace(candidate), v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package scheduler
+
+import (
+	"maps"
+	"strings"
+	"testing"
+
+	"dvr-upload/config"
+)
+
+func TestPrioritySchedulerClassify(t *testing.T) {
+	classes := []config.PriorityClass{
+		{Name: "alarm", Types: []string{"I"}, Workers: 2},
+		{Name: "front", Channels: []string{"1"}, Pattern: `^EVENT_`, Workers: 1},
+		{Name: "routine", Workers: 2},
+	}
+	s, err := NewPriorityScheduler(classes, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		info JobInfo
+		want string
+	}{
+		{"type matches", JobInfo{Type: "I", Channel: "1"}, "alarm"},
+		{"type is case insensitive", JobInfo{Type: "i"}, "alarm"},
+		{"channel and pattern match", JobInfo{Type: "F", Channel: "1", Filename: "EVENT_1.mp4"}, "front"},
+		{"pattern does not match", JobInfo{Type: "F", Channel: "1", Filename: "snapshot.jpg"}, "routine"},
+		{"nothing matches", JobInfo{Type: "F", Channel: "2"}, "routine"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.Classify(tt.info); got != tt.want {
+				t.Fatalf("Classify(%+v) = %q, want %q", tt.info, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPriorityScheduler(t *testing.T) {
+	tests := []struct {
+		name        string
+		classes     []config.PriorityClass
+		maxWorkers  int
+		wantErr     string
+		wantWorkers map[string]int
+	}{
+		{
+			name:        "no classes",
+			maxWorkers:  4,
+			wantWorkers: map[string]int{DefaultClass: 4},
+		},
+		{
+			name:        "implicit default gets the remaining workers",
+			classes:     []config.PriorityClass{{Name: "alarm", Types: []string{"I"}, Workers: 3}},
+			maxWorkers:  4,
+			wantWorkers: map[string]int{"alarm": 3, DefaultClass: 1},
+		},
+		{
+			name:        "catch-all class replaces the default",
+			classes:     []config.PriorityClass{{Name: "alarm", Types: []string{"I"}, Workers: 3}, {Name: "rest", Workers: 1}},
+			maxWorkers:  4,
+			wantWorkers: map[string]int{"alarm": 3, "rest": 1},
+		},
+		{
+			name:       "classes above the limit",
+			classes:    []config.PriorityClass{{Name: "alarm", Types: []string{"I"}, Workers: 3}, {Name: "rest", Workers: 2}},
+			maxWorkers: 4,
+			wantErr:    "more than the limit",
+		},
+		{
+			name:       "no workers left for the default",
+			classes:    []config.PriorityClass{{Name: "alarm", Types: []string{"I"}, Workers: 4}},
+			maxWorkers: 4,
+			wantErr:    "none left",
+		},
+		{
+			name:       "duplicate name",
+			classes:    []config.PriorityClass{{Name: "a", Workers: 1}, {Name: "a", Workers: 1}},
+			maxWorkers: 4,
+			wantErr:    "duplicate",
+		},
+		{
+			name:       "missing workers",
+			classes:    []config.PriorityClass{{Name: "a"}},
+			maxWorkers: 4,
+			wantErr:    "at least one worker",
+		},
+		{
+			name:       "invalid pattern",
+			classes:    []config.PriorityClass{{Name: "a", Pattern: "(", Workers: 1}},
+			maxWorkers: 4,
+			wantErr:    "invalid pattern",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewPriorityScheduler(tt.classes, tt.maxWorkers)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]int{}
+			for _, c := range s.Stats(0) {
+				got[c.Name] = c.Workers
+			}
+			if !maps.Equal(got, tt.wantWorkers) {
+				t.Fatalf("workers per class = %v, want %v", got, tt.wantWorkers)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package scheduler
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// tokenBucket é um balde de tokens simples; não é seguro para uso concorrente
+// (o DeviceLimiter protege o acesso com seu próprio mutex).
+type tokenBucket struct {
+	capacity float64
+	tokens   float64
+	rate     float64 // tokens por segundo
+	last     time.Time
+}
+
+func newTokenBucket(capacity float64, per time.Duration, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		capacity: capacity,
+		tokens:   capacity,
+		rate:     capacity / per.Seconds(),
+		last:     now,
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens += elapsed * b.rate
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+	}
+	b.last = now
+}
+
+// allows indica se há saldo para n tokens, sem consumi-los. Requisições maiores que a
+// capacidade do balde só passam com o balde cheio, para que um único arquivo grande
+// não fique bloqueado para sempre.
+func (b *tokenBucket) allows(n float64, now time.Time) bool {
+	b.refill(now)
+	if n > b.capacity {
+		return b.tokens >= b.capacity
+	}
+	return b.tokens >= n
+}
+
+// take consome n tokens; o chamador já verificou o saldo com allows. Requisições maiores
+// que a capacidade esvaziam o balde.
+func (b *tokenBucket) take(n float64) {
+	b.tokens -= min(n, b.capacity)
+}
+
+type deviceState struct {
+	uploads             *tokenBucket
+	bytes               *tokenBucket
+	rejectedUploads     int64
+	byteLimitRejections int64
+	lastRejected        time.Time
+	lastSeen            time.Time
+}
+
+// Offender descreve um dispositivo que já teve uploads recusados pelo limitador. Os contadores
+// são de uploads recusados por cada limite (ByteLimitRejections não é uma soma de bytes).
+type Offender struct {
+	IMEI                string    `json:"imei"`
+	RejectedUploads     int64     `json:"rejected_uploads"`
+	ByteLimitRejections int64     `json:"byte_limit_rejections"`
+	LastRejectedAt      time.Time `json:"last_rejected_at"`
+}
+
+// DeviceLimiter aplica limites por IMEI de uploads por minuto e de bytes por hora.
+// Um limite <= 0 desativa a respectiva verificação.
+type DeviceLimiter struct {
+	mu               sync.Mutex
+	uploadsPerMinute int
+	bytesPerHour     int64
+	devices          map[string]*deviceState
+	lastPrune        time.Time
+	now              func() time.Time
+}
+
+// Dispositivos sem atividade por mais que isso são descartados da memória.
+const deviceIdleTTL = 2 * time.Hour
+
+func NewDeviceLimiter(uploadsPerMinute int, bytesPerHour int64) *DeviceLimiter {
+	return &DeviceLimiter{
+		uploadsPerMinute: uploadsPerMinute,
+		bytesPerHour:     bytesPerHour,
+		devices:          make(map[string]*deviceState),
+		now:              time.Now,
+	}
+}
+
+// Enabled indica se algum dos limites está ativo.
+func (l *DeviceLimiter) Enabled() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.uploadsPerMinute > 0 || l.bytesPerHour > 0
+}
+
+// SetLimits altera os limites em tempo de execução. Os baldes existentes são
+// recriados na próxima requisição de cada dispositivo.
+func (l *DeviceLimiter) SetLimits(uploadsPerMinute int, bytesPerHour int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.uploadsPerMinute == uploadsPerMinute && l.bytesPerHour == bytesPerHour {
+		return
+	}
+	l.uploadsPerMinute = uploadsPerMinute
+	l.bytesPerHour = bytesPerHour
+	for _, d := range l.devices {
+		d.uploads = nil
+		d.bytes = nil
+	}
+}
+
+// Allow verifica (e consome) um upload de size bytes para o IMEI informado.
+// Retorna false e o motivo quando algum dos limites foi excedido.
+func (l *DeviceLimiter) Allow(imei string, size int64) (bool, string) {
+	if imei == "" {
+		return true, ""
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.uploadsPerMinute <= 0 && l.bytesPerHour <= 0 {
+		return true, ""
+	}
+
+	now := l.now()
+	l.prune(now)
+
+	d := l.devices[imei]
+	if d == nil {
+		d = &deviceState{}
+		l.devices[imei] = d
+	}
+	d.lastSeen = now
+
+	if l.uploadsPerMinute > 0 && d.uploads == nil {
+		d.uploads = newTokenBucket(float64(l.uploadsPerMinute), time.Minute, now)
+	}
+	if l.bytesPerHour > 0 && d.bytes == nil {
+		d.bytes = newTokenBucket(float64(l.bytesPerHour), time.Hour, now)
+	}
+
+	// Os dois baldes são verificados antes de consumir qualquer um: um upload recusado
+	// por bytes não gasta a cota de uploads por minuto (e vice-versa)
+	if d.uploads != nil && !d.uploads.allows(1, now) {
+		d.rejectedUploads++
+		d.lastRejected = now
+		return false, "uploads_per_minute"
+	}
+	if d.bytes != nil && !d.bytes.allows(float64(size), now) {
+		d.byteLimitRejections++
+		d.lastRejected = now
+		return false, "bytes_per_hour"
+	}
+	if d.uploads != nil {
+		d.uploads.take(1)
+	}
+	if d.bytes != nil {
+		d.bytes.take(float64(size))
+	}
+
+	return true, ""
+}
+
+// Offenders retorna os dispositivos com rejeições, do mais recente para o mais antigo.
+func (l *DeviceLimiter) Offenders() []Offender {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	offenders := []Offender{}
+	for imei, d := range l.devices {
+		if d.rejectedUploads == 0 && d.byteLimitRejections == 0 {
+			continue
+		}
+		offenders = append(offenders, Offender{
+			IMEI:                imei,
+			RejectedUploads:     d.rejectedUploads,
+			ByteLimitRejections: d.byteLimitRejections,
+			LastRejectedAt:      d.lastRejected,
+		})
+	}
+	sort.Slice(offenders, func(i, j int) bool {
+		return offenders[i].LastRejectedAt.After(offenders[j].LastRejectedAt)
+	})
+	return offenders
+}
+
+func (l *DeviceLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < time.Minute {
+		return
+	}
+	l.lastPrune = now
+	for imei, d := range l.devices {
+		if now.Sub(d.lastSeen) > deviceIdleTTL {
+			delete(l.devices, imei)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeviceLimiterAllow(t *testing.T) {
+	type step struct {
+		advance    time.Duration
+		imei       string
+		size       int64
+		wantOK     bool
+		wantReason string
+	}
+	tests := []struct {
+		name             string
+		uploadsPerMinute int
+		bytesPerHour     int64
+		steps            []step
+	}{
+		{
+			name: "limits disabled",
+			steps: []step{
+				{imei: "1", size: 1 << 30, wantOK: true},
+				{imei: "1", size: 1 << 30, wantOK: true},
+			},
+		},
+		{
+			name:             "uploads per minute exhausted then refilled",
+			uploadsPerMinute: 2,
+			steps: []step{
+				{imei: "1", wantOK: true},
+				{imei: "1", wantOK: true},
+				{imei: "1", wantReason: "uploads_per_minute"},
+				{advance: 30 * time.Second, imei: "1", wantOK: true},
+				{imei: "1", wantReason: "uploads_per_minute"},
+			},
+		},
+		{
+			name:             "devices have separate buckets",
+			uploadsPerMinute: 1,
+			steps: []step{
+				{imei: "1", wantOK: true},
+				{imei: "2", wantOK: true},
+				{imei: "1", wantReason: "uploads_per_minute"},
+			},
+		},
+		{
+			name:             "empty imei is never limited",
+			uploadsPerMinute: 1,
+			steps: []step{
+				{wantOK: true},
+				{wantOK: true},
+			},
+		},
+		{
+			name:         "bytes per hour",
+			bytesPerHour: 100,
+			steps: []step{
+				{imei: "1", size: 60, wantOK: true},
+				{imei: "1", size: 60, wantReason: "bytes_per_hour"},
+				{advance: 12 * time.Minute, imei: "1", size: 60, wantOK: true},
+			},
+		},
+		{
+			name:             "byte limit rejection does not use the upload quota",
+			uploadsPerMinute: 1,
+			bytesPerHour:     100,
+			steps: []step{
+				{imei: "1", size: 200, wantOK: true},
+				{advance: 30 * time.Minute, imei: "1", size: 200, wantReason: "bytes_per_hour"},
+				{imei: "1", size: 10, wantOK: true},
+			},
+		},
+		{
+			name:             "upload limit rejection does not use the byte quota",
+			uploadsPerMinute: 1,
+			bytesPerHour:     100,
+			steps: []step{
+				{imei: "1", size: 50, wantOK: true},
+				{imei: "1", size: 50, wantReason: "uploads_per_minute"},
+				{advance: time.Minute, imei: "1", size: 48, wantOK: true},
+			},
+		},
+		{
+			name:         "file larger than the bucket needs a full bucket",
+			bytesPerHour: 100,
+			steps: []step{
+				{imei: "1", size: 500, wantOK: true},
+				{imei: "1", size: 500, wantReason: "bytes_per_hour"},
+				{advance: time.Hour, imei: "1", size: 500, wantOK: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			l := NewDeviceLimiter(tt.uploadsPerMinute, tt.bytesPerHour)
+			l.now = func() time.Time { return now }
+			for i, s := range tt.steps {
+				now = now.Add(s.advance)
+				ok, reason := l.Allow(s.imei, s.size)
+				if ok != s.wantOK || reason != s.wantReason {
+					t.Fatalf("step %d: Allow(%q, %d) = %v, %q; want %v, %q", i, s.imei, s.size, ok, reason, s.wantOK, s.wantReason)
+				}
+			}
+		})
+	}
+}
+
+func TestDeviceLimiterSetLimits(t *testing.T) {
+	l := NewDeviceLimiter(1, 0)
+	if ok, _ := l.Allow("1", 0); !ok {
+		t.Fatal("first upload rejected")
+	}
+	if ok, _ := l.Allow("1", 0); ok {
+		t.Fatal("second upload allowed with a limit of 1 per minute")
+	}
+	l.SetLimits(5, 10)
+	if ok, reason := l.Allow("1", 0); !ok {
+		t.Fatalf("upload rejected after raising the limit: %s", reason)
+	}
+	if ok, _ := l.Allow("1", 1000); !ok {
+		t.Fatal("upload larger than a full byte bucket rejected")
+	}
+	if ok, _ := l.Allow("1", 1000); ok {
+		t.Fatal("upload allowed with an empty byte bucket")
+	}
+
+	offenders := l.Offenders()
+	if len(offenders) != 1 || offenders[0].IMEI != "1" || offenders[0].RejectedUploads != 1 || offenders[0].ByteLimitRejections != 1 {
+		t.Fatalf("Offenders() = %+v, want one offender with one rejection per limit", offenders)
+	}
+}
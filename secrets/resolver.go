@@ -0,0 +1,188 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resolver resolve uma referência de secret (ex: file:///run/secrets/key, vault://secret/data/dvr#key).
+type Resolver interface {
+	Resolve(ctx context.Context, ref *url.URL) (string, error)
+}
+
+// ResolverFunc adapta uma função a Resolver.
+type ResolverFunc func(ctx context.Context, ref *url.URL) (string, error)
+
+func (f ResolverFunc) Resolve(ctx context.Context, ref *url.URL) (string, error) {
+	return f(ctx, ref)
+}
+
+var (
+	pluginsMu sync.RWMutex
+	plugins   = map[string]Resolver{}
+)
+
+// Register adiciona um resolver para o esquema informado a todos os registries criados depois.
+// Permite integrar outros gerenciadores de secrets sem alterar o carregamento da configuração.
+func Register(scheme string, r Resolver) {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+	plugins[strings.ToLower(scheme)] = r
+}
+
+// Registry encaminha cada referência ao resolver do seu esquema. Valores sem esquema registrado
+// são secrets literais e voltam inalterados.
+type Registry struct {
+	resolvers map[string]Resolver
+}
+
+// VaultOptions configura o resolver vault:// (API HTTP do Vault ou um serviço compatível).
+type VaultOptions struct {
+	Addr    string // ex: http://127.0.0.1:8200
+	Token   string // enviado em X-Vault-Token
+	Timeout time.Duration
+}
+
+// NewRegistry cria um registry com file://, env://, vault:// (quando vault.Addr estiver definido)
+// e os resolvers adicionados com Register.
+func NewRegistry(vault VaultOptions) *Registry {
+	r := &Registry{resolvers: map[string]Resolver{
+		"file": ResolverFunc(resolveFile),
+		"env":  ResolverFunc(resolveEnv),
+	}}
+	if vault.Addr != "" {
+		r.resolvers["vault"] = newVaultResolver(vault)
+	}
+	pluginsMu.RLock()
+	defer pluginsMu.RUnlock()
+	for scheme, res := range plugins {
+		r.resolvers[scheme] = res
+	}
+	return r
+}
+
+// Resolve retorna o secret referenciado por value ou o próprio value quando ele não é uma referência.
+func (r *Registry) Resolve(ctx context.Context, value string) (string, error) {
+	scheme, _, ok := strings.Cut(value, "://")
+	if !ok {
+		return value, nil
+	}
+	scheme = strings.ToLower(scheme)
+	res, ok := r.resolvers[scheme]
+	if !ok {
+		if scheme == "vault" {
+			return "", errors.New("vault:// reference but VAULT_ADDR is not set")
+		}
+		return value, nil
+	}
+	ref, err := url.Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid secret reference: %w", err)
+	}
+	secret, err := res.Resolve(ctx, ref)
+	if err != nil {
+		// A referência não é secreta, mas o erro não deve carregar nenhum trecho do valor resolvido
+		return "", fmt.Errorf("%s://%s%s: %w", scheme, ref.Host, ref.Path, err)
+	}
+	return secret, nil
+}
+
+// ReadFile lê um secret de arquivo (Docker/Kubernetes secrets), sem a quebra de linha final.
+func ReadFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// resolveFile trata file:///caminho/absoluto (ou file://caminho/relativo).
+func resolveFile(_ context.Context, ref *url.URL) (string, error) {
+	return ReadFile(ref.Host + ref.Path)
+}
+
+// resolveEnv trata env://NOME_DA_VARIAVEL.
+func resolveEnv(_ context.Context, ref *url.URL) (string, error) {
+	name := ref.Host + strings.TrimPrefix(ref.Path, "/")
+	val, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s not set", name)
+	}
+	return val, nil
+}
+
+// vaultResolver lê secrets pela API HTTP do Vault: vault://<caminho>#<campo> faz
+// GET <addr>/v1/<caminho> e retorna data.data.<campo> (KV v2) ou data.<campo> (KV v1).
+// O campo padrão é "value".
+type vaultResolver struct {
+	addr   string
+	token  string
+	client *http.Client
+}
+
+func newVaultResolver(opts VaultOptions) *vaultResolver {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	return &vaultResolver{
+		addr:   strings.TrimRight(opts.Addr, "/"),
+		token:  opts.Token,
+		client: &http.Client{Timeout: opts.Timeout},
+	}
+}
+
+func (v *vaultResolver) Resolve(ctx context.Context, ref *url.URL) (string, error) {
+	path := strings.Trim(ref.Host+ref.Path, "/")
+	field := ref.Fragment
+	if field == "" {
+		field = "value"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.addr+"/v1/"+path, nil)
+	if err != nil {
+		return "", err
+	}
+	if v.token != "" {
+		req.Header.Set("X-Vault-Token", v.token)
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned %s", resp.Status)
+	}
+
+	var body struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("invalid vault response: %w", err)
+	}
+	data := body.Data
+	// KV v2 aninha os valores em data.data (ao lado de data.metadata)
+	if nested, ok := data["data"]; ok {
+		var kv2 map[string]json.RawMessage
+		if err := json.Unmarshal(nested, &kv2); err == nil {
+			data = kv2
+		}
+	}
+	raw, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("field %q not found", field)
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", fmt.Errorf("field %q is not a string", field)
+	}
+	return value, nil
+}
@@ -0,0 +1,103 @@
+package secrets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRegistryResolve(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "key")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DVR_TEST_SECRET", "from-env")
+
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/dvr":
+			w.Write([]byte(`{"data":{"data":{"value":"kv2-value","api":"kv2-api"},"metadata":{"version":3}}}`))
+		case "/v1/kv/dvr":
+			w.Write([]byte(`{"data":{"value":"kv1-value","port":5672}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer vault.Close()
+
+	Register("test", ResolverFunc(func(_ context.Context, ref *url.URL) (string, error) {
+		return "plugin:" + ref.Host, nil
+	}))
+	defer func() {
+		pluginsMu.Lock()
+		delete(plugins, "test")
+		pluginsMu.Unlock()
+	}()
+
+	withVault := NewRegistry(VaultOptions{Addr: vault.URL, Token: "root"})
+	withoutVault := NewRegistry(VaultOptions{})
+
+	tests := []struct {
+		name     string
+		registry *Registry
+		value    string
+		want     string
+		wantErr  string
+	}{
+		{name: "literal", registry: withoutVault, value: "s3cr3t", want: "s3cr3t"},
+		{name: "unknown scheme is literal", registry: withoutVault, value: "https://user:pw@host/", want: "https://user:pw@host/"},
+		{name: "file", registry: withoutVault, value: "file://" + secretFile, want: "from-file"},
+		{name: "missing file", registry: withoutVault, value: "file://" + filepath.Join(dir, "nope"), wantErr: "no such file"},
+		{name: "env", registry: withoutVault, value: "env://DVR_TEST_SECRET", want: "from-env"},
+		{name: "scheme is case insensitive", registry: withoutVault, value: "ENV://DVR_TEST_SECRET", want: "from-env"},
+		{name: "missing env", registry: withoutVault, value: "env://DVR_TEST_MISSING", wantErr: "not set"},
+		{name: "plugin", registry: withoutVault, value: "test://abc", want: "plugin:abc"},
+		{name: "vault without VAULT_ADDR", registry: withoutVault, value: "vault://secret/data/dvr", wantErr: "VAULT_ADDR is not set"},
+		{name: "vault kv2 default field", registry: withVault, value: "vault://secret/data/dvr", want: "kv2-value"},
+		{name: "vault kv2 field", registry: withVault, value: "vault://secret/data/dvr#api", want: "kv2-api"},
+		{name: "vault kv1", registry: withVault, value: "vault://kv/dvr", want: "kv1-value"},
+		{name: "vault missing field", registry: withVault, value: "vault://kv/dvr#user", wantErr: `field "user" not found`},
+		{name: "vault field not a string", registry: withVault, value: "vault://kv/dvr#port", wantErr: "not a string"},
+		{name: "vault missing path", registry: withVault, value: "vault://kv/other", wantErr: "404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.registry.Resolve(context.Background(), tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("Resolve(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistryResolveWrongVaultToken(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "permission denied", http.StatusForbidden)
+	}))
+	defer vault.Close()
+
+	r := NewRegistry(VaultOptions{Addr: vault.URL, Token: "wrong"})
+	_, err := r.Resolve(context.Background(), "vault://secret/data/dvr#value")
+	if err == nil || !strings.Contains(err.Error(), "vault://secret/data/dvr: vault returned 403") {
+		t.Fatalf("error = %v", err)
+	}
+}
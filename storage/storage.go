@@ -1,28 +1,43 @@
 package storage
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"log/slog"
+	"mime"
 	"mime/multipart"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"dvr-upload/config"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	s3config "github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
 type StorageService struct {
-	cfg      *config.Config
-	s3Client *s3.Client
-	log      *slog.Logger
+	cfg         *config.Config
+	s3Client    *s3.Client
+	credentials *aws.CredentialsCache
+	keys        *rotatingCredentials
+	log         *slog.Logger
+}
+
+// rotatingCredentials fornece as credenciais atuais do object storage. SetCredentials as troca sem
+// recriar o cliente S3 (rotação de secrets ao recarregar a configuração).
+type rotatingCredentials struct {
+	value atomic.Pointer[aws.Credentials]
+}
+
+func (r *rotatingCredentials) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	return *r.value.Load(), nil
 }
 
 func NewStorageService(cfg *config.Config, log *slog.Logger) *StorageService {
@@ -44,10 +59,14 @@ func (s *StorageService) initS3() {
 		return
 	}
 
+	s.keys = &rotatingCredentials{}
+	s.keys.value.Store(&aws.Credentials{AccessKeyID: s.cfg.S3AccessKey, SecretAccessKey: s.cfg.S3SecretKey, Source: "dvr-upload"})
+	s.credentials = aws.NewCredentialsCache(s.keys)
+
 	cfg, err := s3config.LoadDefaultConfig(
 		context.TODO(),
 		s3config.WithRegion(s.cfg.S3Region),
-		s3config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(s.cfg.S3AccessKey, s.cfg.S3SecretKey, "")),
+		s3config.WithCredentialsProvider(s.credentials),
 	)
 	if err != nil {
 		s.log.Error("Failed to load AWS config for S3-compatible endpoint", "error", err)
@@ -69,11 +88,26 @@ func (s *StorageService) initS3() {
 		"checksum_mode", "when_required")
 }
 
-func (s *StorageService) Ping() error {
+// SetCredentials troca as credenciais usadas pelo cliente S3. As próximas requisições já assinam
+// com a nova chave; uploads em andamento terminam com a anterior.
+func (s *StorageService) SetCredentials(accessKey, secretKey string) {
+	if s.keys == nil {
+		return
+	}
+	s.keys.value.Store(&aws.Credentials{AccessKeyID: accessKey, SecretAccessKey: secretKey, Source: "dvr-upload"})
+	s.credentials.Invalidate()
+}
+
+// S3Enabled indica se o cliente S3 foi inicializado e há bucket configurado.
+func (s *StorageService) S3Enabled() bool {
+	return s.s3Client != nil && s.cfg.S3Bucket != ""
+}
+
+func (s *StorageService) Ping(ctx context.Context) error {
 	if s.s3Client == nil {
 		return fmt.Errorf("S3 client not initialized")
 	}
-	_, err := s.s3Client.ListBuckets(context.TODO(), &s3.ListBucketsInput{})
+	_, err := s.s3Client.ListBuckets(ctx, &s3.ListBucketsInput{})
 	return err
 }
 
@@ -149,6 +183,11 @@ func (s *StorageService) CopyToBackup(srcPath, filename string, logger *slog.Log
 }
 
 func (s *StorageService) UploadFileToS3(filePath string, filename string, logger *slog.Logger) error {
+	return s.UploadFileToS3WithMetadata(filePath, filename, nil, logger)
+}
+
+// UploadFileToS3WithMetadata envia o arquivo gravando metadata como user metadata (x-amz-meta-*) do objeto.
+func (s *StorageService) UploadFileToS3WithMetadata(filePath string, filename string, metadata map[string]string, logger *slog.Logger) error {
 	if s.s3Client == nil || s.cfg.S3Bucket == "" {
 		logger.Warn("S3 upload skipped: client not initialized or bucket not set")
 		return nil
@@ -167,15 +206,7 @@ func (s *StorageService) UploadFileToS3(filePath string, filename string, logger
 	}
 	fileSize := stat.Size()
 
-	contentType := "application/octet-stream"
-	switch strings.ToLower(filepath.Ext(filename)) {
-	case ".mp4":
-		contentType = "video/mp4"
-	case ".ts":
-		contentType = "video/MP2T"
-	case ".jpg", ".jpeg":
-		contentType = "image/jpeg"
-	}
+	contentType := ContentTypeFor(filename)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
@@ -186,6 +217,7 @@ func (s *StorageService) UploadFileToS3(filePath string, filename string, logger
 		Body:          f,
 		ContentLength: aws.Int64(fileSize),
 		ContentType:   aws.String(contentType),
+		Metadata:      metadata,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to upload to S3-compatible storage (duration: %s): %w", time.Since(start), err)
@@ -193,3 +225,247 @@ func (s *StorageService) UploadFileToS3(filePath string, filename string, logger
 
 	return nil
 }
+
+// DeleteObject remove um objeto do bucket.
+func (s *StorageService) DeleteObject(ctx context.Context, key string) error {
+	if !s.S3Enabled() {
+		return fmt.Errorf("S3 client not initialized")
+	}
+	_, err := s.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.cfg.S3Bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+// DeletePrefix remove todos os objetos sob o prefixo (ex: pacote HLS). Retorna quantos foram removidos.
+func (s *StorageService) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	if !s.S3Enabled() {
+		return 0, fmt.Errorf("S3 client not initialized")
+	}
+	deleted := 0
+	paginator := s3.NewListObjectsV2Paginator(s.s3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.cfg.S3Bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return deleted, err
+		}
+		for _, obj := range page.Contents {
+			if err := s.DeleteObject(ctx, aws.ToString(obj.Key)); err != nil {
+				return deleted, err
+			}
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// DownloadToFile grava o objeto do bucket no caminho informado.
+func (s *StorageService) DownloadToFile(ctx context.Context, key, dstPath string) (int64, error) {
+	obj, err := s.GetObject(ctx, key, GetObjectOptions{})
+	if err != nil {
+		return 0, err
+	}
+	defer obj.Body.Close()
+
+	f, err := os.Create(dstPath)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, obj.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dstPath)
+		return 0, err
+	}
+	return n, nil
+}
+
+// PutObjectBytes envia um conteúdo pequeno (ex: manifestos) para o S3.
+func (s *StorageService) PutObjectBytes(key string, data []byte, logger *slog.Logger) error {
+	if !s.S3Enabled() {
+		logger.Warn("S3 upload skipped: client not initialized or bucket not set")
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := s.s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.cfg.S3Bucket),
+		Key:           aws.String(key),
+		Body:          bytes.NewReader(data),
+		ContentLength: aws.Int64(int64(len(data))),
+		ContentType:   aws.String(ContentTypeFor(key)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload object to S3-compatible storage: %w", err)
+	}
+	return nil
+}
+
+// UploadDirToS3 envia todos os arquivos de dir para o S3 sob o prefixo informado,
+// preservando a estrutura de subpastas. Retorna as keys enviadas.
+func (s *StorageService) UploadDirToS3(dir string, prefix string, logger *slog.Logger) ([]string, error) {
+	var keys []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		key := strings.TrimSuffix(prefix, "/") + "/" + filepath.ToSlash(rel)
+		if err := s.UploadFileToS3(path, key, logger); err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		return nil
+	})
+	return keys, err
+}
+
+// PresignGetURL gera uma URL GET temporária para a object key. Se filename for
+// informado, a resposta do S3 vem com Content-Disposition de download com esse nome.
+func (s *StorageService) PresignGetURL(key string, expiry time.Duration, filename string) (string, error) {
+	if !s.S3Enabled() {
+		return "", fmt.Errorf("S3 client not initialized")
+	}
+
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.cfg.S3Bucket),
+		Key:    aws.String(key),
+	}
+	if filename != "" {
+		input.ResponseContentDisposition = aws.String(ContentDisposition(filename))
+	}
+
+	req, err := s3.NewPresignClient(s.s3Client).PresignGetObject(context.TODO(), input, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return "", fmt.Errorf("failed to presign object: %w", err)
+	}
+	return req.URL, nil
+}
+
+// LocalPath resolve uma key para o arquivo local em VideoPath ou BackupPath,
+// recusando keys que escapariam dessas pastas.
+func (s *StorageService) LocalPath(key string) (string, bool) {
+	clean := filepath.Clean("/" + filepath.FromSlash(key))
+	if clean == string(filepath.Separator) || strings.Contains(clean, string(filepath.Separator)+".processing") {
+		return "", false
+	}
+
+	for _, base := range []string{s.cfg.VideoPath, s.cfg.BackupPath} {
+		if base == "" {
+			continue
+		}
+		candidate := filepath.Join(base, clean)
+		if stat, err := os.Stat(candidate); err == nil && stat.Mode().IsRegular() {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// ContentDisposition monta o header de download para o nome de arquivo informado.
+func ContentDisposition(filename string) string {
+	return mime.FormatMediaType("attachment", map[string]string{"filename": filepath.Base(filename)})
+}
+
+// ContentTypeFor retorna o Content-Type das mídias tratadas pelo serviço.
+func ContentTypeFor(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".mp4":
+		return "video/mp4"
+	case ".ts":
+		return "video/MP2T"
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".png":
+		return "image/png"
+	case ".webp":
+		return "image/webp"
+	case ".avif":
+		return "image/avif"
+	case ".m3u8":
+		return "application/vnd.apple.mpegurl"
+	case ".m4s":
+		return "video/iso.segment"
+	case ".json":
+		return "application/json"
+	}
+	return "application/octet-stream"
+}
+
+// GetObjectOptions repassa ao S3 os headers condicionais e de Range da requisição original.
+type GetObjectOptions struct {
+	Range             string
+	IfNoneMatch       string
+	IfModifiedSince   string
+	IfMatch           string
+	IfUnmodifiedSince string
+}
+
+// Object é o resultado de GetObject. Body deve ser fechado pelo chamador.
+type Object struct {
+	Body          io.ReadCloser
+	ContentLength int64
+	ContentRange  string
+	ContentType   string
+	ETag          string
+	LastModified  time.Time
+}
+
+// GetObject lê uma object key do S3. Respostas condicionais (304, 412, 416) retornam erro;
+// o status HTTP pode ser obtido via a interface HTTPStatusCode() do erro.
+func (s *StorageService) GetObject(ctx context.Context, key string, opts GetObjectOptions) (*Object, error) {
+	if !s.S3Enabled() {
+		return nil, fmt.Errorf("S3 client not initialized")
+	}
+
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.cfg.S3Bucket),
+		Key:    aws.String(key),
+	}
+	if opts.Range != "" {
+		input.Range = aws.String(opts.Range)
+	}
+	if opts.IfNoneMatch != "" {
+		input.IfNoneMatch = aws.String(opts.IfNoneMatch)
+	}
+	if opts.IfMatch != "" {
+		input.IfMatch = aws.String(opts.IfMatch)
+	}
+	if t, err := http.ParseTime(opts.IfModifiedSince); err == nil {
+		input.IfModifiedSince = aws.Time(t)
+	}
+	if t, err := http.ParseTime(opts.IfUnmodifiedSince); err == nil {
+		input.IfUnmodifiedSince = aws.Time(t)
+	}
+
+	out, err := s.s3Client.GetObject(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := &Object{
+		Body:         out.Body,
+		ContentRange: aws.ToString(out.ContentRange),
+		ContentType:  aws.ToString(out.ContentType),
+		ETag:         aws.ToString(out.ETag),
+		LastModified: aws.ToTime(out.LastModified),
+	}
+	if out.ContentLength != nil {
+		obj.ContentLength = *out.ContentLength
+	}
+	if obj.ContentType == "" || obj.ContentType == "application/octet-stream" {
+		obj.ContentType = ContentTypeFor(key)
+	}
+	return obj, nil
+}
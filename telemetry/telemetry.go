@@ -0,0 +1,161 @@
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// MaxMetaSize limita o campo meta já escapado para ASCII (como vai para o S3): o S3 aceita no
+// máximo 2 KB de user metadata por objeto.
+const MaxMetaSize = 1024
+
+var alarmCodeRe = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,32}$`)
+
+// Fields lista os campos do formulário de upload tratados como telemetria.
+var Fields = []string{"lat", "lon", "speed", "heading", "alarm", "meta"}
+
+// Telemetry são os dados de posição/alarme enviados pela câmera junto com o arquivo.
+type Telemetry struct {
+	Lat     *float64        `json:"lat,omitempty"`
+	Lon     *float64        `json:"lon,omitempty"`
+	Speed   *float64        `json:"speed,omitempty"`   // km/h
+	Heading *float64        `json:"heading,omitempty"` // graus (0-360)
+	Alarm   string          `json:"alarm,omitempty"`
+	Meta    json.RawMessage `json:"meta,omitempty"`
+}
+
+// Parse valida os campos de telemetria do formulário. Retorna nil quando nenhum foi enviado.
+func Parse(values map[string]string) (*Telemetry, error) {
+	var t Telemetry
+	var err error
+	empty := true
+
+	parse := func(name string, min, max float64) *float64 {
+		v := strings.TrimSpace(values[name])
+		if v == "" || err != nil {
+			return nil
+		}
+		empty = false
+		f, parseErr := strconv.ParseFloat(v, 64)
+		if parseErr != nil || math.IsNaN(f) || f < min || f > max {
+			err = fmt.Errorf("invalid %s (expect a number between %g and %g)", name, min, max)
+			return nil
+		}
+		return &f
+	}
+
+	t.Lat = parse("lat", -90, 90)
+	t.Lon = parse("lon", -180, 180)
+	t.Speed = parse("speed", 0, 1000)
+	t.Heading = parse("heading", 0, 360)
+	if err != nil {
+		return nil, err
+	}
+	if (t.Lat == nil) != (t.Lon == nil) {
+		return nil, errors.New("lat and lon must be sent together")
+	}
+
+	if alarm := strings.TrimSpace(values["alarm"]); alarm != "" {
+		if !alarmCodeRe.MatchString(alarm) {
+			return nil, errors.New("invalid alarm code")
+		}
+		t.Alarm = alarm
+		empty = false
+	}
+
+	if meta := strings.TrimSpace(values["meta"]); meta != "" {
+		if len(meta) > MaxMetaSize {
+			return nil, fmt.Errorf("meta exceeds %d bytes", MaxMetaSize)
+		}
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(meta), &obj); err != nil {
+			return nil, errors.New("meta must be a JSON object")
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, []byte(meta)); err != nil {
+			return nil, errors.New("meta must be a JSON object")
+		}
+		// Caracteres não ASCII crescem até 12 bytes no escape \uXXXX: o limite vale para o resultado
+		if len(asciiJSON(compact.Bytes())) > MaxMetaSize {
+			return nil, fmt.Errorf("meta exceeds %d bytes", MaxMetaSize)
+		}
+		t.Meta = compact.Bytes()
+		empty = false
+	}
+
+	if empty {
+		return nil, nil
+	}
+	return &t, nil
+}
+
+// Metadata converte a telemetria em user metadata do S3 (x-amz-meta-*).
+func (t *Telemetry) Metadata() map[string]string {
+	if t == nil {
+		return nil
+	}
+	m := make(map[string]string)
+	set := func(key string, v *float64) {
+		if v != nil {
+			m[key] = strconv.FormatFloat(*v, 'f', -1, 64)
+		}
+	}
+	set("lat", t.Lat)
+	set("lon", t.Lon)
+	set("speed", t.Speed)
+	set("heading", t.Heading)
+	if t.Alarm != "" {
+		m["alarm"] = t.Alarm
+	}
+	if len(t.Meta) > 0 {
+		m["meta"] = asciiJSON(t.Meta)
+	}
+	return m
+}
+
+// asciiJSON escapa caracteres não ASCII como \uXXXX (o JSON continua válido), já que
+// a user metadata do S3 só aceita ASCII.
+func asciiJSON(data []byte) string {
+	var b strings.Builder
+	for _, r := range string(data) {
+		switch {
+		case r < utf8.RuneSelf:
+			b.WriteRune(r)
+		case r > 0xFFFF:
+			r1, r2 := utf16.EncodeRune(r)
+			fmt.Fprintf(&b, "\\u%04x\\u%04x", r1, r2)
+		default:
+			fmt.Fprintf(&b, "\\u%04x", r)
+		}
+	}
+	return b.String()
+}
+
+// Caption gera o texto exibido na faixa de legenda do vídeo.
+func (t *Telemetry) Caption() string {
+	if t == nil {
+		return ""
+	}
+	var parts []string
+	if t.Lat != nil && t.Lon != nil {
+		parts = append(parts, fmt.Sprintf("%.6f, %.6f", *t.Lat, *t.Lon))
+	}
+	if t.Speed != nil {
+		parts = append(parts, fmt.Sprintf("%.0f km/h", *t.Speed))
+	}
+	if t.Heading != nil {
+		parts = append(parts, fmt.Sprintf("%.0f°", *t.Heading))
+	}
+	if t.Alarm != "" {
+		parts = append(parts, "alarm "+t.Alarm)
+	}
+	return strings.Join(parts, " | ")
+}
@@ -0,0 +1,82 @@
+package telemetry
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		values      map[string]string
+		wantErr     string
+		wantMeta    map[string]string // Metadata() do resultado
+		wantCaption string
+	}{
+		{
+			name:   "no telemetry",
+			values: map[string]string{"lat": " ", "meta": ""},
+		},
+		{
+			name:        "all fields",
+			values:      map[string]string{"lat": "-23.55052", "lon": "-46.633308", "speed": "62.5", "heading": "90", "alarm": "overspeed"},
+			wantMeta:    map[string]string{"lat": "-23.55052", "lon": "-46.633308", "speed": "62.5", "heading": "90", "alarm": "overspeed"},
+			wantCaption: "-23.550520, -46.633308 | 62 km/h | 90° | alarm overspeed",
+		},
+		{
+			name:        "alarm only",
+			values:      map[string]string{"alarm": "SOS_1"},
+			wantMeta:    map[string]string{"alarm": "SOS_1"},
+			wantCaption: "alarm SOS_1",
+		},
+		{
+			name:     "meta is compacted",
+			values:   map[string]string{"meta": `{ "driver": "ana", "plate": "ABC1D23" }`},
+			wantMeta: map[string]string{"meta": `{"driver":"ana","plate":"ABC1D23"}`},
+		},
+		{
+			name:     "non ASCII meta is escaped",
+			values:   map[string]string{"meta": `{"motorista":"João 🚚"}`},
+			wantMeta: map[string]string{"meta": `{"motorista":"Jo\u00e3o \ud83d\ude9a"}`},
+		},
+		{name: "lat without lon", values: map[string]string{"lat": "1"}, wantErr: "sent together"},
+		{name: "lat out of range", values: map[string]string{"lat": "91", "lon": "0"}, wantErr: "invalid lat"},
+		{name: "lon not a number", values: map[string]string{"lat": "0", "lon": "east"}, wantErr: "invalid lon"},
+		{name: "NaN speed", values: map[string]string{"speed": "NaN"}, wantErr: "invalid speed"},
+		{name: "negative heading", values: map[string]string{"heading": "-1"}, wantErr: "invalid heading"},
+		{name: "alarm with spaces", values: map[string]string{"alarm": "over speed"}, wantErr: "invalid alarm"},
+		{name: "meta not an object", values: map[string]string{"meta": `[1,2]`}, wantErr: "JSON object"},
+		{name: "meta not JSON", values: map[string]string{"meta": `{driver}`}, wantErr: "JSON object"},
+		{
+			name:    "meta too long",
+			values:  map[string]string{"meta": `{"k":"` + strings.Repeat("a", MaxMetaSize) + `"}`},
+			wantErr: "meta exceeds",
+		},
+		{
+			name:    "meta too long only after escaping",
+			values:  map[string]string{"meta": `{"k":"` + strings.Repeat("é", 400) + `"}`},
+			wantErr: "meta exceeds",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.values)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if meta := got.Metadata(); !reflect.DeepEqual(meta, tt.wantMeta) {
+				t.Fatalf("Metadata() = %v, want %v", meta, tt.wantMeta)
+			}
+			if caption := got.Caption(); caption != tt.wantCaption {
+				t.Fatalf("Caption() = %q, want %q", caption, tt.wantCaption)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exportadores suportados.
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+const tracerName = "dvr-upload"
+
+// Atributos dos spans de upload e processamento.
+const (
+	AttrRequestID = attribute.Key("dvr.request_id")
+	AttrIMEI      = attribute.Key("dvr.imei")
+	AttrType      = attribute.Key("dvr.type")
+	AttrChannel   = attribute.Key("dvr.channel")
+	AttrFilename  = attribute.Key("dvr.file.name")
+	AttrSize      = attribute.Key("dvr.file.size")
+	AttrExt       = attribute.Key("dvr.file.ext")
+	AttrClass     = attribute.Key("dvr.priority_class")
+	AttrStage     = attribute.Key("dvr.stage")
+)
+
+// Options configura o tracing.
+type Options struct {
+	Exporter     string  // none, stdout ou otlp
+	OTLPEndpoint string  // URL do coletor OTLP/HTTP; vazio usa OTEL_EXPORTER_OTLP_* ou localhost:4318
+	ServiceName  string  // service.name do resource
+	SampleRatio  float64 // fração de traces amostrados (respeita a decisão do pai)
+}
+
+// Setup instala o TracerProvider e o propagador W3C (traceparent/tracestate) globais. Com o
+// exportador "none" os spans não são gravados, mas o contexto recebido continua sendo propagado.
+// A função retornada descarrega os spans pendentes.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(opts.Exporter) {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		var clientOpts []otlptracehttp.Option
+		if opts.OTLPEndpoint != "" {
+			clientOpts = append(clientOpts, otlptracehttp.WithEndpointURL(opts.OTLPEndpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, clientOpts...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", opts.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s exporter: %w", opts.Exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", opts.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Tracer retorna o tracer do serviço (no-op enquanto Setup não instalar um provider).
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Extract retorna ctx com o contexto de trace recebido nos headers HTTP (traceparent).
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// Inject serializa o contexto de trace de ctx (traceparent, tracestate, baggage) para ser enviado
+// nos headers das mensagens. Retorna nil quando não há span ativo.
+func Inject(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+// Detach retorna um contexto sem cancelamento que carrega apenas o span de ctx, para continuar o
+// trace em um job assíncrono que sobrevive à requisição.
+func Detach(ctx context.Context) context.Context {
+	return trace.ContextWithSpan(context.Background(), trace.SpanFromContext(ctx))
+}
@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const testTraceparent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+
+// restoreGlobals desfaz, ao fim do teste, o provider e o propagador globais instalados por Setup.
+func restoreGlobals(t *testing.T) {
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+}
+
+func TestSetup(t *testing.T) {
+	restoreGlobals(t)
+	ctx := context.Background()
+
+	for _, exporter := range []string{"", ExporterNone, ExporterStdout, "STDOUT", ExporterOTLP} {
+		shutdown, err := Setup(ctx, Options{Exporter: exporter, OTLPEndpoint: "http://127.0.0.1:1", ServiceName: "dvr-upload", SampleRatio: 1})
+		if err != nil {
+			t.Fatalf("Setup(%q): %v", exporter, err)
+		}
+		if err := shutdown(ctx); err != nil {
+			t.Fatalf("shutdown(%q): %v", exporter, err)
+		}
+	}
+	if _, err := Setup(ctx, Options{Exporter: "jaeger"}); err == nil || !strings.Contains(err.Error(), `unknown tracing exporter "jaeger"`) {
+		t.Fatalf("unknown exporter: %v", err)
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	restoreGlobals(t)
+	// Com o exportador "none" nada é gravado, mas o contexto recebido continua sendo propagado
+	if _, err := Setup(context.Background(), Options{Exporter: ExporterNone}); err != nil {
+		t.Fatal(err)
+	}
+	if carrier := Inject(context.Background()); carrier != nil {
+		t.Fatalf("Inject without span = %v", carrier)
+	}
+
+	header := http.Header{}
+	header.Set("traceparent", testTraceparent)
+	ctx := Extract(context.Background(), header)
+	if got := Inject(ctx)["traceparent"]; got != testTraceparent {
+		t.Fatalf("traceparent = %q, want %q", got, testTraceparent)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	detached := Detach(ctx)
+	cancel()
+	if detached.Err() != nil {
+		t.Fatal("detached context cancelled with the request")
+	}
+	if got := trace.SpanContextFromContext(detached); got.TraceID().String() != "0af7651916cd43dd8448eb211c80319c" {
+		t.Fatalf("detached trace ID = %s", got.TraceID())
+	}
+	if got := Inject(detached)["traceparent"]; got != testTraceparent {
+		t.Fatalf("detached traceparent = %q", got)
+	}
+}
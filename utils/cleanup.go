@@ -14,14 +14,15 @@ func StartCleanupTask(videoPath string, backupPath string, logger *slog.Logger)
 	ticker := time.NewTicker(5 * time.Minute)
 	go func() {
 		// Executa uma vez no início
-		cleanup(videoPath, backupPath, logger)
+		RunCleanup(videoPath, backupPath, logger)
 		for range ticker.C {
-			cleanup(videoPath, backupPath, logger)
+			RunCleanup(videoPath, backupPath, logger)
 		}
 	}()
 }
 
-func cleanup(videoPath string, backupPath string, logger *slog.Logger) {
+// RunCleanup executa uma varredura de limpeza e retorna quantos arquivos foram removidos.
+func RunCleanup(videoPath string, backupPath string, logger *slog.Logger) int {
 	dirs := []string{os.TempDir()}
 	processingDirs := []string{}
 
@@ -84,6 +85,10 @@ func cleanup(videoPath string, backupPath string, logger *slog.Logger) {
 			// Nunca apagar vídeos/imagens a menos que sejam temporários ou não tenham nome.
 			ext := strings.ToLower(filepath.Ext(name))
 			isMedia := ext == ".mp4" || ext == ".ts" || ext == ".jpg" || ext == ".jpeg" || ext == ".png" || ext == ".mkv" || ext == ".avi"
+			// Manifestos de custódia ficam ao lado da mídia e seguem as mesmas regras de proteção
+			if strings.HasSuffix(name, ".manifest.json") {
+				isMedia = true
+			}
 			isTemp := strings.HasPrefix(name, "upload-stream-") ||
 				strings.HasSuffix(name, ".tmp") ||
 				strings.Contains(name, ".compressed")
@@ -119,4 +124,5 @@ func cleanup(videoPath string, backupPath string, logger *slog.Logger) {
 	if cleanedCount > 0 {
 		logger.Info("Periodic cleanup finished", "files_removed", cleanedCount)
 	}
+	return cleanedCount
 }
@@ -1,12 +1,46 @@
 package utils
 
 import (
+	"crypto/hmac"
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 )
 
 func GenerateSign(filename, timestamp, secret string) string {
 	sum := md5.Sum([]byte(filename + timestamp + secret))
 	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%x", sum)))
 }
+
+// FileSHA256 calcula o SHA-256 (hex) do conteúdo de um arquivo.
+func FileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// SignURL gera a assinatura HMAC-SHA256 (hex) das partes de uma URL assinada.
+func SignURL(secret string, parts ...string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyURLSignature compara a assinatura recebida com a esperada em tempo constante.
+func VerifyURLSignature(secret, sig string, parts ...string) bool {
+	expected := SignURL(secret, parts...)
+	return hmac.Equal([]byte(expected), []byte(sig))
+}
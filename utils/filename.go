@@ -84,6 +84,63 @@ func BuildStandardFilename(r *http.Request, fh *multipart.FileHeader) (string, e
 	return "", errors.New("insufficient data for standardized filename")
 }
 
+// FilenameInfo contém os campos extraídos de um nome gerado por BuildStandardFilename.
+type FilenameInfo struct {
+	Pattern     string // "event" ou "snapshot"
+	IMEI        string
+	Type        string
+	Channel     string
+	CaptureTime time.Time
+	Raw         string
+	Index       string
+	Ext         string
+}
+
+var (
+	eventFilenameRe    = regexp.MustCompile(`^EVENT_([0-9]{8,20})_([0-9]+)_([0-9]{4}_[0-9]{2}_[0-9]{2}_[0-9]{2}_[0-9]{2}_[0-9]{2})_([IF]?)_([0-9]{0,3})(\.[A-Za-z0-9]+)$`)
+	snapshotFilenameRe = regexp.MustCompile(`^([0-9]{8,20})_([0-9A-Fa-f]*)_([0-9]{0,3})_([0-9]{2,3})(\.[A-Za-z0-9]+)$`)
+)
+
+// ParseStandardFilename faz o caminho inverso de BuildStandardFilename, para os casos em que
+// só temos o nome do arquivo (ex: arquivos recuperados após crash ou nome informado pela câmera).
+func ParseStandardFilename(name string) (FilenameInfo, bool) {
+	name = filepath.Base(name)
+	if m := eventFilenameRe.FindStringSubmatch(name); m != nil {
+		t, err := time.ParseInLocation("2006_01_02_15_04_05", m[3], time.UTC)
+		if err != nil {
+			return FilenameInfo{}, false
+		}
+		return FilenameInfo{
+			Pattern:     "event",
+			IMEI:        m[1],
+			Type:        m[4],
+			Channel:     m[5],
+			CaptureTime: t,
+			Ext:         strings.ToLower(m[6]),
+		}, true
+	}
+	if m := snapshotFilenameRe.FindStringSubmatch(name); m != nil {
+		return FilenameInfo{
+			Pattern: "snapshot",
+			IMEI:    m[1],
+			Raw:     m[2],
+			Channel: m[3],
+			Index:   m[4],
+			Ext:     strings.ToLower(m[5]),
+		}, true
+	}
+	return FilenameInfo{}, false
+}
+
+// ParseDateTime aceita os mesmos formatos do campo datetime do upload
+// (unix em segundos, yyyymmddHHMMSS ou RFC3339), mas exige um valor.
+func ParseDateTime(v string) (time.Time, error) {
+	if strings.TrimSpace(v) == "" {
+		return time.Time{}, errors.New("empty datetime")
+	}
+	return parseDateTimeFlexible(strings.TrimSpace(v))
+}
+
 func parseDateTimeFlexible(v string) (time.Time, error) {
 	if v == "" {
 		return time.Now().UTC(), nil
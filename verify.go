@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"os"
+
+	"dvr-upload/custody"
+)
+
+// runVerify implementa o subcomando "dvr-upload verify [-pubkey KEY] <manifest.json> <arquivo>",
+// que confere um arquivo contra seu manifesto de custódia. Sem chave confiável (-pubkey,
+// CUSTODY_PUBLIC_KEY ou CUSTODY_SIGNING_KEY) a assinatura sai como UNVERIFIED e o código é 1:
+// a chave embutida no manifesto é autodeclarada e não prova a origem.
+func runVerify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	pubKey := fs.String("pubkey", os.Getenv("CUSTODY_PUBLIC_KEY"), "trusted Ed25519 public key (base64 or hex)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: dvr-upload verify [-pubkey KEY] <manifest.json> <file>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+
+	var trusted []ed25519.PublicKey
+	if *pubKey != "" {
+		key, err := custody.ParsePublicKey(*pubKey)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "invalid public key:", err)
+			return 2
+		}
+		trusted = append(trusted, key)
+	} else if signingKey := os.Getenv("CUSTODY_SIGNING_KEY"); signingKey != "" {
+		key, err := custody.LoadPrivateKey(signingKey)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "invalid CUSTODY_SIGNING_KEY:", err)
+			return 2
+		}
+		trusted = append(trusted, key.Public().(ed25519.PublicKey))
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to open manifest:", err)
+		return 2
+	}
+	manifest, err := custody.Parse(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	sum, _, err := custody.HashFile(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to hash file:", err)
+		return 2
+	}
+
+	ok := true
+	if len(trusted) == 0 {
+		fmt.Println("signature: UNVERIFIED - no trusted public key (use -pubkey, CUSTODY_PUBLIC_KEY or CUSTODY_SIGNING_KEY)")
+		ok = false
+	} else if err := manifest.VerifySignature(trusted...); err != nil {
+		fmt.Println("signature: FAIL -", err)
+		ok = false
+	} else {
+		fmt.Println("signature: OK")
+	}
+	if artifact, err := manifest.Match(sum); err != nil {
+		fmt.Printf("file:      FAIL - %v (sha256 %s)\n", err, sum)
+		ok = false
+	} else {
+		fmt.Printf("file:      OK - matches %s artifact %s (sha256 %s)\n", artifact.Stage, artifact.Filename, sum)
+	}
+
+	if !ok {
+		return 1
+	}
+	return 0
+}
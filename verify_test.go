@@ -0,0 +1,79 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dvr-upload/custody"
+)
+
+func TestRunVerify(t *testing.T) {
+	dir := t.TempDir()
+	media := filepath.Join(dir, "video.mp4")
+	if err := os.WriteFile(media, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(dir, "other.mp4")
+	if err := os.WriteFile(other, []byte("other"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	seed := make([]byte, ed25519.SeedSize)
+	key := ed25519.NewKeyFromSeed(seed)
+	_, attacker, _ := ed25519.GenerateKey(nil)
+
+	// signed grava um manifesto do vídeo assinado com k
+	signed := func(name string, k ed25519.PrivateKey) string {
+		m := &custody.Manifest{RequestID: "req-1", Filename: "video.mp4"}
+		if _, err := m.AddArtifact("received", "video.mp4", media); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Sign(k); err != nil {
+			t.Fatal(err)
+		}
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	manifest := signed("video.mp4.manifest.json", key)
+	forged := signed("forged.manifest.json", attacker)
+	pub := base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want int
+	}{
+		{name: "trusted key", args: []string{"-pubkey", pub, manifest, media}, want: 0},
+		{name: "public key from the environment", env: map[string]string{"CUSTODY_PUBLIC_KEY": pub}, args: []string{manifest, media}, want: 0},
+		{name: "signing key from the environment", env: map[string]string{"CUSTODY_SIGNING_KEY": base64.StdEncoding.EncodeToString(seed)}, args: []string{manifest, media}, want: 0},
+		{name: "no trusted key", args: []string{manifest, media}, want: 1},
+		{name: "signed by another key", args: []string{"-pubkey", pub, forged, media}, want: 1},
+		{name: "file not in the manifest", args: []string{"-pubkey", pub, manifest, other}, want: 1},
+		{name: "invalid public key", args: []string{"-pubkey", "nope", manifest, media}, want: 2},
+		{name: "missing arguments", args: []string{manifest}, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CUSTODY_PUBLIC_KEY", "")
+			t.Setenv("CUSTODY_SIGNING_KEY", "")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			if got := runVerify(tt.args); got != tt.want {
+				t.Fatalf("runVerify(%q) = %d, want %d", tt.args, got, tt.want)
+			}
+		})
+	}
+}